@@ -0,0 +1,10 @@
+package auditlog
+
+import "errors"
+
+var (
+	errFilePathEmptyOrMissing   = errors.New("file path empty or missing")
+	errLineTooLong              = errors.New("line too long")
+	errWebhookURLEmptyOrMissing = errors.New("webhook URL empty or missing")
+	errUnexpectedStatus         = errors.New("unexpected status")
+)
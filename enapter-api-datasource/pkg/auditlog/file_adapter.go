@@ -0,0 +1,189 @@
+package auditlog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+var _ core.AuditLogPort = (*FileAdapter)(nil)
+
+type FileAdapterParams struct {
+	Logger log.Logger
+	Path   string
+}
+
+// FileAdapter appends audit records to a file in the JSON lines format.
+type FileAdapter struct {
+	logger log.Logger
+	// mu serializes writes, so that every record is written as a whole.
+	mu   sync.Mutex
+	path string
+}
+
+func NewFileAdapter(p FileAdapterParams) (*FileAdapter, error) {
+	if p.Path == "" {
+		return nil, errFilePathEmptyOrMissing
+	}
+	const dirPerm = 0o750
+	if err := os.MkdirAll(filepath.Dir(p.Path), dirPerm); err != nil {
+		return nil, fmt.Errorf("create directory: %w", err)
+	}
+	return &FileAdapter{
+		logger: p.Logger.With("logger", "audit_log_file_adapter"),
+		path:   p.Path,
+	}, nil
+}
+
+func (a *FileAdapter) WriteAuditRecord(
+	_ context.Context, r *core.AuditRecord,
+) (retErr error) {
+	line, err := json.Marshal(newRecord(r))
+	if err != nil {
+		return fmt.Errorf("marshal record: %w", err)
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	const filePerm = 0o600
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePerm)
+	if err != nil {
+		return fmt.Errorf("open: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			if retErr == nil {
+				retErr = fmt.Errorf("close: %w", err)
+			}
+		}
+	}()
+
+	if _, err := f.Write(line); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+
+	return nil
+}
+
+func (a *FileAdapter) ReadAuditRecords(
+	_ context.Context, req *core.ReadAuditRecordsRequest,
+) (*core.ReadAuditRecordsResponse, error) {
+	f, err := os.Open(a.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &core.ReadAuditRecordsResponse{}, nil
+		}
+		return nil, fmt.Errorf("open: %w", err)
+	}
+	defer f.Close()
+
+	// Only the records written completely so far are read, so that the
+	// writes do not wait for the whole file to be scanned.
+	a.mu.Lock()
+	info, err := f.Stat()
+	a.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+
+	var records []*core.AuditRecord
+
+	const maxLineSize = 1 << 20
+	reader := bufio.NewReader(io.LimitReader(f, info.Size()))
+
+	for i := 1; ; i++ {
+		line, err := readLine(reader, maxLineSize)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !errors.Is(err, errLineTooLong) {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+		var r record
+		if err == nil {
+			err = json.Unmarshal(line, &r)
+		}
+		if err != nil {
+			// A damaged line must not hide the rest of the log.
+			a.logger.Warn("skipped malformed audit log line",
+				"path", a.path,
+				"line", i,
+				"error", err)
+			continue
+		}
+		if a.match(&r, req) {
+			records = append(records, r.toCore())
+		}
+	}
+
+	// Records are written once the commands complete, so the commands
+	// running concurrently are not in the order of their start time.
+	slices.Reverse(records)
+	slices.SortStableFunc(records, func(x, y *core.AuditRecord) int {
+		return y.Time.Compare(x.Time)
+	})
+	if req.Limit > 0 && len(records) > req.Limit {
+		records = records[:req.Limit]
+	}
+
+	return &core.ReadAuditRecordsResponse{
+		Records: records,
+	}, nil
+}
+
+// readLine returns the next line without the line break. The rest of a line
+// longer than maxSize is skipped, so that the next line can be read.
+func readLine(r *bufio.Reader, maxSize int) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > maxSize+1 {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && (len(line) > 0 || tooLong):
+			// The last line has no line break.
+		case err != nil:
+			return nil, err
+		}
+		if tooLong {
+			return nil, fmt.Errorf("%w: over %d bytes", errLineTooLong, maxSize)
+		}
+		return bytes.TrimSuffix(line, []byte("\n")), nil
+	}
+}
+
+func (a *FileAdapter) match(r *record, req *core.ReadAuditRecordsRequest) bool {
+	if !req.From.IsZero() && r.Time.Before(req.From) {
+		return false
+	}
+	if !req.To.IsZero() && r.Time.After(req.To) {
+		return false
+	}
+	if req.DeviceID != "" && r.DeviceID != req.DeviceID {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package auditlog_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/Enapter/grafana-plugins/pkg/auditlog"
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+type FileAdapterSuite struct {
+	suite.Suite
+	ctx     context.Context
+	path    string
+	adapter *auditlog.FileAdapter
+}
+
+func (s *FileAdapterSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.path = filepath.Join(s.T().TempDir(), "data", "audit_log.jsonl")
+	adapter, err := auditlog.NewFileAdapter(auditlog.FileAdapterParams{
+		Logger: log.DefaultLogger,
+		Path:   s.path,
+	})
+	s.Require().NoError(err)
+	s.adapter = adapter
+}
+
+func (s *FileAdapterSuite) TestReadMissingFile() {
+	resp, err := s.adapter.ReadAuditRecords(s.ctx, &core.ReadAuditRecordsRequest{})
+	s.Require().NoError(err)
+	s.Require().Empty(resp.Records)
+}
+
+func (s *FileAdapterSuite) TestWriteAndRead() {
+	record := &core.AuditRecord{
+		Time:             time.Unix(42, 0).UTC(),
+		GrafanaUserLogin: "jack",
+		GrafanaUserEmail: "jack@example.com",
+		EnapterUser:      "e2a8",
+		DeviceID:         "dev",
+		CommandName:      "reboot",
+		CommandArgs:      map[string]any{"delay": float64(5)},
+		State:            "succeeded",
+		Payload:          map[string]any{"ok": true},
+		Latency:          250 * time.Millisecond,
+	}
+	s.Require().NoError(s.adapter.WriteAuditRecord(s.ctx, record))
+
+	resp, err := s.adapter.ReadAuditRecords(s.ctx, &core.ReadAuditRecordsRequest{})
+	s.Require().NoError(err)
+	s.Require().Equal([]*core.AuditRecord{record}, resp.Records)
+}
+
+func (s *FileAdapterSuite) TestReadFilterAndLimit() {
+	for i, deviceID := range []string{"a", "b", "a", "a", "a"} {
+		s.Require().NoError(s.adapter.WriteAuditRecord(s.ctx, &core.AuditRecord{
+			Time:        time.Unix(int64(i), 0).UTC(),
+			DeviceID:    deviceID,
+			CommandName: "reboot",
+		}))
+	}
+
+	resp, err := s.adapter.ReadAuditRecords(s.ctx, &core.ReadAuditRecordsRequest{
+		From:     time.Unix(1, 0),
+		To:       time.Unix(3, 0),
+		DeviceID: "a",
+		Limit:    5,
+	})
+	s.Require().NoError(err)
+	s.Require().Len(resp.Records, 2)
+	s.Require().Equal(time.Unix(3, 0).UTC(), resp.Records[0].Time)
+	s.Require().Equal(time.Unix(2, 0).UTC(), resp.Records[1].Time)
+
+	resp, err = s.adapter.ReadAuditRecords(s.ctx, &core.ReadAuditRecordsRequest{
+		Limit: 2,
+	})
+	s.Require().NoError(err)
+	s.Require().Len(resp.Records, 2)
+	s.Require().Equal(time.Unix(4, 0).UTC(), resp.Records[0].Time)
+	s.Require().Equal(time.Unix(3, 0).UTC(), resp.Records[1].Time)
+}
+
+func (s *FileAdapterSuite) TestReadSkipsMalformedLines() {
+	s.Require().NoError(s.adapter.WriteAuditRecord(s.ctx, &core.AuditRecord{
+		Time:        time.Unix(1, 0).UTC(),
+		CommandName: "reboot",
+	}))
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0)
+	s.Require().NoError(err)
+	_, err = f.WriteString("{\"time\":\n")
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+	s.Require().NoError(s.adapter.WriteAuditRecord(s.ctx, &core.AuditRecord{
+		Time:        time.Unix(2, 0).UTC(),
+		CommandName: "reboot",
+	}))
+
+	resp, err := s.adapter.ReadAuditRecords(s.ctx, &core.ReadAuditRecordsRequest{})
+	s.Require().NoError(err)
+	s.Require().Len(resp.Records, 2)
+	s.Require().Equal(time.Unix(2, 0).UTC(), resp.Records[0].Time)
+	s.Require().Equal(time.Unix(1, 0).UTC(), resp.Records[1].Time)
+}
+
+func (s *FileAdapterSuite) TestReadSkipsTooLongLines() {
+	for i, size := range []int{1, 2 << 20, 1} {
+		s.Require().NoError(s.adapter.WriteAuditRecord(s.ctx, &core.AuditRecord{
+			Time:        time.Unix(int64(i), 0).UTC(),
+			CommandName: "reboot",
+			CommandArgs: map[string]any{"data": strings.Repeat("x", size)},
+		}))
+	}
+
+	resp, err := s.adapter.ReadAuditRecords(s.ctx, &core.ReadAuditRecordsRequest{})
+	s.Require().NoError(err)
+	s.Require().Len(resp.Records, 2)
+	s.Require().Equal(time.Unix(2, 0).UTC(), resp.Records[0].Time)
+	s.Require().Equal(time.Unix(0, 0).UTC(), resp.Records[1].Time)
+}
+
+func (s *FileAdapterSuite) TestReadSortsByTime() {
+	// Commands complete in a different order than they start.
+	for _, sec := range []int64{2, 1, 4, 3} {
+		s.Require().NoError(s.adapter.WriteAuditRecord(s.ctx, &core.AuditRecord{
+			Time:        time.Unix(sec, 0).UTC(),
+			CommandName: "reboot",
+		}))
+	}
+
+	resp, err := s.adapter.ReadAuditRecords(s.ctx, &core.ReadAuditRecordsRequest{
+		Limit: 3,
+	})
+	s.Require().NoError(err)
+	s.Require().Len(resp.Records, 3)
+	s.Require().Equal(time.Unix(4, 0).UTC(), resp.Records[0].Time)
+	s.Require().Equal(time.Unix(3, 0).UTC(), resp.Records[1].Time)
+	s.Require().Equal(time.Unix(2, 0).UTC(), resp.Records[2].Time)
+}
+
+func TestFileAdapter(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(FileAdapterSuite))
+}
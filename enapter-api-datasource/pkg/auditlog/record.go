@@ -0,0 +1,57 @@
+package auditlog
+
+import (
+	"time"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+// record is the JSON representation of an audit record shared by all sinks.
+type record struct {
+	Time             time.Time      `json:"time"`
+	GrafanaUserLogin string         `json:"grafana_user_login,omitempty"`
+	GrafanaUserEmail string         `json:"grafana_user_email,omitempty"`
+	EnapterUser      string         `json:"enapter_user,omitempty"`
+	DeviceID         string         `json:"device_id,omitempty"`
+	HardwareID       string         `json:"hardware_id,omitempty"`
+	CommandName      string         `json:"command_name"`
+	CommandArgs      map[string]any `json:"command_args,omitempty"`
+	State            string         `json:"state,omitempty"`
+	Payload          map[string]any `json:"payload,omitempty"`
+	Error            string         `json:"error,omitempty"`
+	LatencyMS        float64        `json:"latency_ms"`
+}
+
+func newRecord(r *core.AuditRecord) *record {
+	return &record{
+		Time:             r.Time.UTC(),
+		GrafanaUserLogin: r.GrafanaUserLogin,
+		GrafanaUserEmail: r.GrafanaUserEmail,
+		EnapterUser:      r.EnapterUser,
+		DeviceID:         r.DeviceID,
+		HardwareID:       r.HardwareID,
+		CommandName:      r.CommandName,
+		CommandArgs:      r.CommandArgs,
+		State:            r.State,
+		Payload:          r.Payload,
+		Error:            r.Error,
+		LatencyMS:        float64(r.Latency) / float64(time.Millisecond),
+	}
+}
+
+func (r *record) toCore() *core.AuditRecord {
+	return &core.AuditRecord{
+		Time:             r.Time,
+		GrafanaUserLogin: r.GrafanaUserLogin,
+		GrafanaUserEmail: r.GrafanaUserEmail,
+		EnapterUser:      r.EnapterUser,
+		DeviceID:         r.DeviceID,
+		HardwareID:       r.HardwareID,
+		CommandName:      r.CommandName,
+		CommandArgs:      r.CommandArgs,
+		State:            r.State,
+		Payload:          r.Payload,
+		Error:            r.Error,
+		Latency:          time.Duration(r.LatencyMS * float64(time.Millisecond)),
+	}
+}
@@ -0,0 +1,109 @@
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	httputil "github.com/Enapter/grafana-plugins/pkg/http/util"
+)
+
+var _ core.AuditLogPort = (*WebhookAdapter)(nil)
+
+const defaultWebhookTimeout = 5 * time.Second
+
+type WebhookAdapterParams struct {
+	URL   string
+	Token string
+	// Timeout bounds every write, which delays the response to the audited
+	// command.
+	Timeout time.Duration
+	// Transport is optional.
+	Transport http.RoundTripper
+}
+
+// WebhookAdapter sends every audit record as a JSON object in the body of
+// a POST request.
+type WebhookAdapter struct {
+	url        string
+	token      string
+	timeout    time.Duration
+	httpClient http.Client
+}
+
+func NewWebhookAdapter(p WebhookAdapterParams) (*WebhookAdapter, error) {
+	if p.URL == "" {
+		return nil, errWebhookURLEmptyOrMissing
+	}
+	if p.Timeout == 0 {
+		p.Timeout = defaultWebhookTimeout
+	}
+	return &WebhookAdapter{
+		url:     p.URL,
+		token:   p.Token,
+		timeout: p.Timeout,
+		httpClient: http.Client{
+			Transport: p.Transport,
+		},
+	}, nil
+}
+
+func (a *WebhookAdapter) WriteAuditRecord(
+	ctx context.Context, r *core.AuditRecord,
+) (retErr error) {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(newRecord(r)); err != nil {
+		return fmt.Errorf("marshal record: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, body)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+	req.Header["Content-Type"] = []string{"application/json"}
+	if a.token != "" {
+		req.Header["Authorization"] = []string{"Bearer " + a.token}
+	}
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
+	}
+	defer func() {
+		if err := httputil.DrainAndClose(resp.Body); err != nil {
+			if retErr == nil {
+				retErr = err
+			}
+		}
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return a.processUnexpectedStatus(resp)
+	}
+
+	return nil
+}
+
+func (a *WebhookAdapter) ReadAuditRecords(
+	context.Context, *core.ReadAuditRecordsRequest,
+) (*core.ReadAuditRecordsResponse, error) {
+	return nil, core.ErrAuditLogNotReadable
+}
+
+func (a *WebhookAdapter) processUnexpectedStatus(resp *http.Response) error {
+	dump, err := httputil.DumpBody(resp.Body)
+	if err != nil {
+		//nolint:errorlint // two errors
+		return fmt.Errorf("%w: %s: body dump: <not available>: %v",
+			errUnexpectedStatus, resp.Status, err)
+	}
+	return fmt.Errorf("%w: %s: body dump: %s",
+		errUnexpectedStatus, resp.Status, dump)
+}
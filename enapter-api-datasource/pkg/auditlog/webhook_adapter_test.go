@@ -0,0 +1,80 @@
+package auditlog_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Enapter/grafana-plugins/pkg/auditlog"
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+type countingTransport struct {
+	requests atomic.Int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests.Add(1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestWebhookAdapter(t *testing.T) {
+	t.Parallel()
+
+	t.Run("should send record through transport", func(t *testing.T) {
+		t.Parallel()
+
+		var received map[string]any
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+			}))
+		defer server.Close()
+
+		transport := new(countingTransport)
+		adapter, err := auditlog.NewWebhookAdapter(auditlog.WebhookAdapterParams{
+			URL:       server.URL,
+			Token:     "secret",
+			Transport: transport,
+		})
+		require.NoError(t, err)
+
+		err = adapter.WriteAuditRecord(context.Background(), &core.AuditRecord{
+			CommandName: "reboot",
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(1), transport.requests.Load())
+		require.Equal(t, "reboot", received["command_name"])
+	})
+
+	t.Run("should give up after timeout", func(t *testing.T) {
+		t.Parallel()
+
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(
+			func(_ http.ResponseWriter, r *http.Request) {
+				select {
+				case <-r.Context().Done():
+				case <-release:
+				}
+			}))
+		defer server.Close()
+		defer close(release)
+
+		adapter, err := auditlog.NewWebhookAdapter(auditlog.WebhookAdapterParams{
+			URL:     server.URL,
+			Timeout: 10 * time.Millisecond,
+		})
+		require.NoError(t, err)
+
+		err = adapter.WriteAuditRecord(context.Background(), &core.AuditRecord{})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
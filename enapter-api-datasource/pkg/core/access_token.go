@@ -0,0 +1,38 @@
+package core
+
+import (
+	"context"
+	"strings"
+)
+
+type accessTokenContextKey struct{}
+
+// AccessTokenFromContext returns the OAuth access token of the Grafana user
+// on whose behalf Enapter API is called. Adapters must use it instead of
+// the data source credentials when it is not empty.
+func AccessTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(accessTokenContextKey{}).(string)
+	return token
+}
+
+// withAccessToken takes the access token from the Authorization header
+// forwarded by Grafana. It does nothing unless forwarding is enabled.
+func (d *DataSource) withAccessToken(
+	ctx context.Context, authorization string,
+) (context.Context, error) {
+	if !d.forwardOAuthToken {
+		return ctx, nil
+	}
+
+	const bearerPrefix = "Bearer "
+	token := authorization
+	if len(token) >= len(bearerPrefix) &&
+		strings.EqualFold(token[:len(bearerPrefix)], bearerPrefix) {
+		token = token[len(bearerPrefix):]
+	}
+	if token == "" {
+		return nil, ErrOAuthTokenMissing
+	}
+
+	return context.WithValue(ctx, accessTokenContextKey{}, token), nil
+}
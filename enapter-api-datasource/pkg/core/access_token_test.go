@@ -0,0 +1,49 @@
+package core_test
+
+import (
+	"context"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestForwardOAuthToken() {
+	defer s.useDataSourceWithForwardOAuthToken()()
+
+	// The user is not resolved, because the token identifies them.
+	req := s.randomDataRequestWithSingleTelemetryQuery()
+	req.headers = map[string]string{
+		backend.OAuthIdentityTokenHeaderName: "Bearer user-token",
+	}
+	s.mockEnapterAPIAdapter.ExpectQueryTimeseriesCheckContextAndReturn(
+		&core.QueryTimeseriesRequest{
+			Query: s.queryTextWithTimeRange(req.queries[0]),
+		}, func(ctx context.Context) {
+			s.Require().Equal("user-token", core.AccessTokenFromContext(ctx))
+		}, nil, core.ErrTimeseriesEmpty)
+
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+}
+
+func (s *DataSourceSuite) TestForwardOAuthTokenMissing() {
+	defer s.useDataSourceWithForwardOAuthToken()()
+
+	req := s.randomDataRequestWithSingleTelemetryQuery()
+	resp := s.handleDataRequest(req)[req.queries[0].refID]
+	s.Require().Equal(core.ErrOAuthTokenMissing, resp.Error)
+	s.Require().Equal(backend.StatusUnauthorized, resp.Status)
+}
+
+func (s *DataSourceSuite) useDataSourceWithForwardOAuthToken() (restore func()) {
+	orig := s.dataSource
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:            s.logger,
+		EnapterAPI:        s.mockEnapterAPIAdapter,
+		UserResolver:      s.mockUserResolver,
+		AuditLog:          s.mockAuditLog,
+		ForwardOAuthToken: true,
+	})
+	return func() { s.dataSource = orig }
+}
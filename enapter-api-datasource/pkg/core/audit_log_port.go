@@ -0,0 +1,40 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+type AuditLogPort interface {
+	WriteAuditRecord(context.Context, *AuditRecord) error
+	ReadAuditRecords(
+		context.Context, *ReadAuditRecordsRequest,
+	) (*ReadAuditRecordsResponse, error)
+}
+
+type AuditRecord struct {
+	Time             time.Time
+	GrafanaUserLogin string
+	GrafanaUserEmail string
+	EnapterUser      string
+	DeviceID         string
+	HardwareID       string
+	CommandName      string
+	CommandArgs      map[string]any
+	State            string
+	Payload          map[string]any
+	Error            string
+	Latency          time.Duration
+}
+
+type ReadAuditRecordsRequest struct {
+	From     time.Time
+	To       time.Time
+	DeviceID string
+	Limit    int
+}
+
+type ReadAuditRecordsResponse struct {
+	// Records are sorted from the newest to the oldest.
+	Records []*AuditRecord
+}
@@ -0,0 +1,235 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//nolint:tagliatelle // js
+type deviceSelector struct {
+	SiteID      string `json:"siteId"`
+	BlueprintID string `json:"blueprintId"`
+}
+
+const (
+	defaultBulkCommandConcurrency = 4
+	maxBulkCommandConcurrency     = 16
+)
+
+const bulkCommandStateSkipped = "skipped"
+
+type bulkCommandResult struct {
+	deviceID string
+	resp     *ExecuteCommandResponse
+	err      error
+	skipped  bool
+}
+
+func (d *DataSource) handleBulkCommandQuery(
+	ctx context.Context, r *requester, p *commandQueryPayload,
+) (data.Frames, error) {
+	// Devices are selected by device ID only.
+	if p.HardwareID != "" {
+		return nil, ErrBulkCommandHardwareID
+	}
+
+	// The devices are authorized one by one as the command is executed, but
+	// the rest of the policy is enforced before the devices are listed.
+	if err := d.commandPolicy.authorizeCommand(r.grafanaUser, p.CommandName); err != nil {
+		err = fmt.Errorf("authorize: %w", err)
+		d.auditCommand(ctx, r, &ExecuteCommandRequest{
+			User:        r.enapterUser,
+			CommandName: p.CommandName,
+			CommandArgs: p.CommandArgs,
+		}, time.Now(), nil, err)
+		return nil, err
+	}
+
+	deviceIDs, err := d.selectDevices(ctx, r, p)
+	if err != nil {
+		return nil, fmt.Errorf("select devices: %w", err)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.Int("device_count", len(deviceIDs)))
+
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBulkCommandConcurrency
+	}
+	concurrency = min(concurrency, maxBulkCommandConcurrency)
+
+	results := d.executeBulkCommand(ctx, r, p, deviceIDs, concurrency)
+
+	frame, err := d.bulkCommandResultsToDataFrame(results)
+	if err != nil {
+		return nil, fmt.Errorf("convert bulk cmd results to data frame: %w", err)
+	}
+
+	return data.Frames{frame}, nil
+}
+
+func (d *DataSource) selectDevices(
+	ctx context.Context, r *requester, p *commandQueryPayload,
+) ([]string, error) {
+	seen := make(map[string]struct{})
+	var deviceIDs []string
+	add := func(id string) {
+		if _, ok := seen[id]; ok || id == "" {
+			return
+		}
+		seen[id] = struct{}{}
+		deviceIDs = append(deviceIDs, id)
+	}
+
+	add(p.DeviceID)
+	for _, id := range p.DeviceIDs {
+		add(id)
+	}
+
+	if sel := p.Selector; sel != nil {
+		resp, err := d.enapterAPI.ListDevices(ctx, &ListDevicesRequest{
+			User:        r.enapterUser,
+			SiteID:      sel.SiteID,
+			BlueprintID: sel.BlueprintID,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list devices: %w", err)
+		}
+		for _, device := range resp.Devices {
+			add(device.ID)
+		}
+	}
+
+	if len(deviceIDs) == 0 {
+		return nil, ErrNoDevicesSelected
+	}
+
+	return deviceIDs, nil
+}
+
+func (d *DataSource) executeBulkCommand(
+	ctx context.Context, r *requester, p *commandQueryPayload,
+	deviceIDs []string, concurrency int,
+) []bulkCommandResult {
+	results := make([]bulkCommandResult, len(deviceIDs))
+
+	// Stopping on failure prevents new executions from being started, but
+	// lets the ones in flight complete.
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, deviceID := range deviceIDs {
+		results[i].deviceID = deviceID
+
+		acquired := false
+		select {
+		case sem <- struct{}{}:
+			acquired = true
+		case <-stop:
+		case <-ctx.Done():
+		}
+		if !acquired || isClosed(stop) || ctx.Err() != nil {
+			if acquired {
+				<-sem
+			}
+			results[i].skipped = true
+			continue
+		}
+
+		wg.Add(1)
+		go func(res *bulkCommandResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			req := &ExecuteCommandRequest{
+				User:        r.enapterUser,
+				CommandName: p.CommandName,
+				CommandArgs: p.CommandArgs,
+				DeviceID:    res.deviceID,
+			}
+
+			res.resp, res.err = d.executeCommand(ctx, r, req, p)
+
+			if p.StopOnFailure && !res.succeeded(p.Async) {
+				stopOnce.Do(func() { close(stop) })
+			}
+		}(&results[i])
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// succeeded also accepts a started execution of an asynchronous command,
+// whose outcome is not awaited.
+func (r *bulkCommandResult) succeeded(async bool) bool {
+	if r.err != nil || r.resp == nil {
+		return false
+	}
+	return r.resp.State == "succeeded" ||
+		async && r.resp.State == commandExecutionStateStarted
+}
+
+func (d *DataSource) bulkCommandResultsToDataFrame(
+	results []bulkCommandResult,
+) (*data.Frame, error) {
+	n := len(results)
+	var (
+		deviceIDs     = make([]string, n)
+		states        = make([]string, n)
+		payloads      = make([]json.RawMessage, n)
+		errorMessages = make([]string, n)
+	)
+
+	for i, res := range results {
+		deviceIDs[i] = res.deviceID
+
+		var payload map[string]any
+
+		switch {
+		case res.skipped:
+			states[i] = bulkCommandStateSkipped
+		case res.err != nil:
+			d.logger.Warn("failed to execute bulk command",
+				"device_id", res.deviceID,
+				"error", res.err)
+			errorMessages[i] = d.userFacingError(res.err).Error()
+		default:
+			states[i] = res.resp.State
+			payload = res.resp.Payload
+		}
+
+		payloadBytes, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("device %s: payload: %w", res.deviceID, err)
+		}
+		payloads[i] = payloadBytes
+	}
+
+	return data.NewFrame("",
+		data.NewField("device_id", nil, deviceIDs),
+		data.NewField("state", nil, states),
+		data.NewField("payload", nil, payloads),
+		data.NewField("error", nil, errorMessages),
+	), nil
+}
@@ -0,0 +1,202 @@
+package core_test
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/bxcodec/faker/v3"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestBulkCommandDeviceIDs() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	req.queries[0].payload["deviceIds"] = []string{"a", "b", "c"}
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+
+	var mu sync.Mutex
+	executed := make(map[string]bool)
+	defer s.mockEnapterAPIAdapter.ExpectExecuteCommandsAndHandle(
+		func(r *core.ExecuteCommandRequest) (*core.ExecuteCommandResponse, error) {
+			mu.Lock()
+			executed[r.DeviceID] = true
+			mu.Unlock()
+			if r.DeviceID == "b" {
+				return nil, errFake
+			}
+			return &core.ExecuteCommandResponse{
+				State:   "succeeded",
+				Payload: map[string]any{"device": r.DeviceID},
+			}, nil
+		})()
+	defer s.mockAuditLog.ExpectWriteAuditRecords()()
+
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+
+	deviceID := req.queries[0].payload["deviceId"].(string)
+	rows := s.extractBulkCommandRows(frames)
+	s.Require().Len(rows, 4)
+	s.Require().Equal(bulkCommandRow{
+		deviceID: deviceID,
+		state:    "succeeded",
+		payload:  map[string]any{"device": deviceID},
+	}, rows[0])
+	s.Require().Equal(bulkCommandRow{
+		deviceID: "a",
+		state:    "succeeded",
+		payload:  map[string]any{"device": "a"},
+	}, rows[1])
+	s.Require().Equal(bulkCommandRow{
+		deviceID: "b",
+		errorMsg: core.ErrSomethingWentWrong.Error(),
+	}, rows[2])
+	s.Require().Equal("c", rows[3].deviceID)
+	s.Require().Len(executed, 4)
+}
+
+func (s *DataSourceSuite) TestBulkCommandStopOnFailure() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	delete(req.queries[0].payload, "deviceId")
+	req.queries[0].payload["deviceIds"] = []string{"a", "b", "c"}
+	req.queries[0].payload["concurrency"] = 1
+	req.queries[0].payload["stopOnFailure"] = true
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+
+	defer s.mockEnapterAPIAdapter.ExpectExecuteCommandsAndHandle(
+		func(r *core.ExecuteCommandRequest) (*core.ExecuteCommandResponse, error) {
+			s.Require().NotEqual("c", r.DeviceID)
+			return &core.ExecuteCommandResponse{State: "error"}, nil
+		})()
+	defer s.mockAuditLog.ExpectWriteAuditRecords()()
+
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+
+	rows := s.extractBulkCommandRows(frames)
+	s.Require().Len(rows, 3)
+	s.Require().Equal("a", rows[0].deviceID)
+	s.Require().Equal("error", rows[0].state)
+	s.Require().Equal("c", rows[2].deviceID)
+	s.Require().Equal("skipped", rows[2].state)
+}
+
+func (s *DataSourceSuite) TestBulkCommandAsyncStopOnFailure() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	delete(req.queries[0].payload, "deviceId")
+	req.queries[0].payload["deviceIds"] = []string{"a", "b", "c"}
+	req.queries[0].payload["concurrency"] = 1
+	req.queries[0].payload["stopOnFailure"] = true
+	req.queries[0].payload["async"] = true
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+
+	defer s.mockEnapterAPIAdapter.ExpectStartCommandExecutionsAndHandle(
+		func(r *core.ExecuteCommandRequest) (*core.StartCommandExecutionResponse, error) {
+			return &core.StartCommandExecutionResponse{
+				DeviceID:    r.DeviceID,
+				ExecutionID: faker.UUIDHyphenated(),
+			}, nil
+		})()
+	defer s.mockAuditLog.ExpectWriteAuditRecords()()
+
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+
+	// Started executions do not stop the command.
+	rows := s.extractBulkCommandRows(frames)
+	s.Require().Len(rows, 3)
+	for _, row := range rows {
+		s.Require().Equal("started", row.state)
+	}
+}
+
+func (s *DataSourceSuite) TestBulkCommandSelector() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	delete(req.queries[0].payload, "deviceId")
+	siteID := faker.UUIDHyphenated()
+	req.queries[0].payload["selector"] = map[string]any{"siteId": siteID}
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockEnapterAPIAdapter.ExpectListDevicesAndReturn(&core.ListDevicesRequest{
+		User:   req.user,
+		SiteID: siteID,
+	}, &core.ListDevicesResponse{
+		Devices: []core.Device{{ID: "x"}},
+	}, nil)
+	s.expectExecuteCommandAndReturn(dataRequest{
+		user: req.user,
+		queries: []query{{payload: map[string]any{
+			"commandName": req.queries[0].payload["commandName"],
+			"commandArgs": req.queries[0].payload["commandArgs"],
+			"deviceId":    "x",
+		}}},
+	}, &core.ExecuteCommandResponse{State: "succeeded"}, nil)
+	defer s.mockAuditLog.ExpectWriteAuditRecords()()
+
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+
+	rows := s.extractBulkCommandRows(frames)
+	s.Require().Len(rows, 1)
+	s.Require().Equal("x", rows[0].deviceID)
+	s.Require().Equal("succeeded", rows[0].state)
+}
+
+func (s *DataSourceSuite) TestBulkCommandSelectorNoDevices() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	delete(req.queries[0].payload, "deviceId")
+	req.queries[0].payload["selector"] = map[string]any{"blueprintId": "bp"}
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockEnapterAPIAdapter.ExpectListDevicesAndReturn(&core.ListDevicesRequest{
+		User:        req.user,
+		BlueprintID: "bp",
+	}, &core.ListDevicesResponse{}, nil)
+
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrNoDevicesSelected)
+}
+
+func (s *DataSourceSuite) TestBulkCommandHardwareID() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	req.queries[0].payload["deviceIds"] = []string{"a"}
+	req.queries[0].payload["hardwareId"] = faker.UUIDHyphenated()
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrBulkCommandHardwareID)
+}
+
+func (s *DataSourceSuite) TestBulkCommandForbiddenBeforeSelector() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	delete(req.queries[0].payload, "deviceId")
+	req.queries[0].payload["selector"] = map[string]any{"siteId": faker.UUIDHyphenated()}
+
+	// No devices are listed.
+	err := s.handleCommandRequestWithPolicy(req, core.CommandPolicy{
+		MinRole: core.RoleEditor,
+	}, "Viewer", false)
+	s.Require().ErrorIs(err, core.ErrCommandForbidden)
+}
+
+type bulkCommandRow struct {
+	deviceID string
+	state    string
+	payload  map[string]any
+	errorMsg string
+}
+
+func (s *DataSourceSuite) extractBulkCommandRows(frames data.Frames) []bulkCommandRow {
+	s.Require().Len(frames, 1)
+	frame := frames[0]
+
+	rows := make([]bulkCommandRow, frame.Rows())
+	for i := range rows {
+		rows[i].deviceID = frame.Fields[0].At(i).(string)
+		rows[i].state = frame.Fields[1].At(i).(string)
+		err := json.Unmarshal(frame.Fields[2].At(i).(json.RawMessage), &rows[i].payload)
+		s.Require().NoError(err)
+		rows[i].errorMsg = frame.Fields[3].At(i).(string)
+	}
+
+	return rows
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// CircuitBreaker configures failing fast while Enapter API is unavailable.
+// The zero value disables it.
+type CircuitBreaker struct {
+	// FailureThreshold is the number of consecutive failures opening the
+	// breaker.
+	FailureThreshold int
+	// Cooldown is how long the breaker stays open before a trial request
+	// is let through.
+	Cooldown time.Duration
+}
+
+type CircuitBreakerState string
+
+const (
+	CircuitBreakerStateClosed   CircuitBreakerState = "closed"
+	CircuitBreakerStateOpen     CircuitBreakerState = "open"
+	CircuitBreakerStateHalfOpen CircuitBreakerState = "half-open"
+)
+
+// circuitBreaker decorates EnapterAPIPort. Only failures telling that the
+// API is unreachable or broken are counted, so that invalid queries do not
+// open the breaker. Only responses of the API close it, so that errors
+// raised before the API is called change nothing.
+type circuitBreaker struct {
+	EnapterAPIPort
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitBreakerState
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+func newCircuitBreaker(api EnapterAPIPort, p CircuitBreaker) *circuitBreaker {
+	return &circuitBreaker{
+		EnapterAPIPort: api,
+		threshold:      p.FailureThreshold,
+		cooldown:       p.Cooldown,
+		now:            time.Now,
+		state:          CircuitBreakerStateClosed,
+	}
+}
+
+// State returns the current state along with the number of consecutive
+// failures.
+func (b *circuitBreaker) State() (CircuitBreakerState, int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitBreakerStateOpen && b.cooledDown() {
+		return CircuitBreakerStateHalfOpen, b.failures
+	}
+	return b.state, b.failures
+}
+
+func (b *circuitBreaker) cooledDown() bool {
+	return !b.now().Before(b.openedAt.Add(b.cooldown))
+}
+
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case CircuitBreakerStateClosed:
+		return nil
+	case CircuitBreakerStateOpen:
+		if !b.cooledDown() {
+			return ErrEnapterAPIUnavailable
+		}
+		b.state = CircuitBreakerStateHalfOpen
+	}
+
+	// Only a single trial request is let through while half-open.
+	if b.trial {
+		return ErrEnapterAPIUnavailable
+	}
+	b.trial = true
+	return nil
+}
+
+func (b *circuitBreaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+
+	// A request cancelled by the caller tells nothing about the API, nor
+	// does one timed out by a short timeout requested by the query. The
+	// expiry of a configured timeout is a failure though.
+	if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) ||
+		(ctx.Err() != nil && hasQueryTimeout(ctx)) {
+		return
+	}
+
+	if !isEnapterAPIUnavailable(err) {
+		if isEnapterAPIResponse(err) {
+			b.state = CircuitBreakerStateClosed
+			b.failures = 0
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitBreakerStateHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitBreakerStateOpen
+		b.openedAt = b.now()
+	}
+}
+
+func isEnapterAPIUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	status, source := classifyError(err)
+	return source == ErrorSourceDownstream &&
+		(status == backend.StatusBadGateway || status == backend.StatusTimeout)
+}
+
+// isEnapterAPIResponse tells whether the API has answered the request.
+func isEnapterAPIResponse(err error) bool {
+	if err == nil || errors.Is(err, ErrTimeseriesEmpty) {
+		return true
+	}
+	var apiErr EnapterAPIError
+	if errors.As(err, &apiErr) {
+		return true
+	}
+	var coder httpStatusCoder
+	return errors.As(err, &coder) && coder.HTTPStatusCode() != 0
+}
+
+func circuitBreakerCall[Req, Resp any](
+	ctx context.Context, b *circuitBreaker, req Req,
+	fn func(context.Context, Req) (Resp, error),
+) (Resp, error) {
+	if err := b.allow(); err != nil {
+		var zero Resp
+		return zero, fmt.Errorf("circuit breaker: %w", err)
+	}
+	resp, err := fn(ctx, req)
+	b.record(ctx, err)
+	return resp, err
+}
+
+func (b *circuitBreaker) QueryTimeseries(
+	ctx context.Context, req *QueryTimeseriesRequest,
+) (*QueryTimeseriesResponse, error) {
+	return circuitBreakerCall(ctx, b, req, b.EnapterAPIPort.QueryTimeseries)
+}
+
+func (b *circuitBreaker) ExecuteCommand(
+	ctx context.Context, req *ExecuteCommandRequest,
+) (*ExecuteCommandResponse, error) {
+	return circuitBreakerCall(ctx, b, req, b.EnapterAPIPort.ExecuteCommand)
+}
+
+func (b *circuitBreaker) GetDeviceManifest(
+	ctx context.Context, req *GetDeviceManifestRequest,
+) (*GetDeviceManifestResponse, error) {
+	return circuitBreakerCall(ctx, b, req, b.EnapterAPIPort.GetDeviceManifest)
+}
+
+func (b *circuitBreaker) ListDevices(
+	ctx context.Context, req *ListDevicesRequest,
+) (*ListDevicesResponse, error) {
+	return circuitBreakerCall(ctx, b, req, b.EnapterAPIPort.ListDevices)
+}
+
+func (b *circuitBreaker) StartCommandExecution(
+	ctx context.Context, req *ExecuteCommandRequest,
+) (*StartCommandExecutionResponse, error) {
+	return circuitBreakerCall(ctx, b, req, b.EnapterAPIPort.StartCommandExecution)
+}
+
+func (b *circuitBreaker) GetCommandExecution(
+	ctx context.Context, req *GetCommandExecutionRequest,
+) (*GetCommandExecutionResponse, error) {
+	return circuitBreakerCall(ctx, b, req, b.EnapterAPIPort.GetCommandExecution)
+}
+
+func (b *circuitBreaker) ResolveDeviceID(
+	ctx context.Context, req *ResolveDeviceIDRequest,
+) (*ResolveDeviceIDResponse, error) {
+	return circuitBreakerCall(ctx, b, req, b.EnapterAPIPort.ResolveDeviceID)
+}
@@ -0,0 +1,179 @@
+package core_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestCircuitBreakerOpens() {
+	defer s.useDataSourceWithCircuitBreaker(core.CircuitBreaker{
+		FailureThreshold: 2,
+		Cooldown:         time.Hour,
+	})()
+
+	unavailable := core.EnapterAPIError{
+		Code:       "oops",
+		StatusCode: http.StatusServiceUnavailable,
+	}
+	for i := 0; i < 2; i++ {
+		resp := s.handleTelemetryQueryFailedWith(unavailable)
+		s.Require().Equal(backend.StatusBadGateway, resp.Status)
+	}
+
+	// The API must not be called once the breaker is open.
+	req := s.randomDataRequestWithSingleTelemetryQuery()
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().Equal(core.ErrEnapterAPIUnavailable, err)
+
+	result, err := s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Contains(result.Message, "circuit breaker: open")
+}
+
+func (s *DataSourceSuite) TestCircuitBreakerIgnoresQueryErrors() {
+	defer s.useDataSourceWithCircuitBreaker(core.CircuitBreaker{
+		FailureThreshold: 1,
+		Cooldown:         time.Hour,
+	})()
+
+	resp := s.handleTelemetryQueryFailedWith(core.EnapterAPIError{
+		Code:       "oops",
+		StatusCode: http.StatusUnprocessableEntity,
+	})
+	s.Require().Equal(backend.StatusValidationFailed, resp.Status)
+
+	result, err := s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Contains(result.Message, "circuit breaker: closed")
+}
+
+func (s *DataSourceSuite) TestCircuitBreakerHalfOpens() {
+	const cooldown = 10 * time.Millisecond
+	defer s.useDataSourceWithCircuitBreaker(core.CircuitBreaker{
+		FailureThreshold: 1,
+		Cooldown:         cooldown,
+	})()
+
+	s.handleTelemetryQueryFailedWith(core.EnapterAPIError{
+		Code:       "oops",
+		StatusCode: http.StatusBadGateway,
+	})
+	time.Sleep(cooldown)
+
+	result, err := s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Contains(result.Message, "circuit breaker: half-open")
+
+	// A successful trial request closes the breaker.
+	req := s.randomDataRequestWithSingleTelemetryQuery()
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectQueryTimeseriesAndReturn(req, nil, core.ErrTimeseriesEmpty)
+	_, err = s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+
+	result, err = s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Contains(result.Message, "circuit breaker: closed")
+}
+
+func (s *DataSourceSuite) TestCircuitBreakerIgnoresLocalErrors() {
+	const cooldown = 10 * time.Millisecond
+	defer s.useDataSourceWithCircuitBreaker(core.CircuitBreaker{
+		FailureThreshold: 1,
+		Cooldown:         cooldown,
+	})()
+
+	s.handleTelemetryQueryFailedWith(core.EnapterAPIError{
+		Code:       "oops",
+		StatusCode: http.StatusBadGateway,
+	})
+	time.Sleep(cooldown)
+
+	// The trial request has failed before reaching the API.
+	resp := s.handleTelemetryQueryFailedWith(
+		fmt.Errorf("rate limit: %w", core.ErrRateLimitExceeded))
+	s.Require().Equal(backend.StatusTooManyRequests, resp.Status)
+
+	result, err := s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Contains(result.Message, "circuit breaker: half-open")
+}
+
+func (s *DataSourceSuite) TestCircuitBreakerIgnoresQueryTimeouts() {
+	defer s.useDataSourceWithCircuitBreakerAndTimeouts(core.CircuitBreaker{
+		FailureThreshold: 1,
+		Cooldown:         time.Hour,
+	}, core.Timeouts{Default: time.Minute})()
+
+	// The query has asked for less than the default timeout.
+	resp := s.handleTelemetryQueryTimedOut(s.dataRequestWithTimeout("1ms"))
+	s.Require().Equal(backend.StatusTimeout, resp.Status)
+
+	result, err := s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Contains(result.Message, "circuit breaker: closed, consecutive failures: 0")
+}
+
+func (s *DataSourceSuite) TestCircuitBreakerCountsDefaultTimeouts() {
+	defer s.useDataSourceWithCircuitBreakerAndTimeouts(core.CircuitBreaker{
+		FailureThreshold: 1,
+		Cooldown:         time.Hour,
+	}, core.Timeouts{Default: time.Millisecond})()
+
+	resp := s.handleTelemetryQueryTimedOut(s.dataRequestWithTimeout(""))
+	s.Require().Equal(backend.StatusTimeout, resp.Status)
+
+	result, err := s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Contains(result.Message, "circuit breaker: open")
+}
+
+// handleTelemetryQueryTimedOut makes the API hang until the query context
+// expires.
+func (s *DataSourceSuite) handleTelemetryQueryTimedOut(req dataRequest) backend.DataResponse {
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockEnapterAPIAdapter.ExpectQueryTimeseriesCheckContextAndReturn(
+		&core.QueryTimeseriesRequest{
+			User:  req.user,
+			Query: s.queryTextWithTimeRange(req.queries[0]),
+		}, func(ctx context.Context) {
+			<-ctx.Done()
+		}, nil, context.DeadlineExceeded)
+	return s.handleDataRequest(req)[req.queries[0].refID]
+}
+
+func (s *DataSourceSuite) useDataSourceWithCircuitBreaker(
+	breaker core.CircuitBreaker,
+) (restore func()) {
+	orig := s.dataSource
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:         s.logger,
+		EnapterAPI:     s.mockEnapterAPIAdapter,
+		UserResolver:   s.mockUserResolver,
+		AuditLog:       s.mockAuditLog,
+		CircuitBreaker: breaker,
+	})
+	return func() { s.dataSource = orig }
+}
+
+func (s *DataSourceSuite) useDataSourceWithCircuitBreakerAndTimeouts(
+	breaker core.CircuitBreaker, timeouts core.Timeouts,
+) (restore func()) {
+	orig := s.dataSource
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:         s.logger,
+		EnapterAPI:     s.mockEnapterAPIAdapter,
+		UserResolver:   s.mockUserResolver,
+		AuditLog:       s.mockAuditLog,
+		CircuitBreaker: breaker,
+		Timeouts:       timeouts,
+	})
+	return func() { s.dataSource = orig }
+}
@@ -0,0 +1,225 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// CommandDeduplication configures suppression of duplicated command
+// executions. The zero value disables it.
+type CommandDeduplication struct {
+	// IdempotencyKeyTTL is how long the results of commands executed with
+	// an idempotency key are remembered.
+	IdempotencyKeyTTL time.Duration
+	// DebounceInterval is the minimum interval between executions of the
+	// same non-idempotent command on the same device.
+	DebounceInterval time.Duration
+	// NonIdempotentCommands lists the names of the commands to debounce.
+	NonIdempotentCommands []string
+}
+
+type commandDeduplicator struct {
+	keyTTL           time.Duration
+	debounceInterval time.Duration
+	nonIdempotent    map[string]struct{}
+	now              func() time.Time
+
+	mu         sync.Mutex
+	executions map[string]*idempotentExecution
+	lastSent   map[string]time.Time
+}
+
+type idempotentExecution struct {
+	fingerprint string
+	done        chan struct{}
+	expiresAt   time.Time
+	resp        *ExecuteCommandResponse
+	err         error
+}
+
+func newCommandDeduplicator(p CommandDeduplication) *commandDeduplicator {
+	nonIdempotent := make(map[string]struct{}, len(p.NonIdempotentCommands))
+	for _, name := range p.NonIdempotentCommands {
+		nonIdempotent[name] = struct{}{}
+	}
+	return &commandDeduplicator{
+		keyTTL:           p.IdempotencyKeyTTL,
+		debounceInterval: p.DebounceInterval,
+		nonIdempotent:    nonIdempotent,
+		now:              time.Now,
+		executions:       make(map[string]*idempotentExecution),
+		lastSent:         make(map[string]time.Time),
+	}
+}
+
+// do calls fn unless the command has already been executed with the same
+// idempotency key. In that case the result of the original execution is
+// returned, waiting for it to complete if necessary.
+func (c *commandDeduplicator) do(
+	ctx context.Context, r *requester, req *ExecuteCommandRequest,
+	idempotencyKey string, fn func() (*ExecuteCommandResponse, error),
+) (resp *ExecuteCommandResponse, deduplicated bool, err error) {
+	if idempotencyKey == "" || c.keyTTL <= 0 {
+		resp, err = fn()
+		return resp, false, err
+	}
+
+	fingerprint, err := commandFingerprint(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("fingerprint command: %w", err)
+	}
+
+	key := idempotencyCacheKey(r, req, idempotencyKey)
+
+	for {
+		c.mu.Lock()
+		c.purgeExpiredExecutionsLocked()
+		if e, ok := c.executions[key]; ok {
+			c.mu.Unlock()
+			if e.fingerprint != fingerprint {
+				return nil, false, ErrIdempotencyKeyReused
+			}
+			select {
+			case <-e.done:
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			}
+			// The context of the caller which has actually executed the
+			// command may have been cancelled, which tells nothing about
+			// the command.
+			if isContextError(e.err) && ctx.Err() == nil {
+				continue
+			}
+			return e.resp, true, e.err
+		}
+		e := &idempotentExecution{
+			fingerprint: fingerprint,
+			done:        make(chan struct{}),
+		}
+		c.executions[key] = e
+		c.mu.Unlock()
+
+		resp, err = fn()
+
+		c.mu.Lock()
+		e.resp, e.err = resp, err
+		if isTransientCommandError(err) {
+			// The command may be sent again with the same key.
+			delete(c.executions, key)
+		} else {
+			e.expiresAt = c.now().Add(c.keyTTL)
+		}
+		close(e.done)
+		c.mu.Unlock()
+
+		return resp, false, err
+	}
+}
+
+// isTransientCommandError tells failures worth retrying apart from the
+// outcomes of commands, which are remembered.
+func isTransientCommandError(err error) bool {
+	if err == nil {
+		return false
+	}
+	switch status, _ := classifyError(err); status {
+	case statusClientClosedRequest, backend.StatusTimeout,
+		backend.StatusBadGateway, backend.StatusTooManyRequests:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *commandDeduplicator) purgeExpiredExecutionsLocked() {
+	now := c.now()
+	for key, e := range c.executions {
+		// Executions in flight have no expiration time yet.
+		if !e.expiresAt.IsZero() && now.After(e.expiresAt) {
+			delete(c.executions, key)
+		}
+	}
+}
+
+// debounces tells whether the command is debounced at all.
+func (c *commandDeduplicator) debounces(commandName string) bool {
+	if c.debounceInterval <= 0 {
+		return false
+	}
+	_, ok := c.nonIdempotent[commandName]
+	return ok
+}
+
+// debounce fails if the same non-idempotent command has been sent to the
+// device identified by deviceKey within the debounce interval. Otherwise
+// the command is held for the interval until the returned function is
+// called with the result of the send, which releases it if the send has
+// failed, so that the command may be retried.
+func (c *commandDeduplicator) debounce(
+	req *ExecuteCommandRequest, deviceKey string,
+) (sent func(error), err error) {
+	if !c.debounces(req.CommandName) {
+		return func(error) {}, nil
+	}
+
+	key := deviceKey + "\x00" + req.CommandName
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	for k, t := range c.lastSent {
+		if now.Sub(t) >= c.debounceInterval {
+			delete(c.lastSent, k)
+		}
+	}
+	if _, ok := c.lastSent[key]; ok {
+		return nil, ErrCommandDebounced
+	}
+	c.lastSent[key] = now
+
+	return func(err error) {
+		if err == nil {
+			return
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if t, ok := c.lastSent[key]; ok && t.Equal(now) {
+			delete(c.lastSent, key)
+		}
+	}, nil
+}
+
+// idempotencyCacheKey scopes the idempotency key to the requester and the
+// target device, so that different users and devices never share results.
+func idempotencyCacheKey(
+	r *requester, req *ExecuteCommandRequest, idempotencyKey string,
+) string {
+	var grafanaLogin string
+	if r.grafanaUser != nil {
+		grafanaLogin = r.grafanaUser.Login
+	}
+	return strings.Join([]string{
+		grafanaLogin, r.enapterUser, req.DeviceID, req.HardwareID, idempotencyKey,
+	}, "\x00")
+}
+
+func commandFingerprint(req *ExecuteCommandRequest) (string, error) {
+	b, err := json.Marshal(struct {
+		Name string         `json:"name"`
+		Args map[string]any `json:"args"`
+	}{
+		Name: req.CommandName,
+		Args: req.CommandArgs,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
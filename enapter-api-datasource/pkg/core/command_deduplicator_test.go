@@ -0,0 +1,196 @@
+package core_test
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bxcodec/faker/v3"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestCommandIdempotencyKey() {
+	defer s.useDataSourceWithDeduplication(core.CommandDeduplication{
+		IdempotencyKeyTTL: time.Minute,
+	})()
+
+	req := s.randomDataRequestWithSingleCommandQuery()
+	req.queries[0].payload["idempotencyKey"] = faker.UUIDHyphenated()
+	stateIn := faker.Word()
+
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, &core.ExecuteCommandResponse{
+		State: stateIn,
+	}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(
+		func(*core.AuditRecord) {}, nil)
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+	stateOut, _ := s.extractCommandResponse(frames)
+	s.Require().Equal(stateIn, stateOut)
+
+	// The duplicate is neither executed nor audited.
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	frames, err = s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+	stateOut, _ = s.extractCommandResponse(frames)
+	s.Require().Equal(stateIn, stateOut)
+}
+
+func (s *DataSourceSuite) TestCommandIdempotencyKeyRetriedAfterTransientError() {
+	defer s.useDataSourceWithDeduplication(core.CommandDeduplication{
+		IdempotencyKeyTTL: time.Minute,
+	})()
+
+	req := s.randomDataRequestWithSingleCommandQuery()
+	req.queries[0].payload["idempotencyKey"] = faker.UUIDHyphenated()
+
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, nil, core.EnapterAPIError{
+		Code:       "oops",
+		StatusCode: http.StatusServiceUnavailable,
+	})
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(
+		func(*core.AuditRecord) {}, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().Error(err)
+
+	// The failure is not replayed, the command is sent again.
+	stateIn := faker.Word()
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, &core.ExecuteCommandResponse{
+		State: stateIn,
+	}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(
+		func(*core.AuditRecord) {}, nil)
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+	stateOut, _ := s.extractCommandResponse(frames)
+	s.Require().Equal(stateIn, stateOut)
+}
+
+func (s *DataSourceSuite) TestCommandIdempotencyKeyReused() {
+	defer s.useDataSourceWithDeduplication(core.CommandDeduplication{
+		IdempotencyKeyTTL: time.Minute,
+	})()
+
+	req := s.randomDataRequestWithSingleCommandQuery()
+	req.queries[0].payload["idempotencyKey"] = faker.UUIDHyphenated()
+
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, &core.ExecuteCommandResponse{
+		State: faker.Word(),
+	}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(
+		func(*core.AuditRecord) {}, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+
+	req.queries[0].payload["commandName"] = "other_" + faker.Word()
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	_, err = s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrIdempotencyKeyReused)
+}
+
+func (s *DataSourceSuite) TestCommandDebounce() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	commandName := req.queries[0].payload["commandName"].(string)
+
+	defer s.useDataSourceWithDeduplication(core.CommandDeduplication{
+		DebounceInterval:      time.Minute,
+		NonIdempotentCommands: []string{commandName},
+	})()
+
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, &core.ExecuteCommandResponse{
+		State: faker.Word(),
+	}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(
+		func(*core.AuditRecord) {}, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(func(r *core.AuditRecord) {
+		s.Require().NotEmpty(r.Error)
+	}, nil)
+	_, err = s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrCommandDebounced)
+}
+
+func (s *DataSourceSuite) TestCommandDebounceByHardwareID() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	q := req.queries[0]
+	commandName := q.payload["commandName"].(string)
+	deviceID := q.payload["deviceId"].(string)
+
+	defer s.useDataSourceWithDeduplication(core.CommandDeduplication{
+		DebounceInterval:      time.Minute,
+		NonIdempotentCommands: []string{commandName},
+	})()
+
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, &core.ExecuteCommandResponse{
+		State: faker.Word(),
+	}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(
+		func(*core.AuditRecord) {}, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+
+	// The same device addressed by hardware ID.
+	hardwareID := faker.UUIDHyphenated()
+	delete(q.payload, "deviceId")
+	q.payload["hardwareId"] = hardwareID
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockEnapterAPIAdapter.ExpectResolveDeviceIDAndReturn(&core.ResolveDeviceIDRequest{
+		User:       req.user,
+		HardwareID: hardwareID,
+	}, &core.ResolveDeviceIDResponse{DeviceID: deviceID}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(func(r *core.AuditRecord) {
+		s.Require().NotEmpty(r.Error)
+	}, nil)
+	_, err = s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrCommandDebounced)
+}
+
+func (s *DataSourceSuite) TestCommandDebounceFailedSend() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	commandName := req.queries[0].payload["commandName"].(string)
+
+	defer s.useDataSourceWithDeduplication(core.CommandDeduplication{
+		DebounceInterval:      time.Minute,
+		NonIdempotentCommands: []string{commandName},
+	})()
+
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, nil, errFake)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(
+		func(*core.AuditRecord) {}, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().Error(err)
+
+	// The failed send does not hold the retry back.
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, &core.ExecuteCommandResponse{
+		State: faker.Word(),
+	}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(
+		func(*core.AuditRecord) {}, nil)
+	_, err = s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+}
+
+func (s *DataSourceSuite) useDataSourceWithDeduplication(
+	dedup core.CommandDeduplication,
+) (restore func()) {
+	orig := s.dataSource
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:               s.logger,
+		EnapterAPI:           s.mockEnapterAPIAdapter,
+		UserResolver:         s.mockUserResolver,
+		AuditLog:             s.mockAuditLog,
+		CommandDeduplication: dedup,
+	})
+	return func() { s.dataSource = orig }
+}
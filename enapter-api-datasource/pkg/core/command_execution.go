@@ -0,0 +1,341 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/resource/httpadapter"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+const (
+	commandExecutionsPath = "command_executions"
+
+	commandExecutionStateStarted = "started"
+	commandExecutionStateTimeout = "timeout"
+
+	commandExecutionPollInterval  = time.Second
+	commandExecutionStreamTimeout = 10 * time.Minute
+)
+
+// isCommandExecutionPending reports whether the execution may still change
+// its state.
+func isCommandExecutionPending(state string) bool {
+	switch state {
+	case "", "new", "pending", "in_progress":
+		return true
+	default:
+		return false
+	}
+}
+
+func (d *DataSource) startCommandExecution(
+	ctx context.Context, req *ExecuteCommandRequest,
+) (*ExecuteCommandResponse, error) {
+	resp, err := d.enapterAPI.StartCommandExecution(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("start command execution: %w", err)
+	}
+	return &ExecuteCommandResponse{
+		State: commandExecutionStateStarted,
+		Payload: map[string]any{
+			"device_id":    resp.DeviceID,
+			"execution_id": resp.ExecutionID,
+		},
+	}, nil
+}
+
+// commandExecutionChannel returns the Grafana Live channel publishing
+// state changes of the execution. Grafana runs a single stream per channel
+// on behalf of its first subscriber, so every user gets a channel of their
+// own.
+func commandExecutionChannel(
+	dataSourceUID string, user *backend.User, deviceID, executionID string,
+) string {
+	return path.Join("ds", dataSourceUID, commandExecutionsPath,
+		deviceID, executionID, streamUserKey(user))
+}
+
+type commandExecutionPath struct {
+	deviceID    string
+	executionID string
+	userKey     string
+}
+
+func parseCommandExecutionPath(p string) (commandExecutionPath, bool) {
+	parts := strings.Split(p, "/")
+	if len(parts) != 4 || parts[0] != commandExecutionsPath ||
+		parts[1] == "" || parts[2] == "" || parts[3] == "" {
+		return commandExecutionPath{}, false
+	}
+	return commandExecutionPath{
+		deviceID:    parts[1],
+		executionID: parts[2],
+		userKey:     parts[3],
+	}, true
+}
+
+// streamUserKey identifies the Grafana user in channel paths without
+// disclosing the login.
+func streamUserKey(user *backend.User) string {
+	var id string
+	if user != nil {
+		id = user.Login
+		if id == "" {
+			id = user.Email
+		}
+	}
+	sum := sha256.Sum256([]byte(id))
+	const keyLen = 16
+	return hex.EncodeToString(sum[:])[:keyLen]
+}
+
+func (d *DataSource) newResourceHandler() backend.CallResourceHandler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /"+commandExecutionsPath+"/{device_id}/{execution_id}",
+		d.handleGetCommandExecution)
+	return httpadapter.New(mux)
+}
+
+func (d *DataSource) CallResource(
+	ctx context.Context, req *backend.CallResourceRequest,
+	sender backend.CallResourceResponseSender,
+) error {
+	return d.resourceHandler.CallResource(ctx, req, sender)
+}
+
+//nolint:tagliatelle // js
+type commandExecutionResource struct {
+	DeviceID    string         `json:"deviceId"`
+	ExecutionID string         `json:"executionId"`
+	State       string         `json:"state"`
+	Payload     map[string]any `json:"payload"`
+	Done        bool           `json:"done"`
+}
+
+func (d *DataSource) handleGetCommandExecution(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.PathValue("device_id")
+	executionID := r.PathValue("execution_id")
+
+	ctx, err := d.withAccessToken(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		d.writeResourceJSON(w, http.StatusUnauthorized, map[string]string{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user := httpadapter.UserFromContext(ctx)
+	if err := d.commandPolicy.authorizeExecution(user, deviceID); err != nil {
+		d.logger.Warn("command execution access denied",
+			"device_id", deviceID,
+			"execution_id", executionID,
+			"error", err)
+		d.writeResourceJSON(w, http.StatusForbidden, map[string]string{
+			"error": d.userFacingError(err).Error(),
+		})
+		return
+	}
+
+	resp, err := d.getCommandExecution(ctx, user, deviceID, executionID)
+	if err != nil {
+		d.logger.Warn("failed to get command execution",
+			"device_id", deviceID,
+			"execution_id", executionID,
+			"error", err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, ErrAsyncCommandsNotSupported) {
+			status = http.StatusNotImplemented
+		}
+		d.writeResourceJSON(w, status, map[string]string{
+			"error": d.userFacingError(err).Error(),
+		})
+		return
+	}
+
+	d.writeResourceJSON(w, http.StatusOK, &commandExecutionResource{
+		DeviceID:    deviceID,
+		ExecutionID: executionID,
+		State:       resp.State,
+		Payload:     resp.Payload,
+		Done:        !isCommandExecutionPending(resp.State),
+	})
+}
+
+func (d *DataSource) writeResourceJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		d.logger.Error("failed to write resource response", "error", err)
+	}
+}
+
+func (d *DataSource) getCommandExecution(
+	ctx context.Context, grafanaUser *backend.User, deviceID, executionID string,
+) (*GetCommandExecutionResponse, error) {
+	ctx, cancel := d.withTimeout(ctx, 0)
+	defer cancel()
+
+	user, err := d.resolveUser(ctx, grafanaUser)
+	if err != nil {
+		return nil, fmt.Errorf("resolve user: %w", err)
+	}
+
+	resp, err := d.enapterAPI.GetCommandExecution(ctx, &GetCommandExecutionRequest{
+		User:        user,
+		DeviceID:    deviceID,
+		ExecutionID: executionID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get command execution: %w", err)
+	}
+
+	return resp, nil
+}
+
+func (d *DataSource) SubscribeStream(
+	_ context.Context, req *backend.SubscribeStreamRequest,
+) (*backend.SubscribeStreamResponse, error) {
+	p, ok := parseCommandExecutionPath(req.Path)
+	if !ok {
+		return &backend.SubscribeStreamResponse{
+			Status: backend.SubscribeStreamStatusNotFound,
+		}, nil
+	}
+	user := req.PluginContext.User
+	if err := d.authorizeStream(user, p); err != nil {
+		d.logger.Warn("command execution stream access denied",
+			"device_id", p.deviceID,
+			"execution_id", p.executionID,
+			"error", err)
+		return &backend.SubscribeStreamResponse{
+			Status: backend.SubscribeStreamStatusPermissionDenied,
+		}, nil
+	}
+	return &backend.SubscribeStreamResponse{
+		Status: backend.SubscribeStreamStatusOK,
+	}, nil
+}
+
+func (d *DataSource) authorizeStream(user *backend.User, p commandExecutionPath) error {
+	if p.userKey != streamUserKey(user) {
+		return fmt.Errorf("%w: channel belongs to another user", ErrCommandForbidden)
+	}
+	return d.commandPolicy.authorizeExecution(user, p.deviceID)
+}
+
+func (d *DataSource) PublishStream(
+	context.Context, *backend.PublishStreamRequest,
+) (*backend.PublishStreamResponse, error) {
+	return &backend.PublishStreamResponse{
+		Status: backend.PublishStreamStatusPermissionDenied,
+	}, nil
+}
+
+// RunStream polls the command execution and publishes its state changes
+// until it is complete or the stream times out. Failed polls are repeated
+// unless polling again cannot help.
+func (d *DataSource) RunStream(
+	ctx context.Context, req *backend.RunStreamRequest, sender *backend.StreamSender,
+) error {
+	p, ok := parseCommandExecutionPath(req.Path)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrCommandExecutionNotFound, req.Path)
+	}
+	// The stream runs on behalf of the user the channel belongs to.
+	if err := d.authorizeStream(req.PluginContext.User, p); err != nil {
+		return fmt.Errorf("authorize: %w", err)
+	}
+
+	// Grafana does not forward OAuth tokens to streams, so they cannot be
+	// run if forwarding is required.
+	ctx, err := d.withAccessToken(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, commandExecutionStreamTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(commandExecutionPollInterval)
+	defer ticker.Stop()
+
+	var (
+		sent      bool
+		lastState string
+	)
+	for {
+		resp, err := d.getCommandExecution(ctx, req.PluginContext.User,
+			p.deviceID, p.executionID)
+		if err != nil && ctx.Err() == nil {
+			if isCommandExecutionPollFatal(err) {
+				return err
+			}
+			d.logger.Warn("failed to poll command execution",
+				"device_id", p.deviceID,
+				"execution_id", p.executionID,
+				"error", err)
+		}
+
+		if err == nil && (!sent || resp.State != lastState) {
+			sent, lastState = true, resp.State
+			if err := sender.SendFrame(
+				commandExecutionToDataFrame(time.Now(), resp),
+				data.IncludeAll,
+			); err != nil {
+				return fmt.Errorf("send frame: %w", err)
+			}
+			if !isCommandExecutionPending(resp.State) {
+				return nil
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil
+			}
+			return sender.SendFrame(commandExecutionToDataFrame(time.Now(),
+				&GetCommandExecutionResponse{
+					State: commandExecutionStateTimeout,
+				}), data.IncludeAll)
+		}
+	}
+}
+
+// isCommandExecutionPollFatal tells the rejected polls apart from the
+// failures which may pass.
+func isCommandExecutionPollFatal(err error) bool {
+	if errors.Is(err, ErrCommandForbidden) {
+		return true
+	}
+	switch status, _ := classifyError(err); status {
+	case backend.StatusBadRequest, backend.StatusUnauthorized,
+		backend.StatusForbidden, backend.StatusNotFound:
+		return true
+	default:
+		return false
+	}
+}
+
+func commandExecutionToDataFrame(
+	t time.Time, resp *GetCommandExecutionResponse,
+) *data.Frame {
+	// Marshaling a map decoded from JSON cannot fail.
+	payloadBytes, _ := json.Marshal(resp.Payload)
+	return data.NewFrame("",
+		data.NewField("time", nil, []time.Time{t}),
+		data.NewField("state", nil, []string{resp.State}),
+		data.NewField("payload", nil, []json.RawMessage{payloadBytes}),
+	)
+}
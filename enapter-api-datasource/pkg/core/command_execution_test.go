@@ -0,0 +1,279 @@
+package core_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+
+	"github.com/bxcodec/faker/v3"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestAsyncCommandRequest() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	req.queries[0].payload["async"] = true
+	executionID := faker.UUIDHyphenated()
+
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockEnapterAPIAdapter.ExpectStartCommandExecutionAndReturn(
+		&core.ExecuteCommandRequest{
+			User:        req.user,
+			CommandName: req.queries[0].payload["commandName"].(string),
+			CommandArgs: req.queries[0].payload["commandArgs"].(map[string]any),
+			DeviceID:    req.queries[0].payload["deviceId"].(string),
+		}, &core.StartCommandExecutionResponse{
+			DeviceID:    req.queries[0].payload["deviceId"].(string),
+			ExecutionID: executionID,
+		}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(func(r *core.AuditRecord) {
+		s.Require().Equal("started", r.State)
+		s.Require().Equal(executionID, r.Payload["execution_id"])
+	}, nil)
+
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+	state, payload := s.extractCommandResponse(frames)
+	s.Require().Equal("started", state)
+	s.Require().Equal(executionID, payload["execution_id"])
+}
+
+func (s *DataSourceSuite) TestGetCommandExecutionResource() {
+	user := faker.Email()
+	deviceID := faker.UUIDHyphenated()
+	executionID := faker.UUIDHyphenated()
+	payload := map[string]any{faker.Word(): faker.Word()}
+
+	s.expectResolveUserAndReturn(user, user, nil)
+	s.mockEnapterAPIAdapter.ExpectGetCommandExecutionAndReturn(
+		&core.GetCommandExecutionRequest{
+			User:        user,
+			DeviceID:    deviceID,
+			ExecutionID: executionID,
+		}, &core.GetCommandExecutionResponse{
+			State:   "succeeded",
+			Payload: payload,
+		}, nil)
+
+	resourcePath := "command_executions/" + deviceID + "/" + executionID
+	var resp *backend.CallResourceResponse
+	err := s.dataSource.CallResource(s.ctx, &backend.CallResourceRequest{
+		PluginContext: backend.PluginContext{
+			User: &backend.User{Email: user},
+		},
+		Path:   resourcePath,
+		Method: http.MethodGet,
+		URL:    resourcePath,
+	}, callResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+		resp = r
+		return nil
+	}))
+	s.Require().NoError(err)
+	s.Require().NotNil(resp)
+	s.Require().Equal(http.StatusOK, resp.Status)
+
+	var body map[string]any
+	s.Require().NoError(json.Unmarshal(resp.Body, &body))
+	s.Require().Equal(executionID, body["executionId"])
+	s.Require().Equal("succeeded", body["state"])
+	s.Require().Equal(payload, body["payload"])
+	s.Require().Equal(true, body["done"])
+}
+
+func (s *DataSourceSuite) TestSubscribeCommandExecutionStream() {
+	resp, err := s.dataSource.SubscribeStream(s.ctx, &backend.SubscribeStreamRequest{
+		Path: "command_executions/" + faker.UUIDHyphenated(),
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(backend.SubscribeStreamStatusNotFound, resp.Status)
+}
+
+func (s *DataSourceSuite) TestSubscribeCommandExecutionStreamOfAnotherUser() {
+	resp, err := s.dataSource.SubscribeStream(s.ctx, &backend.SubscribeStreamRequest{
+		PluginContext: backend.PluginContext{
+			User: &backend.User{Email: faker.Email()},
+		},
+		Path: commandExecutionStreamPath(faker.Email(),
+			faker.UUIDHyphenated(), faker.UUIDHyphenated()),
+	})
+	s.Require().NoError(err)
+	s.Require().Equal(backend.SubscribeStreamStatusPermissionDenied, resp.Status)
+}
+
+func (s *DataSourceSuite) TestSubscribeCommandExecutionStreamForbiddenDevice() {
+	dataSource := core.NewDataSource(core.DataSourceParams{
+		Logger:       s.logger,
+		EnapterAPI:   s.mockEnapterAPIAdapter,
+		UserResolver: s.mockUserResolver,
+		AuditLog:     s.mockAuditLog,
+		CommandPolicy: core.CommandPolicy{
+			AllowedDevices: []string{"allowed"},
+		},
+	})
+
+	user := faker.Email()
+	for deviceID, want := range map[string]backend.SubscribeStreamStatus{
+		"allowed":   backend.SubscribeStreamStatusOK,
+		"forbidden": backend.SubscribeStreamStatusPermissionDenied,
+	} {
+		resp, err := dataSource.SubscribeStream(s.ctx, &backend.SubscribeStreamRequest{
+			PluginContext: backend.PluginContext{
+				User: &backend.User{Email: user},
+			},
+			Path: commandExecutionStreamPath(user, deviceID, faker.UUIDHyphenated()),
+		})
+		s.Require().NoError(err)
+		s.Require().Equal(want, resp.Status, deviceID)
+	}
+}
+
+func (s *DataSourceSuite) TestGetCommandExecutionResourceForbidden() {
+	dataSource := core.NewDataSource(core.DataSourceParams{
+		Logger:       s.logger,
+		EnapterAPI:   s.mockEnapterAPIAdapter,
+		UserResolver: s.mockUserResolver,
+		AuditLog:     s.mockAuditLog,
+		CommandPolicy: core.CommandPolicy{
+			MinRole: core.RoleEditor,
+		},
+	})
+
+	resourcePath := "command_executions/" + faker.UUIDHyphenated() +
+		"/" + faker.UUIDHyphenated()
+	var resp *backend.CallResourceResponse
+	err := dataSource.CallResource(s.ctx, &backend.CallResourceRequest{
+		PluginContext: backend.PluginContext{
+			User: &backend.User{Email: faker.Email(), Role: "Viewer"},
+		},
+		Path:   resourcePath,
+		Method: http.MethodGet,
+		URL:    resourcePath,
+	}, callResourceResponseSenderFunc(func(r *backend.CallResourceResponse) error {
+		resp = r
+		return nil
+	}))
+	s.Require().NoError(err)
+	s.Require().NotNil(resp)
+	s.Require().Equal(http.StatusForbidden, resp.Status)
+}
+
+func (s *DataSourceSuite) TestRunCommandExecutionStream() {
+	user := faker.Email()
+	deviceID := faker.UUIDHyphenated()
+	executionID := faker.UUIDHyphenated()
+
+	s.expectResolveUserAndReturn(user, user, nil)
+	s.mockEnapterAPIAdapter.ExpectGetCommandExecutionAndReturn(
+		&core.GetCommandExecutionRequest{
+			User:        user,
+			DeviceID:    deviceID,
+			ExecutionID: executionID,
+		}, &core.GetCommandExecutionResponse{
+			State: "error",
+		}, nil)
+
+	var packets []*backend.StreamPacket
+	err := s.dataSource.RunStream(s.ctx, &backend.RunStreamRequest{
+		PluginContext: backend.PluginContext{
+			User: &backend.User{Email: user},
+		},
+		Path: commandExecutionStreamPath(user, deviceID, executionID),
+	}, backend.NewStreamSender(streamPacketSenderFunc(func(p *backend.StreamPacket) error {
+		packets = append(packets, p)
+		return nil
+	})))
+	s.Require().NoError(err)
+	s.Require().Len(packets, 1)
+
+	frame := new(data.Frame)
+	s.Require().NoError(json.Unmarshal(packets[0].Data, frame))
+	state, ok := frame.Fields[1].ConcreteAt(0)
+	s.Require().True(ok)
+	s.Require().Equal("error", state)
+}
+
+func (s *DataSourceSuite) TestRunCommandExecutionStreamRetriesTransientErrors() {
+	user := faker.Email()
+	deviceID := faker.UUIDHyphenated()
+	executionID := faker.UUIDHyphenated()
+
+	s.expectResolveUserAndReturn(user, user, nil)
+	polls := 0
+	defer s.mockEnapterAPIAdapter.ExpectGetCommandExecutionsAndHandle(
+		func(*core.GetCommandExecutionRequest) (*core.GetCommandExecutionResponse, error) {
+			polls++
+			if polls == 1 {
+				s.expectResolveUserAndReturn(user, user, nil)
+				return nil, core.EnapterAPIError{
+					Code:       "unavailable",
+					StatusCode: http.StatusServiceUnavailable,
+				}
+			}
+			return &core.GetCommandExecutionResponse{State: "success"}, nil
+		})()
+
+	packets, err := s.runCommandExecutionStream(user, deviceID, executionID)
+	s.Require().NoError(err)
+	s.Require().Equal(2, polls)
+	s.Require().Len(packets, 1)
+}
+
+func (s *DataSourceSuite) TestRunCommandExecutionStreamStopsOnRejectedPoll() {
+	user := faker.Email()
+	deviceID := faker.UUIDHyphenated()
+	executionID := faker.UUIDHyphenated()
+
+	s.expectResolveUserAndReturn(user, user, nil)
+	s.mockEnapterAPIAdapter.ExpectGetCommandExecutionAndReturn(
+		&core.GetCommandExecutionRequest{
+			User:        user,
+			DeviceID:    deviceID,
+			ExecutionID: executionID,
+		}, nil, core.EnapterAPIError{
+			Code:       "not_found",
+			StatusCode: http.StatusNotFound,
+		})
+
+	packets, err := s.runCommandExecutionStream(user, deviceID, executionID)
+	s.Require().Error(err)
+	s.Require().Empty(packets)
+}
+
+func (s *DataSourceSuite) runCommandExecutionStream(
+	user, deviceID, executionID string,
+) ([]*backend.StreamPacket, error) {
+	var packets []*backend.StreamPacket
+	err := s.dataSource.RunStream(s.ctx, &backend.RunStreamRequest{
+		PluginContext: backend.PluginContext{
+			User: &backend.User{Email: user},
+		},
+		Path: commandExecutionStreamPath(user, deviceID, executionID),
+	}, backend.NewStreamSender(streamPacketSenderFunc(func(p *backend.StreamPacket) error {
+		packets = append(packets, p)
+		return nil
+	})))
+	return packets, err
+}
+
+// commandExecutionStreamPath mirrors the channel path the data source
+// returns for users without a login.
+func commandExecutionStreamPath(email, deviceID, executionID string) string {
+	sum := sha256.Sum256([]byte(email))
+	return "command_executions/" + deviceID + "/" + executionID + "/" +
+		hex.EncodeToString(sum[:])[:16]
+}
+
+type callResourceResponseSenderFunc func(*backend.CallResourceResponse) error
+
+func (fn callResourceResponseSenderFunc) Send(r *backend.CallResourceResponse) error {
+	return fn(r)
+}
+
+type streamPacketSenderFunc func(*backend.StreamPacket) error
+
+func (fn streamPacketSenderFunc) Send(p *backend.StreamPacket) error {
+	return fn(p)
+}
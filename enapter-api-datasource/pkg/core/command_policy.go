@@ -0,0 +1,137 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+type Role string
+
+const (
+	RoleNone   Role = "None"
+	RoleViewer Role = "Viewer"
+	RoleEditor Role = "Editor"
+	RoleAdmin  Role = "Admin"
+)
+
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+func (r Role) atLeast(other Role) bool {
+	return roleRanks[r] >= roleRanks[other]
+}
+
+//nolint:gochecknoglobals // constant
+var roleRanks = map[Role]int{
+	RoleNone:   0,
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// CommandPolicy decides which Grafana users may execute which commands.
+// The zero value allows everything.
+type CommandPolicy struct {
+	// ReadOnly disables command queries entirely.
+	ReadOnly bool
+	// MinRole is the minimum Grafana role required to execute commands.
+	MinRole Role
+	// AllowedCommands restricts command names, if not empty.
+	AllowedCommands []string
+	// AllowedDevices restricts device IDs and hardware IDs, if not empty.
+	// Every ID given in a command must be allowed.
+	AllowedDevices []string
+}
+
+func (p *CommandPolicy) authorize(
+	user *backend.User, req *ExecuteCommandRequest,
+) error {
+	if err := p.authorizeCommand(user, req.CommandName); err != nil {
+		return err
+	}
+
+	if len(p.AllowedDevices) > 0 && !p.devicesAllowed(req.DeviceID, req.HardwareID) {
+		return fmt.Errorf("%w: device %q (hardware %q) is not allowed",
+			ErrCommandForbidden, req.DeviceID, req.HardwareID)
+	}
+
+	return nil
+}
+
+// authorizeCommand checks everything but the device, which lets bulk
+// commands be rejected before their devices are selected.
+func (p *CommandPolicy) authorizeCommand(user *backend.User, commandName string) error {
+	if p.ReadOnly {
+		return ErrCommandsDisabled
+	}
+
+	if err := p.authorizeRole(user); err != nil {
+		return err
+	}
+
+	if len(p.AllowedCommands) > 0 &&
+		!slices.Contains(p.AllowedCommands, commandName) {
+		return fmt.Errorf("%w: command %q is not allowed",
+			ErrCommandForbidden, commandName)
+	}
+
+	return nil
+}
+
+// authorizeExecution decides whether the user may follow a command execution
+// on the device. The command name is not known at this point, so only the
+// role and the device are checked.
+func (p *CommandPolicy) authorizeExecution(user *backend.User, deviceID string) error {
+	if p.ReadOnly {
+		return ErrCommandsDisabled
+	}
+
+	if err := p.authorizeRole(user); err != nil {
+		return err
+	}
+
+	if len(p.AllowedDevices) > 0 && !p.devicesAllowed(deviceID) {
+		return fmt.Errorf("%w: device %q is not allowed",
+			ErrCommandForbidden, deviceID)
+	}
+
+	return nil
+}
+
+func (p *CommandPolicy) authorizeRole(user *backend.User) error {
+	if p.MinRole == "" {
+		return nil
+	}
+	if role := userRole(user); !role.atLeast(p.MinRole) {
+		return fmt.Errorf("%w: role %q is lower than %q",
+			ErrCommandForbidden, role, p.MinRole)
+	}
+	return nil
+}
+
+func userRole(user *backend.User) Role {
+	if user == nil || user.Role == "" {
+		return RoleNone
+	}
+	return Role(user.Role)
+}
+
+// devicesAllowed requires at least one ID, because the command is sent to
+// every ID given.
+func (p *CommandPolicy) devicesAllowed(ids ...string) bool {
+	var n int
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		if !slices.Contains(p.AllowedDevices, id) {
+			return false
+		}
+		n++
+	}
+	return n > 0
+}
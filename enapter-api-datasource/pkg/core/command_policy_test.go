@@ -0,0 +1,117 @@
+package core_test
+
+import (
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestCommandPolicyReadOnly() {
+	err := s.handleCommandWithPolicy(core.CommandPolicy{
+		ReadOnly: true,
+	}, "Admin", false)
+	s.Require().ErrorIs(err, core.ErrCommandsDisabled)
+}
+
+func (s *DataSourceSuite) TestCommandPolicyMinRole() {
+	for role, allowed := range map[string]bool{
+		"":       false,
+		"None":   false,
+		"Viewer": false,
+		"Editor": true,
+		"Admin":  true,
+	} {
+		s.Run(role, func() {
+			err := s.handleCommandWithPolicy(core.CommandPolicy{
+				MinRole: core.RoleEditor,
+			}, role, allowed)
+			if allowed {
+				s.Require().NoError(err)
+			} else {
+				s.Require().ErrorIs(err, core.ErrCommandForbidden)
+			}
+		})
+	}
+}
+
+func (s *DataSourceSuite) TestCommandPolicyAllowedCommands() {
+	err := s.handleCommandWithPolicy(core.CommandPolicy{
+		AllowedCommands: []string{"start", "stop"},
+	}, "Viewer", false)
+	s.Require().ErrorIs(err, core.ErrCommandForbidden)
+}
+
+func (s *DataSourceSuite) TestCommandPolicyAllowedDevices() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	err := s.handleCommandRequestWithPolicy(req, core.CommandPolicy{
+		AllowedDevices: []string{"other"},
+	}, "Viewer", false)
+	s.Require().ErrorIs(err, core.ErrCommandForbidden)
+
+	err = s.handleCommandRequestWithPolicy(req, core.CommandPolicy{
+		AllowedDevices: []string{req.queries[0].payload["deviceId"].(string)},
+	}, "Viewer", true)
+	s.Require().NoError(err)
+}
+
+func (s *DataSourceSuite) TestCommandPolicyAllowedDevicesRequireEveryID() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	req.queries[0].payload["hardwareId"] = "allowed"
+	err := s.handleCommandRequestWithPolicy(req, core.CommandPolicy{
+		AllowedDevices: []string{"allowed"},
+	}, "Viewer", false)
+	s.Require().ErrorIs(err, core.ErrCommandForbidden)
+}
+
+func (s *DataSourceSuite) handleCommandWithPolicy(
+	policy core.CommandPolicy, role string, expectExecution bool,
+) error {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	return s.handleCommandRequestWithPolicy(req, policy, role, expectExecution)
+}
+
+func (s *DataSourceSuite) handleCommandRequestWithPolicy(
+	req dataRequest, policy core.CommandPolicy, role string, expectExecution bool,
+) error {
+	dataSource := core.NewDataSource(core.DataSourceParams{
+		Logger:        s.logger,
+		EnapterAPI:    s.mockEnapterAPIAdapter,
+		UserResolver:  s.mockUserResolver,
+		AuditLog:      s.mockAuditLog,
+		CommandPolicy: policy,
+	})
+
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	if expectExecution {
+		s.expectExecuteCommandAndReturn(req, &core.ExecuteCommandResponse{
+			State: "succeeded",
+		}, nil)
+	}
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(func(r *core.AuditRecord) {
+		if expectExecution {
+			s.Require().Empty(r.Error)
+		} else {
+			s.Require().NotEmpty(r.Error)
+		}
+	}, nil)
+
+	q := req.queries[0]
+	resp, err := dataSource.QueryData(s.ctx, &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{
+			User: &backend.User{
+				Email: req.user,
+				Role:  role,
+			},
+		},
+		Queries: []backend.DataQuery{{
+			RefID:     q.refID,
+			QueryType: q.queryType,
+			JSON: s.shouldMarshalJSON(map[string]any{
+				"payload": q.payload,
+			}),
+		}},
+	})
+	s.Require().NoError(err)
+
+	return resp.Responses[q.refID].Error
+}
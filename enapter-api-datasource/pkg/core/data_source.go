@@ -10,101 +10,283 @@ import (
 	"time"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
-	"github.com/hashicorp/go-hclog"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
+
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
 )
 
 var (
-	_ backend.CheckHealthHandler = (*DataSource)(nil)
-	_ backend.QueryDataHandler   = (*DataSource)(nil)
+	_ backend.CheckHealthHandler  = (*DataSource)(nil)
+	_ backend.QueryDataHandler    = (*DataSource)(nil)
+	_ backend.CallResourceHandler = (*DataSource)(nil)
+	_ backend.StreamHandler       = (*DataSource)(nil)
 )
 
 type DataSource struct {
-	logger       hclog.Logger
-	enapterAPI   EnapterAPIPort
+	logger     log.Logger
+	enapterAPI EnapterAPIPort
+	// endpoints is empty unless telemetry queries are federated, otherwise
+	// it starts with enapterAPI.
+	endpoints    []endpoint
 	userResolver UserResolverPort
+	// uncachedUserResolver is used by the health check.
+	uncachedUserResolver UserResolverPort
+	auditLog             AuditLogPort
+	auditLogMinRole      Role
+	commandPolicy        CommandPolicy
+	deduplicator         *commandDeduplicator
+	breaker              *circuitBreaker
+	timeouts             Timeouts
+	// forwardOAuthToken makes the OAuth access token of the Grafana user
+	// the only credential used to call Enapter API.
+	forwardOAuthToken bool
+	metrics           *metrics.DataSource
+
+	resourceHandler backend.CallResourceHandler
 }
 
 type DataSourceParams struct {
-	Logger       hclog.Logger
-	EnapterAPI   EnapterAPIPort
-	UserResolver UserResolverPort
+	Logger     log.Logger
+	EnapterAPI EnapterAPIPort
+	// EnapterAPIName names EnapterAPI among Endpoints.
+	EnapterAPIName string
+	// Endpoints is optional. Telemetry queries are federated across
+	// EnapterAPI and Endpoints, everything else uses EnapterAPI only.
+	Endpoints            []Endpoint
+	UserResolver         UserResolverPort
+	AuditLog             AuditLogPort
+	CommandPolicy        CommandPolicy
+	CommandDeduplication CommandDeduplication
+	CircuitBreaker       CircuitBreaker
+	Timeouts             Timeouts
+	ForwardOAuthToken    bool
+	UserResolverCache    UserResolverCache
+	// AuditLogMinRole is the minimum Grafana role required to read the
+	// audit log. Every user may read it if empty.
+	AuditLogMinRole Role
+	// Metrics is optional.
+	Metrics *metrics.DataSource
 }
 
 func NewDataSource(p DataSourceParams) *DataSource {
-	return &DataSource{
-		logger:       p.Logger,
-		enapterAPI:   p.EnapterAPI,
-		userResolver: p.UserResolver,
+	d := &DataSource{
+		logger:        p.Logger,
+		enapterAPI:    p.EnapterAPI,
+		userResolver:  p.UserResolver,
+		auditLog:      p.AuditLog,
+		commandPolicy: p.CommandPolicy,
+		deduplicator:  newCommandDeduplicator(p.CommandDeduplication),
+		timeouts:      p.Timeouts,
+
+		uncachedUserResolver: p.UserResolver,
+		auditLogMinRole:      p.AuditLogMinRole,
+		forwardOAuthToken:    p.ForwardOAuthToken,
+		metrics:              p.Metrics,
+	}
+	if p.UserResolverCache.TTL > 0 {
+		d.userResolver = newCachingUserResolver(
+			p.UserResolver, p.UserResolverCache, p.Metrics)
+	}
+	d.enapterAPI = &instrumentedEnapterAPI{
+		EnapterAPIPort: d.enapterAPI,
+		metrics:        p.Metrics,
+	}
+	// The breaker wraps the instrumentation, so that only the requests
+	// actually sent are traced and recorded.
+	if p.CircuitBreaker.FailureThreshold > 0 {
+		d.breaker = newCircuitBreaker(d.enapterAPI, p.CircuitBreaker)
+		d.enapterAPI = d.breaker
+	}
+	if len(p.Endpoints) > 0 {
+		d.endpoints = append(d.endpoints, endpoint{
+			name:       p.EnapterAPIName,
+			enapterAPI: d.enapterAPI,
+		})
+	}
+	for _, e := range p.Endpoints {
+		var api EnapterAPIPort = &instrumentedEnapterAPI{
+			EnapterAPIPort: e.EnapterAPI,
+			metrics:        p.Metrics,
+		}
+		// Every site fails on its own, so each one has a breaker of its
+		// own.
+		if p.CircuitBreaker.FailureThreshold > 0 {
+			api = newCircuitBreaker(api, p.CircuitBreaker)
+		}
+		d.endpoints = append(d.endpoints, endpoint{name: e.Name, enapterAPI: api})
 	}
+	d.resourceHandler = d.newResourceHandler()
+	return d
 }
 
 func (d *DataSource) CheckHealth(
-	ctx context.Context, _ *backend.CheckHealthRequest,
+	ctx context.Context, req *backend.CheckHealthRequest,
 ) (*backend.CheckHealthResult, error) {
-	if err := d.enapterAPI.Ready(ctx); err != nil {
-		d.logger.Error("Enapter API is not ready", "error", err.Error())
+	ctx, cancel := d.withTimeout(ctx, 0)
+	defer cancel()
+
+	ctx, err := d.withAccessToken(ctx,
+		req.GetHTTPHeader(backend.OAuthIdentityTokenHeaderName))
+	if err != nil {
 		return &backend.CheckHealthResult{
 			Status:  backend.HealthStatusError,
 			Message: err.Error(),
 		}, nil
 	}
+
+	results := d.runHealthChecks(ctx, req.PluginContext.User)
+
+	status := backend.HealthStatusOk
+	for _, r := range results {
+		if r.Status == healthCheckStatusError {
+			status = backend.HealthStatusError
+		}
+	}
 	return &backend.CheckHealthResult{
-		Status:  backend.HealthStatusOk,
-		Message: "ok",
+		Status:      status,
+		Message:     d.withCircuitBreakerState(healthCheckMessage(results)),
+		JSONDetails: healthCheckJSONDetails(results),
 	}, nil
 }
 
+func (d *DataSource) withCircuitBreakerState(msg string) string {
+	if d.breaker == nil {
+		return msg
+	}
+	state, failures := d.breaker.State()
+	return fmt.Sprintf("%s (circuit breaker: %s, consecutive failures: %d)",
+		msg, state, failures)
+}
+
 func (d *DataSource) QueryData(
 	ctx context.Context, req *backend.QueryDataRequest,
-) (*backend.QueryDataResponse, error) {
+) (_ *backend.QueryDataResponse, retErr error) {
+	ctx, span := startSpan(ctx, "QueryData",
+		attribute.Int("query_count", len(req.Queries)))
+	defer func() { endSpan(span, retErr) }()
+
+	ctx, err := d.withAccessToken(ctx,
+		req.GetHTTPHeader(backend.OAuthIdentityTokenHeaderName))
+	if err != nil {
+		resp := backend.NewQueryDataResponse()
+		for _, q := range req.Queries {
+			resp.Responses[q.RefID] = backend.DataResponse{
+				Error:  err,
+				Status: backend.StatusUnauthorized,
+			}
+		}
+		return resp, nil
+	}
+
 	user, err := d.resolveUser(ctx, req.PluginContext.User)
 	if err != nil {
-		return nil, fmt.Errorf("resolve user: %w", err)
+		// Every query fails the same way, so that the user is told why.
+		err = fmt.Errorf("resolve user: %w", err)
+		resp := backend.NewQueryDataResponse()
+		for _, q := range req.Queries {
+			resp.Responses[q.RefID] = d.errorResponse(q.RefID, err)
+		}
+		return resp, nil
+	}
+
+	r := &requester{
+		grafanaUser: req.PluginContext.User,
+		enapterUser: user,
+	}
+	if settings := req.PluginContext.DataSourceInstanceSettings; settings != nil {
+		r.dataSourceUID = settings.UID
 	}
 
 	resp := backend.NewQueryDataResponse()
 
 	for _, q := range req.Queries {
-		frames, err := d.handleQuery(ctx, user, q)
+		frames, err := d.handleQuery(ctx, r, q)
 		if err != nil {
-			d.logger.Warn("failed to handle query",
-				"ref_id", q.RefID,
-				"error", err)
-
-			err = d.userFacingError(err)
+			resp.Responses[q.RefID] = d.errorResponse(q.RefID, err)
+			continue
 		}
 
 		resp.Responses[q.RefID] = backend.DataResponse{
 			Frames: frames,
-			Error:  err,
 		}
 	}
 
 	return resp, nil
 }
 
+func (d *DataSource) errorResponse(refID string, err error) backend.DataResponse {
+	status, source := classifyError(err)
+	d.logger.Warn("failed to handle query",
+		"ref_id", refID,
+		"status", status,
+		"error_source", source,
+		"error", err)
+
+	return backend.DataResponse{
+		Frames: enapterAPIErrorFrames(err, source),
+		Error:  d.userFacingError(err),
+		Status: status,
+	}
+}
+
 func (d *DataSource) resolveUser(
 	ctx context.Context, user *backend.User,
 ) (string, error) {
-	if user == nil {
+	// The forwarded access token identifies the user on its own.
+	if user == nil || d.forwardOAuthToken {
 		return "", nil
 	}
+	ctx, cancel := d.withTimeout(ctx, 0)
+	defer cancel()
+	ctx, span := startSpan(ctx, "resolveUser")
+	start := time.Now()
 	resp, err := d.userResolver.ResolveUser(ctx, &ResolveUserRequest{
 		Email: user.Email,
+		Login: user.Login,
+		Name:  user.Name,
 	})
+	d.metrics.ObserveUserResolution(userResolutionResult(err), time.Since(start))
+	endSpan(span, err)
 	if err != nil {
 		return "", err
 	}
+	// The ID is sent in a header, so the resolver must not be trusted
+	// blindly.
+	if !validUserID(resp.ID) {
+		return "", fmt.Errorf("%w: %q", errInvalidUserID, resp.ID)
+	}
 	return resp.ID, nil
 }
 
+// requester describes on whose behalf a query is handled.
+type requester struct {
+	grafanaUser   *backend.User
+	enapterUser   string
+	dataSourceUID string
+}
+
+func validUserID(id string) bool {
+	const maxLen = 256
+	if len(id) > maxLen {
+		return false
+	}
+	for _, r := range id {
+		if r < ' ' || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
 func (d *DataSource) handleQuery(
-	ctx context.Context, user string, query backend.DataQuery,
-) (data.Frames, error) {
+	ctx context.Context, r *requester, query backend.DataQuery,
+) (_ data.Frames, retErr error) {
 	var handler func(
-		ctx context.Context, user string, query backend.DataQuery,
+		ctx context.Context, r *requester, query backend.DataQuery,
 	) (data.Frames, error)
 
 	queryType := "telemetry"
@@ -112,7 +294,14 @@ func (d *DataSource) handleQuery(
 		queryType = t
 	}
 
+	ctx, span := startSpan(ctx, "handleQuery",
+		attribute.String("ref_id", query.RefID),
+		attribute.String("query_type", queryType))
+	defer func() { endSpan(span, retErr) }()
+
 	switch queryType {
+	case "audit":
+		handler = d.handleAuditQuery
 	case "command":
 		handler = d.handleCommandQuery
 	case "manifest":
@@ -123,7 +312,17 @@ func (d *DataSource) handleQuery(
 		return nil, errUnexpectedQueryType
 	}
 
-	frames, err := handler(ctx, user, query)
+	// Telemetry queries may request a timeout of their own, so they apply
+	// it themselves.
+	if queryType != "telemetry" {
+		var cancel context.CancelFunc
+		ctx, cancel = d.withTimeout(ctx, 0)
+		defer cancel()
+	}
+
+	start := time.Now()
+	frames, err := handler(ctx, r, query)
+	d.observeQuery(span, queryType, start, frames, err)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", queryType, err)
 	}
@@ -131,31 +330,81 @@ func (d *DataSource) handleQuery(
 	return frames, nil
 }
 
+func (d *DataSource) observeQuery(
+	span trace.Span, queryType string, start time.Time, frames data.Frames, err error,
+) {
+	status := backend.StatusOK
+	if err != nil {
+		status, _ = classifyError(err)
+	}
+	var rows int
+	for _, f := range frames {
+		if f != nil {
+			rows += f.Rows()
+		}
+	}
+	d.metrics.ObserveQuery(queryType, int(status), time.Since(start), len(frames), rows)
+	span.SetAttributes(
+		attribute.Int("series_count", len(frames)),
+		attribute.Int("row_count", rows))
+}
+
+func userResolutionResult(err error) string {
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.Is(err, ErrUserNotFound):
+		return "not_found"
+	default:
+		return "error"
+	}
+}
+
+//nolint:tagliatelle // js
+type commandQueryPayload struct {
+	CommandName    string         `json:"commandName"`
+	CommandArgs    map[string]any `json:"commandArgs"`
+	DeviceID       string         `json:"deviceId"`
+	HardwareID     string         `json:"hardwareId"`
+	IdempotencyKey string         `json:"idempotencyKey"`
+	Async          bool           `json:"async"`
+
+	// The following fields are used by bulk commands only.
+	DeviceIDs     []string        `json:"deviceIds"`
+	Selector      *deviceSelector `json:"selector"`
+	Concurrency   int             `json:"concurrency"`
+	StopOnFailure bool            `json:"stopOnFailure"`
+}
+
+func (p *commandQueryPayload) isBulk() bool {
+	return len(p.DeviceIDs) > 0 || p.Selector != nil
+}
+
 func (d *DataSource) handleCommandQuery(
-	ctx context.Context, user string, query backend.DataQuery,
+	ctx context.Context, r *requester, query backend.DataQuery,
 ) (data.Frames, error) {
-	//nolint:tagliatelle // js
 	var props struct {
-		Payload struct {
-			CommandName string         `json:"commandName"`
-			CommandArgs map[string]any `json:"commandArgs"`
-			DeviceID    string         `json:"deviceId"`
-			HardwareID  string         `json:"hardwareId"`
-		} `json:"payload"`
+		Payload commandQueryPayload `json:"payload"`
 	}
 	if err := json.Unmarshal(query.JSON, &props); err != nil {
 		return nil, fmt.Errorf("parse query properties: %w", err)
 	}
 
-	resp, err := d.enapterAPI.ExecuteCommand(ctx, &ExecuteCommandRequest{
-		User:        user,
+	if props.Payload.isBulk() {
+		return d.handleBulkCommandQuery(ctx, r, &props.Payload)
+	}
+
+	req := &ExecuteCommandRequest{
+		User:        r.enapterUser,
 		CommandName: props.Payload.CommandName,
 		CommandArgs: props.Payload.CommandArgs,
 		DeviceID:    props.Payload.DeviceID,
 		HardwareID:  props.Payload.HardwareID,
-	})
+	}
+
+	resp, err := d.executeCommand(ctx, r, req, &props.Payload)
 	if err != nil {
-		return nil, fmt.Errorf("execute command: %w", err)
+		return nil, err
 	}
 
 	frame, err := d.commandResponseToDataFrame(resp)
@@ -163,25 +412,197 @@ func (d *DataSource) handleCommandQuery(
 		return nil, fmt.Errorf("convert cmd resp to data frame: %w", err)
 	}
 
+	// Let the panel follow the progress of the execution.
+	deviceID, _ := resp.Payload["device_id"].(string)
+	executionID, _ := resp.Payload["execution_id"].(string)
+	// Grafana does not forward OAuth tokens to streams.
+	if props.Payload.Async && deviceID != "" && executionID != "" &&
+		r.dataSourceUID != "" && !d.forwardOAuthToken {
+		frame.Meta = &data.FrameMeta{
+			Channel: commandExecutionChannel(r.dataSourceUID, r.grafanaUser,
+				deviceID, executionID),
+		}
+	}
+
 	return data.Frames{frame}, nil
 }
 
-func (d *DataSource) handleManifestQuery(
-	ctx context.Context, user string, query backend.DataQuery,
+func (d *DataSource) executeCommand(
+	ctx context.Context, r *requester, req *ExecuteCommandRequest,
+	p *commandQueryPayload,
+) (*ExecuteCommandResponse, error) {
+	resp, deduplicated, err := d.deduplicator.do(ctx, r, req, p.IdempotencyKey,
+		func() (*ExecuteCommandResponse, error) {
+			start := time.Now()
+			resp, err := d.executeCommandOnce(ctx, r, req, p.Async)
+			d.auditCommand(ctx, r, req, start, resp, err)
+			return resp, err
+		})
+	if deduplicated {
+		d.logger.Info("duplicated command execution suppressed",
+			"device_id", req.DeviceID,
+			"hardware_id", req.HardwareID,
+			"command_name", req.CommandName,
+			"idempotency_key", p.IdempotencyKey)
+	}
+	return resp, err
+}
+
+func (d *DataSource) executeCommandOnce(
+	ctx context.Context, r *requester, req *ExecuteCommandRequest, async bool,
+) (*ExecuteCommandResponse, error) {
+	if err := d.commandPolicy.authorize(r.grafanaUser, req); err != nil {
+		return nil, fmt.Errorf("authorize: %w", err)
+	}
+
+	sent, err := d.debounce(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("debounce: %w", err)
+	}
+
+	var resp *ExecuteCommandResponse
+	if async {
+		resp, err = d.startCommandExecution(ctx, req)
+	} else {
+		resp, err = d.enapterAPI.ExecuteCommand(ctx, req)
+		if err != nil {
+			err = fmt.Errorf("execute command: %w", err)
+		}
+	}
+	sent(err)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// debounce identifies the device by its ID, so that commands addressed to
+// it by hardware ID are debounced along with the others.
+func (d *DataSource) debounce(
+	ctx context.Context, req *ExecuteCommandRequest,
+) (sent func(error), err error) {
+	deviceKey := req.DeviceID
+	if req.HardwareID != "" && d.deduplicator.debounces(req.CommandName) {
+		resp, err := d.enapterAPI.ResolveDeviceID(ctx, &ResolveDeviceIDRequest{
+			User:       req.User,
+			DeviceID:   req.DeviceID,
+			HardwareID: req.HardwareID,
+		})
+		switch {
+		case errors.Is(err, ErrHardwareIDNotSupported):
+			// The API looks devices up by hardware ID on its own.
+			deviceKey = req.DeviceID + "\x00" + req.HardwareID
+		case err != nil:
+			return nil, fmt.Errorf("resolve device ID: %w", err)
+		default:
+			deviceKey = resp.DeviceID
+		}
+	}
+	return d.deduplicator.debounce(req, deviceKey)
+}
+
+func (d *DataSource) auditCommand(
+	ctx context.Context, r *requester, req *ExecuteCommandRequest,
+	start time.Time, resp *ExecuteCommandResponse, err error,
+) {
+	record := &AuditRecord{
+		Time:        start,
+		EnapterUser: r.enapterUser,
+		DeviceID:    req.DeviceID,
+		HardwareID:  req.HardwareID,
+		CommandName: req.CommandName,
+		CommandArgs: req.CommandArgs,
+		Latency:     time.Since(start),
+	}
+	if u := r.grafanaUser; u != nil {
+		record.GrafanaUserLogin = u.Login
+		record.GrafanaUserEmail = u.Email
+	}
+	if resp != nil {
+		record.State = resp.State
+		record.Payload = resp.Payload
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	// The record must be written even if the query has been cancelled.
+	ctx = context.WithoutCancel(ctx)
+
+	if err := d.auditLog.WriteAuditRecord(ctx, record); err != nil {
+		d.logger.Error("failed to write audit record",
+			"device_id", req.DeviceID,
+			"hardware_id", req.HardwareID,
+			"command_name", req.CommandName,
+			"error", err)
+	}
+}
+
+func (d *DataSource) handleAuditQuery(
+	ctx context.Context, r *requester, query backend.DataQuery,
 ) (data.Frames, error) {
+	// The records disclose the commands of every user.
+	if d.auditLogMinRole != "" {
+		if role := userRole(r.grafanaUser); !role.atLeast(d.auditLogMinRole) {
+			return nil, fmt.Errorf("%w: role %q is lower than %q",
+				ErrAuditLogForbidden, role, d.auditLogMinRole)
+		}
+	}
+
 	//nolint:tagliatelle // js
 	var props struct {
 		Payload struct {
 			DeviceID string `json:"deviceId"`
+			Limit    int    `json:"limit"`
 		} `json:"payload"`
 	}
 	if err := json.Unmarshal(query.JSON, &props); err != nil {
 		return nil, fmt.Errorf("parse query properties: %w", err)
 	}
 
-	resp, err := d.enapterAPI.GetDeviceManifest(ctx, &GetDeviceManifestRequest{
-		User:     user,
+	const defaultLimit = 1000
+	limit := props.Payload.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+
+	resp, err := d.auditLog.ReadAuditRecords(ctx, &ReadAuditRecordsRequest{
+		From:     query.TimeRange.From,
+		To:       query.TimeRange.To,
 		DeviceID: props.Payload.DeviceID,
+		Limit:    limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read audit records: %w", err)
+	}
+
+	frame, err := d.auditRecordsToDataFrame(resp.Records)
+	if err != nil {
+		return nil, fmt.Errorf("convert audit records to data frame: %w", err)
+	}
+
+	return data.Frames{frame}, nil
+}
+
+func (d *DataSource) handleManifestQuery(
+	ctx context.Context, r *requester, query backend.DataQuery,
+) (data.Frames, error) {
+	//nolint:tagliatelle // js
+	var props struct {
+		Payload struct {
+			DeviceID   string `json:"deviceId"`
+			HardwareID string `json:"hardwareId"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(query.JSON, &props); err != nil {
+		return nil, fmt.Errorf("parse query properties: %w", err)
+	}
+
+	resp, err := d.enapterAPI.GetDeviceManifest(ctx, &GetDeviceManifestRequest{
+		User:       r.enapterUser,
+		DeviceID:   props.Payload.DeviceID,
+		HardwareID: props.Payload.HardwareID,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("get device manifest: %w", err)
@@ -195,7 +616,7 @@ func (d *DataSource) handleManifestQuery(
 }
 
 func (d *DataSource) handleTelemetryQuery(
-	ctx context.Context, user string, query backend.DataQuery,
+	ctx context.Context, r *requester, query backend.DataQuery,
 ) (data.Frames, error) {
 	var props struct {
 		Hide bool   `json:"hide"`
@@ -209,34 +630,20 @@ func (d *DataSource) handleTelemetryQuery(
 		return nil, nil
 	}
 
-	preparedQuery, err := d.prepareQuery(props.Text, query.Interval, query.TimeRange)
+	preparedQuery, err := d.prepareQuery(ctx, props.Text, query.Interval, query.TimeRange)
 	if err != nil {
 		return nil, fmt.Errorf("prepare query text: %w", err)
 	}
 
-	resp, err := d.enapterAPI.QueryTimeseries(ctx, &QueryTimeseriesRequest{
-		User:  user,
-		Query: preparedQuery.text,
-	})
+	endpoints, err := d.selectEndpoints(preparedQuery.endpoint)
 	if err != nil {
-		if errors.Is(err, ErrTimeseriesEmpty) {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("query timeseries: %w", err)
-	}
-	timeseries := resp.Timeseries
-	if offset := preparedQuery.offset; offset != 0 {
-		timeseries = timeseries.ShiftTime(preparedQuery.offset)
-	}
-
-	frame, err := d.timeseriesToDataFrame(timeseries)
-	if err != nil {
-		return nil, fmt.Errorf("convert timeseries to data frame: %w", err)
+		return nil, err
 	}
 
-	d.makeLabelsUnique(frame)
+	ctx, cancel := d.withTimeout(ctx, preparedQuery.timeout)
+	defer cancel()
 
-	return data.Frames{frame}, nil
+	return d.queryEndpoints(ctx, r, preparedQuery, endpoints)
 }
 
 func (d *DataSource) userFacingError(err error) error {
@@ -246,28 +653,102 @@ func (d *DataSource) userFacingError(err error) error {
 	if errors.Is(err, ErrInvalidOffset) {
 		return ErrInvalidOffset
 	}
+	if errors.Is(err, ErrInvalidTimeout) {
+		return ErrInvalidTimeout
+	}
+	if errors.Is(err, ErrInvalidEndpoint) {
+		return ErrInvalidEndpoint
+	}
+	if errors.Is(err, ErrCommandsDisabled) {
+		return ErrCommandsDisabled
+	}
+	if errors.Is(err, ErrCommandForbidden) {
+		return ErrCommandForbidden
+	}
+	if errors.Is(err, ErrDeviceSelectorNotSupported) {
+		return ErrDeviceSelectorNotSupported
+	}
+	if errors.Is(err, ErrCommandDebounced) {
+		return ErrCommandDebounced
+	}
+	if errors.Is(err, ErrIdempotencyKeyReused) {
+		return ErrIdempotencyKeyReused
+	}
+	if errors.Is(err, ErrAsyncCommandsNotSupported) {
+		return ErrAsyncCommandsNotSupported
+	}
+	if errors.Is(err, ErrCommandExecutionNotFound) {
+		return ErrCommandExecutionNotFound
+	}
+	if errors.Is(err, ErrHardwareIDNotFound) {
+		return ErrHardwareIDNotFound
+	}
+	if errors.Is(err, ErrHardwareIDNotSupported) {
+		return ErrHardwareIDNotSupported
+	}
+	if errors.Is(err, ErrDeviceIDMismatch) {
+		return ErrDeviceIDMismatch
+	}
+	if errors.Is(err, ErrBulkCommandHardwareID) {
+		return ErrBulkCommandHardwareID
+	}
+	if errors.Is(err, ErrNoDevicesSelected) {
+		return ErrNoDevicesSelected
+	}
+	if errors.Is(err, ErrRateLimitExceeded) {
+		return ErrRateLimitExceeded
+	}
+	if errors.Is(err, ErrEnapterAPIUnavailable) {
+		return ErrEnapterAPIUnavailable
+	}
+	if errors.Is(err, ErrAuditLogDisabled) {
+		return ErrAuditLogDisabled
+	}
+	if errors.Is(err, ErrAuditLogNotReadable) {
+		return ErrAuditLogNotReadable
+	}
+	if errors.Is(err, ErrAuditLogForbidden) {
+		return ErrAuditLogForbidden
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		return ErrUserNotFound
+	}
 
 	if e := (&yaml.TypeError{}); errors.As(err, &e) {
 		return ErrInvalidYAML
 	}
 
 	var apiError EnapterAPIError
-	if errors.As(err, &apiError) && len(apiError.Message) > 0 {
-		//nolint: goerr113 // user-facing
-		return errors.New(apiError.Message)
+	if errors.As(err, &apiError) {
+		var msgs []string
+		for _, e := range apiError.All() {
+			if len(e.Message) > 0 {
+				msgs = append(msgs, e.Message)
+			}
+		}
+		if len(msgs) > 0 {
+			//nolint: goerr113 // user-facing
+			return errors.New(strings.Join(msgs, "\n"))
+		}
 	}
 
 	return ErrSomethingWentWrong
 }
 
 type preparedQuery struct {
-	text   string
-	offset time.Duration
+	text    string
+	offset  time.Duration
+	timeout time.Duration
+	// endpoint is empty if the query is sent to every endpoint.
+	endpoint string
 }
 
 func (d *DataSource) prepareQuery(
-	text string, interval time.Duration, timeRange backend.TimeRange,
-) (*preparedQuery, error) {
+	ctx context.Context, text string, interval time.Duration, timeRange backend.TimeRange,
+) (_ *preparedQuery, retErr error) {
+	_, span := startSpan(ctx, "prepareQuery")
+	defer func() { endSpan(span, retErr) }()
+
 	dec := yaml.NewDecoder(strings.NewReader(text))
 
 	var obj map[string]any
@@ -294,6 +775,34 @@ func (d *DataSource) prepareQuery(
 		delete(obj, "@offset")
 	}
 
+	var timeout time.Duration
+	if timeoutInterface, ok := obj["@timeout"]; ok {
+		timeoutString, ok := timeoutInterface.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: unexpected type: want %T, have %T",
+				ErrInvalidTimeout, timeoutString, timeoutInterface)
+		}
+		var err error
+		timeout, err = time.ParseDuration(timeoutString)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTimeout, err)
+		}
+		if timeout <= 0 {
+			return nil, fmt.Errorf("%w: must be positive", ErrInvalidTimeout)
+		}
+		delete(obj, "@timeout")
+	}
+
+	var endpoint string
+	if endpointInterface, ok := obj["@endpoint"]; ok {
+		endpoint, ok = endpointInterface.(string)
+		if !ok || endpoint == "" {
+			return nil, fmt.Errorf("%w: unexpected value: %v",
+				ErrInvalidEndpoint, endpointInterface)
+		}
+		delete(obj, "@endpoint")
+	}
+
 	obj["from"] = from.Format(time.RFC3339Nano)
 	obj["to"] = to.Format(time.RFC3339Nano)
 
@@ -311,8 +820,10 @@ func (d *DataSource) prepareQuery(
 	}
 
 	return &preparedQuery{
-		text:   string(out),
-		offset: offset,
+		text:     string(out),
+		offset:   offset,
+		timeout:  timeout,
+		endpoint: endpoint,
 	}, nil
 }
 
@@ -361,6 +872,65 @@ func (d *DataSource) commandResponseToDataFrame(
 	}}, nil
 }
 
+func (d *DataSource) auditRecordsToDataFrame(
+	records []*AuditRecord,
+) (*data.Frame, error) {
+	n := len(records)
+	var (
+		timeValues        = make([]time.Time, n)
+		grafanaUserLogins = make([]string, n)
+		grafanaUserEmails = make([]string, n)
+		enapterUsers      = make([]string, n)
+		deviceIDs         = make([]string, n)
+		hardwareIDs       = make([]string, n)
+		commandNames      = make([]string, n)
+		commandArgs       = make([]json.RawMessage, n)
+		states            = make([]string, n)
+		payloads          = make([]json.RawMessage, n)
+		errorMessages     = make([]string, n)
+		latencies         = make([]float64, n)
+	)
+
+	for i, r := range records {
+		argsBytes, err := json.Marshal(r.CommandArgs)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: command args: %w", i, err)
+		}
+		payloadBytes, err := json.Marshal(r.Payload)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: payload: %w", i, err)
+		}
+
+		timeValues[i] = r.Time
+		grafanaUserLogins[i] = r.GrafanaUserLogin
+		grafanaUserEmails[i] = r.GrafanaUserEmail
+		enapterUsers[i] = r.EnapterUser
+		deviceIDs[i] = r.DeviceID
+		hardwareIDs[i] = r.HardwareID
+		commandNames[i] = r.CommandName
+		commandArgs[i] = argsBytes
+		states[i] = r.State
+		payloads[i] = payloadBytes
+		errorMessages[i] = r.Error
+		latencies[i] = float64(r.Latency) / float64(time.Millisecond)
+	}
+
+	return data.NewFrame("audit",
+		data.NewField("time", nil, timeValues),
+		data.NewField("grafana_user_login", nil, grafanaUserLogins),
+		data.NewField("grafana_user_email", nil, grafanaUserEmails),
+		data.NewField("enapter_user", nil, enapterUsers),
+		data.NewField("device_id", nil, deviceIDs),
+		data.NewField("hardware_id", nil, hardwareIDs),
+		data.NewField("command_name", nil, commandNames),
+		data.NewField("command_args", nil, commandArgs),
+		data.NewField("state", nil, states),
+		data.NewField("payload", nil, payloads),
+		data.NewField("error", nil, errorMessages),
+		data.NewField("latency_ms", nil, latencies),
+	), nil
+}
+
 func (d *DataSource) timeseriesToDataFrame(
 	timeseries *Timeseries,
 ) (*data.Frame, error) {
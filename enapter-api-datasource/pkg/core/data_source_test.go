@@ -4,14 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/rand"
 	"testing"
 	"time"
 
 	"github.com/bxcodec/faker/v3"
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/grafana/grafana-plugin-sdk-go/data"
-	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/suite"
 	"gopkg.in/yaml.v3"
 
@@ -21,9 +22,10 @@ import (
 type DataSourceSuite struct {
 	suite.Suite
 	ctx                   context.Context
-	logger                hclog.Logger
+	logger                log.Logger
 	mockEnapterAPIAdapter *MockEnapterAPIAdapter
 	mockUserResolver      *MockUserResolver
+	mockAuditLog          *MockAuditLog
 	dataSource            *core.DataSource
 }
 
@@ -31,11 +33,13 @@ func (s *DataSourceSuite) SetupSuite() {
 	s.ctx = context.Background()
 	s.mockEnapterAPIAdapter = NewMockEnapterAPIAdapter(&s.Suite)
 	s.mockUserResolver = NewMockUserResolver(&s.Suite)
-	s.logger = hclog.Default()
+	s.mockAuditLog = NewMockAuditLog(&s.Suite)
+	s.logger = log.DefaultLogger
 	s.dataSource = core.NewDataSource(core.DataSourceParams{
 		Logger:       s.logger,
 		EnapterAPI:   s.mockEnapterAPIAdapter,
 		UserResolver: s.mockUserResolver,
+		AuditLog:     s.mockAuditLog,
 	})
 }
 
@@ -108,6 +112,10 @@ func (s *DataSourceSuite) TestUserResolution() {
 			State:   faker.Word(),
 			Payload: nil,
 		}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(func(r *core.AuditRecord) {
+		s.Require().Equal(req.user, r.GrafanaUserEmail)
+		s.Require().Equal("other_"+req.user, r.EnapterUser)
+	}, nil)
 	_, err := s.handleDataRequestWithSingleQuery(req)
 	s.Require().NoError(err)
 }
@@ -115,7 +123,7 @@ func (s *DataSourceSuite) TestUserResolution() {
 func (s *DataSourceSuite) TestUserResolutionError() {
 	req := s.randomDataRequestWithSingleCommandQuery()
 	s.expectResolveUserAndReturn(req.user, "", errFake)
-	_, err := s.dataSource.QueryData(s.ctx, &backend.QueryDataRequest{
+	resp, err := s.dataSource.QueryData(s.ctx, &backend.QueryDataRequest{
 		PluginContext: backend.PluginContext{
 			User: &backend.User{
 				Email: req.user,
@@ -137,7 +145,16 @@ func (s *DataSourceSuite) TestUserResolutionError() {
 			}),
 		}},
 	})
-	s.Require().ErrorIs(err, errFake)
+	s.Require().NoError(err)
+	queryResp := resp.Responses[req.queries[0].refID]
+	s.Require().ErrorIs(queryResp.Error, core.ErrSomethingWentWrong)
+	s.Require().Equal(backend.StatusInternal, queryResp.Status)
+}
+
+func (s *DataSourceSuite) TestInvalidResolvedUserID() {
+	user := faker.Email()
+	s.expectResolveUserAndReturn(user, "e2a8\r\nX-Enapter-Auth-Token: stolen", nil)
+	s.Require().ErrorIs(s.queryDataAs(user), core.ErrSomethingWentWrong)
 }
 
 func (s *DataSourceSuite) TestCommandRequest() {
@@ -152,6 +169,12 @@ func (s *DataSourceSuite) TestCommandRequest() {
 		State:   stateIn,
 		Payload: payloadIn,
 	}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(func(r *core.AuditRecord) {
+		s.Require().Equal(req.queries[0].payload["commandName"], r.CommandName)
+		s.Require().Equal(stateIn, r.State)
+		s.Require().Equal(payloadIn, r.Payload)
+		s.Require().Empty(r.Error)
+	}, nil)
 	frames, err := s.handleDataRequestWithSingleQuery(req)
 	s.Require().NoError(err)
 	stateOut, payloadOut := s.extractCommandResponse(frames)
@@ -159,6 +182,169 @@ func (s *DataSourceSuite) TestCommandRequest() {
 	s.Require().Equal(payloadIn, payloadOut)
 }
 
+func (s *DataSourceSuite) TestCommandRequestErrorIsAudited() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, nil, errFake)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(func(r *core.AuditRecord) {
+		s.Require().Equal(req.user, r.EnapterUser)
+		s.Require().Equal(req.queries[0].payload["deviceId"], r.DeviceID)
+		s.Require().Contains(r.Error, errFake.Error())
+	}, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrSomethingWentWrong)
+}
+
+func (s *DataSourceSuite) TestCommandRequestAuditLogErrorIsIgnored() {
+	req := s.randomDataRequestWithSingleCommandQuery()
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectExecuteCommandAndReturn(req, &core.ExecuteCommandResponse{
+		State: faker.Word(),
+	}, nil)
+	s.mockAuditLog.ExpectWriteAuditRecordCheckItAndReturn(
+		func(*core.AuditRecord) {}, errFake)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+}
+
+func (s *DataSourceSuite) TestAuditRequest() {
+	req := dataRequest{
+		user: faker.Email(),
+		queries: []query{{
+			refID:     s.randomRefID(),
+			queryType: "audit",
+			from:      time.Unix(5, 0),
+			to:        time.Unix(10, 0),
+			payload: map[string]any{
+				"deviceId": "dev",
+			},
+		}},
+	}
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockAuditLog.ExpectReadAuditRecordsAndReturn(&core.ReadAuditRecordsRequest{
+		From:     time.Unix(5, 0).UTC(),
+		To:       time.Unix(10, 0).UTC(),
+		DeviceID: "dev",
+		Limit:    1000,
+	}, &core.ReadAuditRecordsResponse{
+		Records: []*core.AuditRecord{{
+			Time:        time.Unix(7, 0),
+			EnapterUser: "jack",
+			DeviceID:    "dev",
+			CommandName: "reboot",
+			State:       "succeeded",
+			Latency:     1500 * time.Millisecond,
+		}},
+	}, nil)
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+	s.Require().Len(frames, 1)
+	frame := frames[0]
+	s.Require().Equal(1, frame.Rows())
+	for name, value := range map[string]any{
+		"time":         time.Unix(7, 0),
+		"enapter_user": "jack",
+		"device_id":    "dev",
+		"command_name": "reboot",
+		"state":        "succeeded",
+		"latency_ms":   float64(1500),
+	} {
+		field, _ := frame.FieldByName(name)
+		s.Require().NotNil(field, name)
+		s.Require().Equal(value, field.At(0), name)
+	}
+}
+
+func (s *DataSourceSuite) TestAuditRequestDisabled() {
+	req := dataRequest{
+		user: faker.Email(),
+		queries: []query{{
+			refID:     s.randomRefID(),
+			queryType: "audit",
+		}},
+	}
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockAuditLog.ExpectReadAuditRecordsAndReturn(&core.ReadAuditRecordsRequest{
+		From:  time.Time{}.UTC(),
+		To:    time.Time{}.UTC(),
+		Limit: 1000,
+	}, nil, core.ErrAuditLogDisabled)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrAuditLogDisabled)
+}
+
+func (s *DataSourceSuite) TestAuditRequestForbidden() {
+	orig := s.dataSource
+	defer func() { s.dataSource = orig }()
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:          s.logger,
+		EnapterAPI:      s.mockEnapterAPIAdapter,
+		UserResolver:    s.mockUserResolver,
+		AuditLog:        s.mockAuditLog,
+		AuditLogMinRole: core.RoleAdmin,
+	})
+
+	req := dataRequest{
+		user: faker.Email(),
+		queries: []query{{
+			refID:     s.randomRefID(),
+			queryType: "audit",
+		}},
+	}
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrAuditLogForbidden)
+}
+
+func (s *DataSourceSuite) TestManifestRequestByHardwareID() {
+	req := dataRequest{
+		user: faker.Email(),
+		queries: []query{{
+			refID:     s.randomRefID(),
+			queryType: "manifest",
+			payload: map[string]any{
+				"hardwareId": faker.Word(),
+			},
+		}},
+	}
+	manifest := `{"blueprint_spec":"device/1.0"}`
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockEnapterAPIAdapter.ExpectGetDeviceManifestAndReturn(
+		&core.GetDeviceManifestRequest{
+			User:       req.user,
+			HardwareID: req.queries[0].payload["hardwareId"].(string),
+		}, &core.GetDeviceManifestResponse{
+			Manifest: []byte(manifest),
+		}, nil)
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+	s.Require().Len(frames, 1)
+	s.Require().JSONEq(manifest, string(frames[0].Fields[0].At(0).(json.RawMessage)))
+}
+
+func (s *DataSourceSuite) TestManifestRequestDeviceIDMismatch() {
+	req := dataRequest{
+		user: faker.Email(),
+		queries: []query{{
+			refID:     s.randomRefID(),
+			queryType: "manifest",
+			payload: map[string]any{
+				"deviceId":   faker.Word(),
+				"hardwareId": faker.Word(),
+			},
+		}},
+	}
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.mockEnapterAPIAdapter.ExpectGetDeviceManifestAndReturn(
+		&core.GetDeviceManifestRequest{
+			User:       req.user,
+			DeviceID:   req.queries[0].payload["deviceId"].(string),
+			HardwareID: req.queries[0].payload["hardwareId"].(string),
+		}, nil, fmt.Errorf("resolve device ID: %w", core.ErrDeviceIDMismatch))
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrDeviceIDMismatch)
+}
+
 func (s *DataSourceSuite) TestTelemetryAPIError() {
 	req := s.randomDataRequestWithSingleTelemetryQuery()
 	s.expectResolveUserAndReturn(req.user, req.user, nil)
@@ -220,6 +406,31 @@ func (s *DataSourceSuite) TestEnapterAPIError() {
 	s.Require().Equal("Houston, we have a problem.", err.Error())
 }
 
+func (s *DataSourceSuite) TestEnapterAPIMultipleErrors() {
+	req := s.randomDataRequestWithSingleTelemetryQuery()
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectQueryTimeseriesAndReturn(req, nil, core.EnapterAPIError{
+		Code:    "not_found",
+		Message: "Attribute foo not found.",
+		Details: map[string]any{"attribute": "foo"},
+		More: []core.EnapterAPIError{{
+			Code:    "not_found",
+			Message: "Attribute bar not found.",
+			Details: map[string]any{"attribute": "bar"},
+		}},
+	})
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().Error(err)
+	s.Require().Equal("Attribute foo not found.\nAttribute bar not found.", err.Error())
+	s.Require().Len(frames, 1)
+	notices := frames[0].Meta.Notices
+	s.Require().Len(notices, 2)
+	s.Require().Equal(data.NoticeSeverityError, notices[1].Severity)
+	s.Require().Contains(notices[1].Text, "Attribute bar not found.")
+	s.Require().Contains(notices[1].Text, "not_found")
+	s.Require().Contains(notices[1].Text, `"attribute":"bar"`)
+}
+
 func (s *DataSourceSuite) TestInvalidOffset() {
 	req := dataRequest{
 		user: faker.Email(),
@@ -716,6 +927,8 @@ func (s *DataSourceSuite) queryTextWithTimeRange(q query) string {
 		obj["from"] = q.from.UTC().Format(time.RFC3339Nano)
 		obj["to"] = q.to.UTC().Format(time.RFC3339Nano)
 		delete(obj, "@offset")
+		delete(obj, "@timeout")
+		delete(obj, "@endpoint")
 	}
 
 	out, err := json.Marshal(obj)
@@ -804,7 +1017,7 @@ func (s *DataSourceSuite) handleDataRequest(req dataRequest) backend.Responses {
 		PluginContext: backend.PluginContext{
 			User: user,
 		},
-		Headers: nil,
+		Headers: req.headers,
 		Queries: queries,
 	})
 	s.Require().NoError(err)
@@ -820,6 +1033,7 @@ func (s *DataSourceSuite) shouldMarshalJSON(o any) []byte {
 
 type dataRequest struct {
 	user    string
+	headers map[string]string
 	queries []query
 }
 
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+//nolint:tagliatelle // js
+type enapterAPIErrorJSON struct {
+	Code    string         `json:"code"`
+	Message string         `json:"message,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// enapterAPIErrorFrames describes every error reported by Enapter API in a
+// frame without fields, so that the response carries them in a structured
+// form along with the joined error message and the source of the failure.
+func enapterAPIErrorFrames(err error, source ErrorSource) data.Frames {
+	var apiErr EnapterAPIError
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+
+	all := apiErr.All()
+	notices := make([]data.Notice, len(all))
+	errs := make([]enapterAPIErrorJSON, len(all))
+	for i, e := range all {
+		notices[i] = data.Notice{
+			Severity: data.NoticeSeverityError,
+			Text:     e.noticeText(),
+		}
+		errs[i] = enapterAPIErrorJSON{
+			Code:    e.Code,
+			Message: e.Message,
+			Details: e.Details,
+		}
+	}
+
+	return data.Frames{
+		data.NewFrame("").SetMeta(&data.FrameMeta{
+			Notices: notices,
+			Custom: map[string]any{
+				"errors":      errs,
+				"errorSource": source,
+			},
+		}),
+	}
+}
+
+func (e EnapterAPIError) noticeText() string {
+	text := e.Message
+	if text == "" {
+		text = "Enapter API error"
+	}
+	text += fmt.Sprintf(" (code: %s", e.Code)
+	if len(e.Details) > 0 {
+		if details, err := json.Marshal(e.Details); err == nil {
+			text += fmt.Sprintf(", details: %s", details)
+		}
+	}
+	return text + ")"
+}
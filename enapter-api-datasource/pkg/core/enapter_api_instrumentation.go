@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
+)
+
+// instrumentedEnapterAPI decorates EnapterAPIPort tracing the requests sent
+// to the API and recording their metrics by operation.
+type instrumentedEnapterAPI struct {
+	EnapterAPIPort
+	metrics *metrics.DataSource
+}
+
+func instrumentedEnapterAPICall[Req, Resp any](
+	ctx context.Context, m *instrumentedEnapterAPI, operation string, req Req,
+	fn func(context.Context, Req) (Resp, error),
+) (_ Resp, retErr error) {
+	ctx, span := startSpan(ctx, "EnapterAPI."+operation)
+	defer func() { endSpan(span, retErr) }()
+
+	start := time.Now()
+	resp, err := fn(ctx, req)
+
+	status := backend.StatusOK
+	var errorCode string
+	if err != nil && !errors.Is(err, ErrTimeseriesEmpty) {
+		status, _ = classifyError(err)
+		var apiErr EnapterAPIError
+		if errors.As(err, &apiErr) {
+			errorCode = apiErr.Code
+		}
+	}
+	m.metrics.ObserveEnapterAPIRequest(operation, int(status), errorCode, time.Since(start))
+	span.SetAttributes(attribute.Int("status", int(status)))
+	if errorCode != "" {
+		span.SetAttributes(attribute.String("error_code", errorCode))
+	}
+
+	return resp, err
+}
+
+func (m *instrumentedEnapterAPI) QueryTimeseries(
+	ctx context.Context, req *QueryTimeseriesRequest,
+) (*QueryTimeseriesResponse, error) {
+	return instrumentedEnapterAPICall(ctx, m, "query_timeseries", req,
+		m.EnapterAPIPort.QueryTimeseries)
+}
+
+func (m *instrumentedEnapterAPI) ExecuteCommand(
+	ctx context.Context, req *ExecuteCommandRequest,
+) (*ExecuteCommandResponse, error) {
+	return instrumentedEnapterAPICall(ctx, m, "execute_command", req,
+		m.EnapterAPIPort.ExecuteCommand)
+}
+
+func (m *instrumentedEnapterAPI) GetDeviceManifest(
+	ctx context.Context, req *GetDeviceManifestRequest,
+) (*GetDeviceManifestResponse, error) {
+	return instrumentedEnapterAPICall(ctx, m, "get_device_manifest", req,
+		m.EnapterAPIPort.GetDeviceManifest)
+}
+
+func (m *instrumentedEnapterAPI) ListDevices(
+	ctx context.Context, req *ListDevicesRequest,
+) (*ListDevicesResponse, error) {
+	return instrumentedEnapterAPICall(ctx, m, "list_devices", req,
+		m.EnapterAPIPort.ListDevices)
+}
+
+func (m *instrumentedEnapterAPI) StartCommandExecution(
+	ctx context.Context, req *ExecuteCommandRequest,
+) (*StartCommandExecutionResponse, error) {
+	return instrumentedEnapterAPICall(ctx, m, "start_command_execution", req,
+		m.EnapterAPIPort.StartCommandExecution)
+}
+
+func (m *instrumentedEnapterAPI) GetCommandExecution(
+	ctx context.Context, req *GetCommandExecutionRequest,
+) (*GetCommandExecutionResponse, error) {
+	return instrumentedEnapterAPICall(ctx, m, "get_command_execution", req,
+		m.EnapterAPIPort.GetCommandExecution)
+}
+
+func (m *instrumentedEnapterAPI) ResolveDeviceID(
+	ctx context.Context, req *ResolveDeviceIDRequest,
+) (*ResolveDeviceIDResponse, error) {
+	return instrumentedEnapterAPICall(ctx, m, "resolve_device_id", req,
+		m.EnapterAPIPort.ResolveDeviceID)
+}
@@ -0,0 +1,89 @@
+package core_test
+
+import (
+	"net/http"
+
+	"github.com/bxcodec/faker/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
+)
+
+func (s *DataSourceSuite) TestMetrics() {
+	uid := faker.UUIDHyphenated()
+	defer s.useDataSourceWithMetrics(metrics.ForDataSource(uid))()
+
+	s.handleTelemetryQueryFailedWith(core.EnapterAPIError{
+		Code:       "not_found",
+		StatusCode: http.StatusNotFound,
+	})
+
+	s.Require().Equal(1.0, s.gatherCounter(
+		"enapter_api_datasource_enapter_api_requests_total", map[string]string{
+			"datasource": uid,
+			"operation":  "query_timeseries",
+			"status":     "404",
+			"error_code": "not_found",
+		}))
+	s.Require().Equal(uint64(1), s.gatherHistogramCount(
+		"enapter_api_datasource_query_duration_seconds", map[string]string{
+			"datasource": uid,
+			"query_type": "telemetry",
+			"status":     "404",
+		}))
+	s.Require().Equal(uint64(1), s.gatherHistogramCount(
+		"enapter_api_datasource_user_resolution_duration_seconds", map[string]string{
+			"datasource": uid,
+			"result":     "ok",
+		}))
+}
+
+func (s *DataSourceSuite) useDataSourceWithMetrics(
+	m *metrics.DataSource,
+) (restore func()) {
+	orig := s.dataSource
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:       s.logger,
+		EnapterAPI:   s.mockEnapterAPIAdapter,
+		UserResolver: s.mockUserResolver,
+		AuditLog:     s.mockAuditLog,
+		Metrics:      m,
+	})
+	return func() { s.dataSource = orig }
+}
+
+func (s *DataSourceSuite) gatherCounter(name string, labels map[string]string) float64 {
+	if m := s.gatherMetric(name, labels); m != nil {
+		return m.GetCounter().GetValue()
+	}
+	return 0
+}
+
+func (s *DataSourceSuite) gatherHistogramCount(name string, labels map[string]string) uint64 {
+	if m := s.gatherMetric(name, labels); m != nil {
+		return m.GetHistogram().GetSampleCount()
+	}
+	return 0
+}
+
+func (s *DataSourceSuite) gatherMetric(name string, labels map[string]string) *dto.Metric {
+	families, err := prometheus.DefaultGatherer.Gather()
+	s.Require().NoError(err)
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if labels[l.GetName()] != l.GetValue() {
+					continue metrics
+				}
+			}
+			return m
+		}
+	}
+	return nil
+}
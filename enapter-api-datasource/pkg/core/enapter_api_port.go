@@ -3,7 +3,7 @@ package core
 import "context"
 
 type EnapterAPIPort interface {
-	Ready(context.Context) error
+	HealthChecks() []HealthCheck
 	QueryTimeseries(
 		context.Context, *QueryTimeseriesRequest,
 	) (*QueryTimeseriesResponse, error)
@@ -13,6 +13,18 @@ type EnapterAPIPort interface {
 	GetDeviceManifest(
 		context.Context, *GetDeviceManifestRequest,
 	) (*GetDeviceManifestResponse, error)
+	ListDevices(
+		context.Context, *ListDevicesRequest,
+	) (*ListDevicesResponse, error)
+	StartCommandExecution(
+		context.Context, *ExecuteCommandRequest,
+	) (*StartCommandExecutionResponse, error)
+	GetCommandExecution(
+		context.Context, *GetCommandExecutionRequest,
+	) (*GetCommandExecutionResponse, error)
+	ResolveDeviceID(
+		context.Context, *ResolveDeviceIDRequest,
+	) (*ResolveDeviceIDResponse, error)
 }
 
 type QueryTimeseriesRequest struct {
@@ -37,11 +49,57 @@ type ExecuteCommandResponse struct {
 	Payload map[string]any
 }
 
-type GetDeviceManifestRequest struct {
-	User     string
+type StartCommandExecutionResponse struct {
+	// DeviceID is set even if the command has been addressed by hardware ID.
+	DeviceID    string
+	ExecutionID string
+}
+
+type GetCommandExecutionRequest struct {
+	User        string
+	DeviceID    string
+	ExecutionID string
+}
+
+type GetCommandExecutionResponse struct {
+	State   string
+	Payload map[string]any
+}
+
+type ResolveDeviceIDRequest struct {
+	User       string
+	DeviceID   string
+	HardwareID string
+}
+
+type ResolveDeviceIDResponse struct {
 	DeviceID string
 }
 
+type GetDeviceManifestRequest struct {
+	User       string
+	DeviceID   string
+	HardwareID string
+}
+
 type GetDeviceManifestResponse struct {
 	Manifest []byte
 }
+
+type ListDevicesRequest struct {
+	User        string
+	SiteID      string
+	BlueprintID string
+}
+
+type ListDevicesResponse struct {
+	Devices []Device
+}
+
+type Device struct {
+	ID          string
+	HardwareID  string
+	Name        string
+	SiteID      string
+	BlueprintID string
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+)
+
+// Endpoint is an additional Enapter API, e.g. the gateway of a single site,
+// telemetry queries are federated across.
+type Endpoint struct {
+	Name       string
+	EnapterAPI EnapterAPIPort
+}
+
+// endpointLabelName labels the timeseries of federated telemetry queries
+// with the endpoint they come from.
+const endpointLabelName = "endpoint"
+
+type endpoint struct {
+	name       string
+	enapterAPI EnapterAPIPort
+}
+
+// selectEndpoints returns the endpoints a telemetry query is sent to. Queries
+// not naming an endpoint fan out to all of them. Without federation the only
+// endpoint is unnamed, so that the timeseries are not labeled.
+func (d *DataSource) selectEndpoints(name string) ([]endpoint, error) {
+	if name == "" {
+		return d.allEndpoints(), nil
+	}
+	for _, e := range d.endpoints {
+		if e.name == name {
+			return []endpoint{e}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidEndpoint, name)
+}
+
+func (d *DataSource) allEndpoints() []endpoint {
+	if len(d.endpoints) == 0 {
+		return []endpoint{{enapterAPI: d.enapterAPI}}
+	}
+	return d.endpoints
+}
+
+func (d *DataSource) queryEndpoints(
+	ctx context.Context, r *requester, q *preparedQuery, endpoints []endpoint,
+) (data.Frames, error) {
+	if len(endpoints) == 1 {
+		frame, err := d.queryEndpoint(ctx, r, q, endpoints[0])
+		if err != nil || frame == nil {
+			return nil, err
+		}
+		return data.Frames{frame}, nil
+	}
+
+	frames := make([]*data.Frame, len(endpoints))
+	errs := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			frames[i], errs[i] = d.queryEndpoint(ctx, r, q, e)
+		}()
+	}
+	wg.Wait()
+
+	var out data.Frames
+	var notices []data.Notice
+	var firstErr error
+	for i, e := range endpoints {
+		if err := errs[i]; err != nil {
+			d.logger.Warn("federated telemetry query failed",
+				"endpoint", e.name,
+				"error", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			notices = append(notices, data.Notice{
+				Severity: data.NoticeSeverityWarning,
+				Text: fmt.Sprintf("Endpoint %q: %s",
+					e.name, d.userFacingError(err)),
+			})
+			continue
+		}
+		if frames[i] != nil {
+			out = append(out, frames[i])
+		}
+	}
+
+	// A failing site must not hide the others, unless there is nothing left
+	// to show.
+	if len(out) == 0 {
+		return nil, firstErr
+	}
+	out[0].AppendNotices(notices...)
+	return out, nil
+}
+
+func (d *DataSource) queryEndpoint(
+	ctx context.Context, r *requester, q *preparedQuery, e endpoint,
+) (*data.Frame, error) {
+	resp, err := e.enapterAPI.QueryTimeseries(ctx, &QueryTimeseriesRequest{
+		User:  r.enapterUser,
+		Query: q.text,
+	})
+	if err != nil {
+		if errors.Is(err, ErrTimeseriesEmpty) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("query timeseries: %w", err)
+	}
+	timeseries := resp.Timeseries
+	if offset := q.offset; offset != 0 {
+		timeseries = timeseries.ShiftTime(q.offset)
+	}
+
+	frame, err := d.timeseriesToDataFrame(timeseries)
+	if err != nil {
+		return nil, fmt.Errorf("convert timeseries to data frame: %w", err)
+	}
+
+	d.makeLabelsUnique(frame)
+
+	// The label is added after the others have been made unique, because it
+	// is shared by the whole frame.
+	if e.name != "" {
+		const oneForTimeField = 1
+		for _, field := range frame.Fields[oneForTimeField:] {
+			if field.Labels == nil {
+				field.Labels = data.Labels{}
+			}
+			field.Labels[endpointLabelName] = e.name
+		}
+	}
+
+	return frame, nil
+}
@@ -0,0 +1,179 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"time"
+
+	"github.com/bxcodec/faker/v3"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/data"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestFederatedQueryFansOut() {
+	plantB := NewMockEnapterAPIAdapter(&s.Suite)
+	defer s.useDataSourceWithEndpoint("plant-b", plantB)()
+
+	req := s.dataRequestWithEndpoint("")
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectQueryTimeseriesAndReturn(req, &core.QueryTimeseriesResponse{
+		Timeseries: s.singleFloatTimeseries(42),
+	}, nil)
+	plantB.ExpectQueryTimeseriesAndReturn(&core.QueryTimeseriesRequest{
+		User:  req.user,
+		Query: s.queryTextWithTimeRange(req.queries[0]),
+	}, &core.QueryTimeseriesResponse{
+		Timeseries: s.singleFloatTimeseries(43),
+	}, nil)
+
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+	s.Require().Equal(map[string]float64{
+		"plant-a": 42,
+		"plant-b": 43,
+	}, s.extractEndpointValues(frames))
+}
+
+func (s *DataSourceSuite) TestFederatedQueryTargetsEndpoint() {
+	plantB := NewMockEnapterAPIAdapter(&s.Suite)
+	defer s.useDataSourceWithEndpoint("plant-b", plantB)()
+
+	req := s.dataRequestWithEndpoint("plant-b")
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	plantB.ExpectQueryTimeseriesAndReturn(&core.QueryTimeseriesRequest{
+		User:  req.user,
+		Query: s.queryTextWithTimeRange(req.queries[0]),
+	}, &core.QueryTimeseriesResponse{
+		Timeseries: s.singleFloatTimeseries(43),
+	}, nil)
+
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+	s.Require().Equal(map[string]float64{
+		"plant-b": 43,
+	}, s.extractEndpointValues(frames))
+}
+
+func (s *DataSourceSuite) TestFederatedQueryReportsFailedEndpoint() {
+	plantB := NewMockEnapterAPIAdapter(&s.Suite)
+	defer s.useDataSourceWithEndpoint("plant-b", plantB)()
+
+	req := s.dataRequestWithEndpoint("")
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectQueryTimeseriesAndReturn(req, &core.QueryTimeseriesResponse{
+		Timeseries: s.singleFloatTimeseries(42),
+	}, nil)
+	plantB.ExpectQueryTimeseriesAndReturn(&core.QueryTimeseriesRequest{
+		User:  req.user,
+		Query: s.queryTextWithTimeRange(req.queries[0]),
+	}, nil, errFake)
+
+	frames, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().NoError(err)
+	s.Require().Equal(map[string]float64{
+		"plant-a": 42,
+	}, s.extractEndpointValues(frames))
+	s.Require().Len(frames[0].Meta.Notices, 1)
+	s.Require().Contains(frames[0].Meta.Notices[0].Text, `"plant-b"`)
+}
+
+func (s *DataSourceSuite) TestFederatedQueryUnknownEndpoint() {
+	defer s.useDataSourceWithEndpoint("plant-b", NewMockEnapterAPIAdapter(&s.Suite))()
+
+	req := s.dataRequestWithEndpoint("plant-c")
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrInvalidEndpoint)
+}
+
+func (s *DataSourceSuite) TestCheckHealthReportsEveryEndpoint() {
+	plantB := NewMockEnapterAPIAdapter(&s.Suite)
+	plantB.healthChecks = []core.HealthCheck{{
+		Name:  "connection",
+		Check: func(context.Context) error { return errFake },
+	}}
+	defer s.useHealthChecks(core.HealthCheck{
+		Name:  "connection",
+		Check: func(context.Context) error { return nil },
+	})()
+	defer s.useDataSourceWithEndpoint("plant-b", plantB)()
+
+	result, err := s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Equal(backend.HealthStatusError, result.Status)
+	s.Require().Contains(result.Message, "plant-b/connection: ")
+
+	var details healthCheckDetails
+	s.Require().NoError(json.Unmarshal(result.JSONDetails, &details))
+	statuses := make(map[string]string)
+	for _, c := range details.Checks {
+		statuses[c.Name] = c.Status
+	}
+	s.Require().Equal(map[string]string{
+		"plant-a/connection": "ok",
+		"plant-a/token":      "ok",
+		"plant-b/connection": "error",
+		"plant-b/token":      "skipped",
+		"user_resolver":      "skipped",
+	}, statuses)
+}
+
+func (s *DataSourceSuite) dataRequestWithEndpoint(endpoint string) dataRequest {
+	obj := map[string]any{
+		"granularity": "42s",
+		"aggregation": "auto",
+	}
+	if endpoint != "" {
+		obj["@endpoint"] = endpoint
+	}
+	return dataRequest{
+		user: faker.Email(),
+		queries: []query{{
+			refID:    s.randomRefID(),
+			from:     time.Now().Add(-time.Duration(rand.Int()+1) * time.Hour),
+			to:       time.Now().Add(-time.Duration(rand.Int()+1) * time.Minute),
+			interval: time.Duration(rand.Int()) * time.Second,
+			text:     string(s.shouldMarshalJSON(obj)),
+		}},
+	}
+}
+
+func (s *DataSourceSuite) singleFloatTimeseries(v float64) *core.Timeseries {
+	return &core.Timeseries{
+		TimeField: []time.Time{time.Unix(1, 0)},
+		DataFields: []*core.TimeseriesDataField{{
+			Type:   core.TimeseriesDataTypeFloat,
+			Values: []any{newFloat64(v)},
+		}},
+	}
+}
+
+func (s *DataSourceSuite) extractEndpointValues(frames data.Frames) map[string]float64 {
+	values := make(map[string]float64)
+	for _, f := range frames {
+		s.Require().Len(f.Fields, 2)
+		field := f.Fields[1]
+		values[field.Labels["endpoint"]] = *field.At(0).(*float64)
+	}
+	return values
+}
+
+// useDataSourceWithEndpoint federates the mock Enapter API, named plant-a,
+// with another one.
+func (s *DataSourceSuite) useDataSourceWithEndpoint(
+	name string, api core.EnapterAPIPort,
+) (restore func()) {
+	orig := s.dataSource
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:         s.logger,
+		EnapterAPI:     s.mockEnapterAPIAdapter,
+		EnapterAPIName: "plant-a",
+		Endpoints:      []core.Endpoint{{Name: name, EnapterAPI: api}},
+		UserResolver:   s.mockUserResolver,
+		AuditLog:       s.mockAuditLog,
+	})
+	return func() { s.dataSource = orig }
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrorSource tells whether a query has failed because of the plugin itself
+// or because of a service it depends on.
+type ErrorSource string
+
+const (
+	ErrorSourcePlugin     ErrorSource = "plugin"
+	ErrorSourceDownstream ErrorSource = "downstream"
+)
+
+// statusClientClosedRequest is reported when the query has been cancelled
+// before it was complete.
+const statusClientClosedRequest backend.Status = 499
+
+// httpStatusCoder is implemented by errors derived from HTTP responses.
+type httpStatusCoder interface {
+	HTTPStatusCode() int
+}
+
+//nolint:gochecknoglobals // read-only
+var enapterAPIErrorCodeStatuses = map[string]backend.Status{
+	"unauthorized":                   backend.StatusUnauthorized,
+	"forbidden":                      backend.StatusForbidden,
+	"access_denied":                  backend.StatusForbidden,
+	"not_found":                      backend.StatusNotFound,
+	"unprocessable_entity":           backend.StatusValidationFailed,
+	"invalid_query_parameter_format": backend.StatusBadRequest,
+	"bad_request":                    backend.StatusBadRequest,
+	"too_many_requests":              backend.StatusTooManyRequests,
+	"rate_limit_exceeded":            backend.StatusTooManyRequests,
+}
+
+// classifyError returns the status of the query response failed with the
+// given error along with the source of the failure.
+//
+//nolint:cyclop // flat mapping
+func classifyError(err error) (backend.Status, ErrorSource) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest, ErrorSourceDownstream
+	case errors.Is(err, context.DeadlineExceeded):
+		return backend.StatusTimeout, ErrorSourceDownstream
+	}
+
+	var apiErr EnapterAPIError
+	if errors.As(err, &apiErr) {
+		if status, ok := enapterAPIErrorCodeStatuses[apiErr.Code]; ok {
+			return status, ErrorSourceDownstream
+		}
+	}
+
+	var coder httpStatusCoder
+	if errors.As(err, &coder) && coder.HTTPStatusCode() != 0 {
+		return httpStatusToBackendStatus(coder.HTTPStatusCode()), ErrorSourceDownstream
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return backend.StatusTimeout, ErrorSourceDownstream
+		}
+		return backend.StatusBadGateway, ErrorSourceDownstream
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return backend.StatusBadGateway, ErrorSourceDownstream
+	}
+
+	if status, ok := userFacingErrorStatus(err); ok {
+		return status, ErrorSourceDownstream
+	}
+
+	if errors.Is(err, errUnsupportedTimeseriesDataType) {
+		return backend.StatusNotImplemented, ErrorSourcePlugin
+	}
+
+	return backend.StatusInternal, ErrorSourcePlugin
+}
+
+func httpStatusToBackendStatus(code int) backend.Status {
+	switch {
+	case code == http.StatusUnauthorized:
+		return backend.StatusUnauthorized
+	case code == http.StatusForbidden:
+		return backend.StatusForbidden
+	case code == http.StatusNotFound:
+		return backend.StatusNotFound
+	case code == http.StatusUnprocessableEntity:
+		return backend.StatusValidationFailed
+	case code == http.StatusTooManyRequests:
+		return backend.StatusTooManyRequests
+	case code == http.StatusGatewayTimeout:
+		return backend.StatusTimeout
+	case code >= http.StatusInternalServerError:
+		return backend.StatusBadGateway
+	case code >= http.StatusBadRequest:
+		return backend.StatusBadRequest
+	default:
+		return backend.StatusBadGateway
+	}
+}
+
+// userFacingErrorStatus handles errors caused by the query itself or by
+// the data source configuration rather than by a failure of the plugin.
+func userFacingErrorStatus(err error) (backend.Status, bool) {
+	if e := (&yaml.TypeError{}); errors.As(err, &e) {
+		return backend.StatusBadRequest, true
+	}
+
+	for _, s := range []struct {
+		err    error
+		status backend.Status
+	}{
+		{ErrInvalidOffset, backend.StatusBadRequest},
+		{ErrInvalidTimeout, backend.StatusBadRequest},
+		{ErrInvalidEndpoint, backend.StatusBadRequest},
+		{ErrDeviceSelectorNotSupported, backend.StatusBadRequest},
+		{ErrIdempotencyKeyReused, backend.StatusBadRequest},
+		{ErrAsyncCommandsNotSupported, backend.StatusBadRequest},
+		{ErrHardwareIDNotSupported, backend.StatusBadRequest},
+		{ErrDeviceIDMismatch, backend.StatusBadRequest},
+		{ErrBulkCommandHardwareID, backend.StatusBadRequest},
+		{ErrNoDevicesSelected, backend.StatusBadRequest},
+		{ErrOAuthTokenMissing, backend.StatusUnauthorized},
+		{ErrUserNotFound, backend.StatusForbidden},
+		{ErrCommandsDisabled, backend.StatusForbidden},
+		{ErrCommandForbidden, backend.StatusForbidden},
+		{ErrAuditLogForbidden, backend.StatusForbidden},
+		{ErrCommandDebounced, backend.StatusTooManyRequests},
+		{ErrRateLimitExceeded, backend.StatusTooManyRequests},
+		{ErrEnapterAPIUnavailable, backend.StatusBadGateway},
+		{ErrCommandExecutionNotFound, backend.StatusNotFound},
+		{ErrHardwareIDNotFound, backend.StatusNotFound},
+		{ErrAuditLogDisabled, backend.StatusNotFound},
+		{ErrAuditLogNotReadable, backend.StatusNotImplemented},
+	} {
+		if errors.Is(err, s.err) {
+			return s.status, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,78 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestErrorStatusEnapterAPIErrorCode() {
+	resp := s.handleTelemetryQueryFailedWith(core.EnapterAPIError{
+		Code:       "not_found",
+		Message:    "Attribute foo not found.",
+		StatusCode: http.StatusBadRequest,
+	})
+	s.Require().Equal(backend.StatusNotFound, resp.Status)
+	s.Require().Len(resp.Frames, 1)
+	custom, ok := resp.Frames[0].Meta.Custom.(map[string]any)
+	s.Require().True(ok)
+	s.Require().Equal(core.ErrorSourceDownstream, custom["errorSource"])
+}
+
+func (s *DataSourceSuite) TestErrorStatusHTTPStatusCode() {
+	for code, status := range map[int]backend.Status{
+		http.StatusUnauthorized:        backend.StatusUnauthorized,
+		http.StatusForbidden:           backend.StatusForbidden,
+		http.StatusNotFound:            backend.StatusNotFound,
+		http.StatusUnprocessableEntity: backend.StatusValidationFailed,
+		http.StatusTooManyRequests:     backend.StatusTooManyRequests,
+		http.StatusConflict:            backend.StatusBadRequest,
+		http.StatusServiceUnavailable:  backend.StatusBadGateway,
+		http.StatusGatewayTimeout:      backend.StatusTimeout,
+	} {
+		resp := s.handleTelemetryQueryFailedWith(core.EnapterAPIError{
+			Code:       "oops",
+			StatusCode: code,
+		})
+		s.Require().Equal(status, resp.Status, code)
+	}
+}
+
+func (s *DataSourceSuite) TestErrorStatusTimeout() {
+	resp := s.handleTelemetryQueryFailedWith(
+		fmt.Errorf("query timeseries: %w", context.DeadlineExceeded))
+	s.Require().Equal(backend.StatusTimeout, resp.Status)
+	s.Require().Equal(core.ErrSomethingWentWrong, resp.Error)
+}
+
+func (s *DataSourceSuite) TestErrorStatusCancellation() {
+	resp := s.handleTelemetryQueryFailedWith(
+		fmt.Errorf("query timeseries: %w", context.Canceled))
+	s.Require().Equal(backend.Status(499), resp.Status)
+}
+
+func (s *DataSourceSuite) TestErrorStatusPluginError() {
+	//nolint: goerr113 // test
+	resp := s.handleTelemetryQueryFailedWith(errors.New("oops"))
+	s.Require().Equal(backend.StatusInternal, resp.Status)
+	s.Require().Nil(resp.Frames)
+}
+
+func (s *DataSourceSuite) handleTelemetryQueryFailedWith(err error) backend.DataResponse {
+	req := s.randomDataRequestWithSingleTelemetryQuery()
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	s.expectQueryTimeseriesAndReturn(req, nil, err)
+	return s.handleDataRequest(req)[req.queries[0].refID]
+}
+
+func (s *DataSourceSuite) TestErrorStatusRateLimitExceeded() {
+	resp := s.handleTelemetryQueryFailedWith(
+		fmt.Errorf("rate limit: %w", core.ErrRateLimitExceeded))
+	s.Require().Equal(backend.StatusTooManyRequests, resp.Status)
+	s.Require().Equal(core.ErrRateLimitExceeded, resp.Error)
+}
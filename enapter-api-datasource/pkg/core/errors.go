@@ -12,9 +12,39 @@ type EnapterAPIError struct {
 	Code    string
 	Message string
 	Details map[string]any
+	// More holds the errors following the first one when the API has
+	// reported several of them.
+	More []EnapterAPIError
+	// StatusCode is the HTTP status code of the response carrying the
+	// errors, zero if unknown.
+	StatusCode int
+}
+
+func (e EnapterAPIError) HTTPStatusCode() int {
+	return e.StatusCode
+}
+
+// All returns every error reported by the API, starting with the first one.
+func (e EnapterAPIError) All() []EnapterAPIError {
+	all := make([]EnapterAPIError, 0, 1+len(e.More))
+	all = append(all, EnapterAPIError{
+		Code:    e.Code,
+		Message: e.Message,
+		Details: e.Details,
+	})
+	return append(all, e.More...)
 }
 
 func (e EnapterAPIError) Error() string {
+	if len(e.More) > 0 {
+		all := e.All()
+		msgs := make([]string, len(all))
+		for i, err := range all {
+			msgs[i] = err.Error()
+		}
+		return fmt.Sprintf("%d errors: [%s]", len(all), strings.Join(msgs, "; "))
+	}
+
 	var b strings.Builder
 	b.WriteString(fmt.Sprintf("code=%s", e.Code))
 	if len(e.Message) > 0 {
@@ -29,6 +59,7 @@ func (e EnapterAPIError) Error() string {
 var (
 	errUnsupportedTimeseriesDataType = errors.New("unsupported timeseries data type")
 	errUnexpectedQueryType           = errors.New("unexpected query type")
+	errInvalidUserID                 = errors.New("invalid user ID")
 )
 
 //nolint:stylecheck,revive // user-facing
@@ -41,4 +72,46 @@ var (
 		"The query is not a valid YAML.")
 	ErrInvalidOffset = errors.New(
 		"The offset specified in the query is invalid.")
+	ErrInvalidTimeout = errors.New(
+		"The timeout specified in the query is invalid.")
+	ErrInvalidEndpoint = errors.New(
+		"The endpoint specified in the query is not configured for this data source.")
+	ErrCommandsDisabled = errors.New(
+		"Commands are disabled for this data source.")
+	ErrCommandForbidden = errors.New(
+		"You are not allowed to execute this command.")
+	ErrCommandDebounced = errors.New(
+		"The command has been sent to the device recently. Wait a moment and try again.")
+	ErrIdempotencyKeyReused = errors.New(
+		"The idempotency key has already been used for a different command.")
+	ErrDeviceSelectorNotSupported = errors.New(
+		"Device selectors are not supported by the configured Enapter API version.")
+	ErrAsyncCommandsNotSupported = errors.New(
+		"Asynchronous commands are not supported by the configured Enapter API version.")
+	ErrCommandExecutionNotFound = errors.New(
+		"The command execution is invalid or does not exist.")
+	ErrHardwareIDNotFound = errors.New(
+		"No device with the given hardware ID was found.")
+	ErrHardwareIDNotSupported = errors.New(
+		"Hardware IDs are not supported in this query by the configured Enapter API version.")
+	ErrDeviceIDMismatch = errors.New(
+		"The device ID and the hardware ID in the query refer to different devices.")
+	ErrBulkCommandHardwareID = errors.New(
+		"Bulk commands select devices by device ID. Hardware IDs are not supported.")
+	ErrNoDevicesSelected = errors.New(
+		"No devices match the command query.")
+	ErrRateLimitExceeded = errors.New(
+		"Too many requests to Enapter API are waiting. Try again later.")
+	ErrEnapterAPIUnavailable = errors.New(
+		"Enapter API is unavailable. Requests are paused for a while after repeated failures.")
+	ErrOAuthTokenMissing = errors.New(
+		"The data source requires an OAuth access token, but Grafana has not forwarded any. Sign in with OAuth.")
+	ErrUserNotFound = errors.New(
+		"Your Grafana user is not known to Enapter. Contact your administrator.")
+	ErrAuditLogDisabled = errors.New(
+		"The audit log is not enabled for this data source.")
+	ErrAuditLogNotReadable = errors.New(
+		"The configured audit log does not support reading records.")
+	ErrAuditLogForbidden = errors.New(
+		"You are not allowed to read the audit log.")
 )
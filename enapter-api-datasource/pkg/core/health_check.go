@@ -0,0 +1,231 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+)
+
+// HealthCheck checks a single part of the data source, so that the config
+// page can show which part is failing.
+type HealthCheck struct {
+	Name  string
+	Check func(context.Context) error
+	// Info is optional. It describes the outcome of a successful check.
+	Info func() string
+}
+
+const (
+	healthCheckStatusOK      = "ok"
+	healthCheckStatusError   = "error"
+	healthCheckStatusSkipped = "skipped"
+)
+
+//nolint:tagliatelle // js
+type healthCheckResult struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Message   string `json:"message,omitempty"`
+	LatencyMs int64  `json:"latencyMs"`
+
+	// err is kept to classify the failure.
+	err error
+}
+
+type healthCheckDetails struct {
+	Checks []healthCheckResult `json:"checks"`
+}
+
+func (d *DataSource) runHealthChecks(
+	ctx context.Context, user *backend.User,
+) []healthCheckResult {
+	endpoints := d.allEndpoints()
+	endpointResults := make([][]healthCheckResult, len(endpoints))
+
+	// The checks are independent, so they do not wait for each other.
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			endpointResults[i] = runEndpointHealthChecks(ctx, e)
+		}()
+	}
+	wg.Wait()
+
+	var results []healthCheckResult
+	for _, r := range endpointResults {
+		results = append(results, r...)
+	}
+	results = append(results,
+		runHealthCheck(ctx, HealthCheck{
+			Name: "user_resolver",
+			Check: func(ctx context.Context) error {
+				return d.checkUserResolver(ctx, user)
+			},
+		}))
+
+	for _, r := range results {
+		if r.Status == healthCheckStatusError {
+			d.logger.Error("health check failed",
+				"check", r.Name,
+				"latency_ms", r.LatencyMs,
+				"error", r.Message)
+		}
+	}
+
+	return results
+}
+
+// runEndpointHealthChecks prefixes the names of the checks with the name of
+// the endpoint, if any, so that federated endpoints are reported apart.
+func runEndpointHealthChecks(ctx context.Context, e endpoint) []healthCheckResult {
+	checks := e.enapterAPI.HealthChecks()
+	results := make([]healthCheckResult, len(checks))
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runHealthCheck(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	results = append(results, tokenHealthCheck(results))
+
+	if e.name != "" {
+		for i := range results {
+			results[i].Name = e.name + "/" + results[i].Name
+		}
+	}
+	return results
+}
+
+func runHealthCheck(ctx context.Context, c HealthCheck) healthCheckResult {
+	start := time.Now()
+	err := c.Check(ctx)
+	r := healthCheckResult{
+		Name:      c.Name,
+		Status:    healthCheckStatusOK,
+		LatencyMs: time.Since(start).Milliseconds(),
+		err:       err,
+	}
+	var skipped healthCheckSkippedError
+	switch {
+	case errors.As(err, &skipped):
+		r.Status = healthCheckStatusSkipped
+		r.Message = skipped.reason
+		r.err = nil
+	case err != nil:
+		r.Status = healthCheckStatusError
+		r.Message = err.Error()
+	case c.Info != nil:
+		r.Message = c.Info()
+	}
+	return r
+}
+
+type healthCheckSkippedError struct {
+	reason string
+}
+
+func (e healthCheckSkippedError) Error() string {
+	return "skipped: " + e.reason
+}
+
+// tokenHealthCheck tells the token apart from the rest of the API using the
+// statuses of the other checks, because an invalid token and a token
+// without the required scopes are rejected by every API.
+func tokenHealthCheck(results []healthCheckResult) healthCheckResult {
+	r := healthCheckResult{
+		Name:   "token",
+		Status: healthCheckStatusSkipped,
+	}
+
+	var forbidden []string
+	for _, c := range results {
+		switch c.Status {
+		case healthCheckStatusOK:
+			if r.Status == healthCheckStatusSkipped {
+				r.Status = healthCheckStatusOK
+			}
+		case healthCheckStatusError:
+			switch status, _ := classifyError(c.err); status {
+			case backend.StatusUnauthorized:
+				r.Status = healthCheckStatusError
+				r.Message = "token is invalid or expired"
+				return r
+			case backend.StatusForbidden:
+				forbidden = append(forbidden, c.Name)
+			}
+		}
+	}
+
+	if len(forbidden) != 0 {
+		r.Status = healthCheckStatusError
+		r.Message = "token lacks the scopes required by " + strings.Join(forbidden, ", ")
+		return r
+	}
+	if r.Status == healthCheckStatusSkipped {
+		r.Message = "no Enapter API check has succeeded"
+	}
+	return r
+}
+
+func (d *DataSource) checkUserResolver(ctx context.Context, user *backend.User) error {
+	switch {
+	case d.forwardOAuthToken:
+		return healthCheckSkippedError{reason: "OAuth tokens are forwarded"}
+	case isNoopUserResolver(d.uncachedUserResolver):
+		return healthCheckSkippedError{reason: "not configured"}
+	case user == nil:
+		return healthCheckSkippedError{reason: "no Grafana user to resolve"}
+	}
+
+	// The cache would hide an unreachable resolver.
+	_, err := d.uncachedUserResolver.ResolveUser(ctx, &ResolveUserRequest{
+		Email: user.Email,
+		Login: user.Login,
+		Name:  user.Name,
+	})
+	// The resolver has been reached, it just does not know the user.
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	return err
+}
+
+func isNoopUserResolver(r UserResolverPort) bool {
+	_, ok := r.(NoopUserResolver)
+	return ok
+}
+
+func healthCheckMessage(results []healthCheckResult) string {
+	var failed []string
+	for _, r := range results {
+		if r.Status == healthCheckStatusError {
+			failed = append(failed, fmt.Sprintf("%s: %s", r.Name, r.Message))
+		}
+	}
+	if len(failed) == 0 {
+		return "ok"
+	}
+	return strings.Join(failed, "; ")
+}
+
+func healthCheckJSONDetails(results []healthCheckResult) []byte {
+	details, err := json.Marshal(healthCheckDetails{Checks: results})
+	if err != nil {
+		// Cannot happen, the results consist of strings and integers.
+		return nil
+	}
+	return details
+}
@@ -0,0 +1,102 @@
+package core_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+type healthCheckDetails struct {
+	Checks []struct {
+		Name    string `json:"name"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	} `json:"checks"`
+}
+
+func (s *DataSourceSuite) TestCheckHealthReportsEveryCheck() {
+	defer s.useHealthChecks(
+		core.HealthCheck{Name: "connection", Check: func(context.Context) error {
+			return nil
+		}},
+		core.HealthCheck{Name: "telemetry_api", Check: func(context.Context) error {
+			return core.EnapterAPIError{
+				Code:       "forbidden",
+				Message:    "token has no access to telemetry",
+				StatusCode: http.StatusForbidden,
+			}
+		}},
+	)()
+
+	result, err := s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Equal(backend.HealthStatusError, result.Status)
+	s.Require().Contains(result.Message, "telemetry_api: ")
+	s.Require().Contains(result.Message, "token: token lacks the scopes required by telemetry_api")
+
+	var details healthCheckDetails
+	s.Require().NoError(json.Unmarshal(result.JSONDetails, &details))
+	statuses := make(map[string]string)
+	for _, c := range details.Checks {
+		statuses[c.Name] = c.Status
+	}
+	s.Require().Equal(map[string]string{
+		"connection":    "ok",
+		"telemetry_api": "error",
+		"token":         "error",
+		"user_resolver": "skipped",
+	}, statuses)
+}
+
+func (s *DataSourceSuite) TestCheckHealthReportsInvalidToken() {
+	defer s.useHealthChecks(
+		core.HealthCheck{Name: "devices_api", Check: func(context.Context) error {
+			return core.EnapterAPIError{
+				Code:       "unauthorized",
+				StatusCode: http.StatusUnauthorized,
+			}
+		}},
+	)()
+
+	result, err := s.dataSource.CheckHealth(s.ctx, &backend.CheckHealthRequest{})
+	s.Require().NoError(err)
+	s.Require().Equal(backend.HealthStatusError, result.Status)
+	s.Require().Contains(result.Message, "token: token is invalid or expired")
+}
+
+func (s *DataSourceSuite) TestCheckHealthChecksUserResolver() {
+	resolver := &countingUserResolver{err: errFake}
+	orig := s.dataSource
+	defer func() { s.dataSource = orig }()
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:       s.logger,
+		EnapterAPI:   s.mockEnapterAPIAdapter,
+		UserResolver: resolver,
+		AuditLog:     s.mockAuditLog,
+	})
+
+	req := &backend.CheckHealthRequest{
+		PluginContext: backend.PluginContext{
+			User: &backend.User{Email: "jack@example.com"},
+		},
+	}
+	result, err := s.dataSource.CheckHealth(s.ctx, req)
+	s.Require().NoError(err)
+	s.Require().Equal(backend.HealthStatusError, result.Status)
+	s.Require().Contains(result.Message, "user_resolver: "+errFake.Error())
+
+	resolver.SetError(core.ErrUserNotFound)
+	result, err = s.dataSource.CheckHealth(s.ctx, req)
+	s.Require().NoError(err)
+	s.Require().Equal(backend.HealthStatusOk, result.Status)
+}
+
+func (s *DataSourceSuite) useHealthChecks(checks ...core.HealthCheck) (restore func()) {
+	orig := s.mockEnapterAPIAdapter.healthChecks
+	s.mockEnapterAPIAdapter.healthChecks = checks
+	return func() { s.mockEnapterAPIAdapter.healthChecks = orig }
+}
@@ -0,0 +1,92 @@
+package core_test
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+type MockAuditLog struct {
+	suite                   *suite.Suite
+	writeAuditRecordHandler func(context.Context, *core.AuditRecord) error
+	readAuditRecordsHandler func(
+		context.Context, *core.ReadAuditRecordsRequest,
+	) (*core.ReadAuditRecordsResponse, error)
+}
+
+func NewMockAuditLog(s *suite.Suite) *MockAuditLog {
+	m := new(MockAuditLog)
+	m.suite = s
+	m.writeAuditRecordHandler = m.unexpectedWriteAuditRecordCall
+	m.readAuditRecordsHandler = m.unexpectedReadAuditRecordsCall
+	return m
+}
+
+func (m *MockAuditLog) ExpectWriteAuditRecordCheckItAndReturn(
+	checkFn func(*core.AuditRecord), err error,
+) {
+	m.writeAuditRecordHandler = func(
+		_ context.Context, r *core.AuditRecord,
+	) error {
+		defer func() {
+			m.writeAuditRecordHandler = m.unexpectedWriteAuditRecordCall
+		}()
+		checkFn(r)
+		return err
+	}
+}
+
+// ExpectWriteAuditRecords accepts every subsequent audit record until the
+// returned function is called.
+func (m *MockAuditLog) ExpectWriteAuditRecords() (reset func()) {
+	m.writeAuditRecordHandler = func(context.Context, *core.AuditRecord) error {
+		return nil
+	}
+	return func() {
+		m.writeAuditRecordHandler = m.unexpectedWriteAuditRecordCall
+	}
+}
+
+func (m *MockAuditLog) WriteAuditRecord(
+	ctx context.Context, r *core.AuditRecord,
+) error {
+	return m.writeAuditRecordHandler(ctx, r)
+}
+
+func (m *MockAuditLog) unexpectedWriteAuditRecordCall(
+	context.Context, *core.AuditRecord,
+) error {
+	m.suite.Require().FailNow("unexpected call")
+	return nil
+}
+
+func (m *MockAuditLog) ExpectReadAuditRecordsAndReturn(
+	wantReq *core.ReadAuditRecordsRequest,
+	resp *core.ReadAuditRecordsResponse, err error,
+) {
+	m.readAuditRecordsHandler = func(
+		_ context.Context, haveReq *core.ReadAuditRecordsRequest,
+	) (*core.ReadAuditRecordsResponse, error) {
+		defer func() {
+			m.readAuditRecordsHandler = m.unexpectedReadAuditRecordsCall
+		}()
+		m.suite.Require().Equal(wantReq, haveReq)
+		return resp, err
+	}
+}
+
+func (m *MockAuditLog) ReadAuditRecords(
+	ctx context.Context, req *core.ReadAuditRecordsRequest,
+) (*core.ReadAuditRecordsResponse, error) {
+	return m.readAuditRecordsHandler(ctx, req)
+}
+
+func (m *MockAuditLog) unexpectedReadAuditRecordsCall(
+	context.Context, *core.ReadAuditRecordsRequest,
+) (*core.ReadAuditRecordsResponse, error) {
+	m.suite.Require().FailNow("unexpected call")
+	//nolint: nilnil // unreachable
+	return nil, nil
+}
@@ -10,6 +10,7 @@ import (
 
 type MockEnapterAPIAdapter struct {
 	suite                  *suite.Suite
+	healthChecks           []core.HealthCheck
 	queryTimeseriesHandler func(
 		context.Context, *core.QueryTimeseriesRequest,
 	) (*core.QueryTimeseriesResponse, error)
@@ -19,6 +20,18 @@ type MockEnapterAPIAdapter struct {
 	getDeviceManifestHandler func(
 		context.Context, *core.GetDeviceManifestRequest,
 	) (*core.GetDeviceManifestResponse, error)
+	listDevicesHandler func(
+		context.Context, *core.ListDevicesRequest,
+	) (*core.ListDevicesResponse, error)
+	startCommandExecutionHandler func(
+		context.Context, *core.ExecuteCommandRequest,
+	) (*core.StartCommandExecutionResponse, error)
+	getCommandExecutionHandler func(
+		context.Context, *core.GetCommandExecutionRequest,
+	) (*core.GetCommandExecutionResponse, error)
+	resolveDeviceIDHandler func(
+		context.Context, *core.ResolveDeviceIDRequest,
+	) (*core.ResolveDeviceIDResponse, error)
 }
 
 func NewMockEnapterAPIAdapter(s *suite.Suite) *MockEnapterAPIAdapter {
@@ -27,6 +40,10 @@ func NewMockEnapterAPIAdapter(s *suite.Suite) *MockEnapterAPIAdapter {
 	c.queryTimeseriesHandler = c.unexpectedQueryTimeseriesCall
 	c.executeCommandHandler = c.unexpectedExecuteCommandCall
 	c.getDeviceManifestHandler = c.unexpectedGetDeviceManifestCall
+	c.listDevicesHandler = c.unexpectedListDevicesCall
+	c.startCommandExecutionHandler = c.unexpectedStartCommandExecutionCall
+	c.getCommandExecutionHandler = c.unexpectedGetCommandExecutionCall
+	c.resolveDeviceIDHandler = c.unexpectedResolveDeviceIDCall
 	return c
 }
 
@@ -45,6 +62,22 @@ func (c *MockEnapterAPIAdapter) ExpectQueryTimeseriesAndReturn(
 	}
 }
 
+func (c *MockEnapterAPIAdapter) ExpectQueryTimeseriesCheckContextAndReturn(
+	wantReq *core.QueryTimeseriesRequest, check func(context.Context),
+	resp *core.QueryTimeseriesResponse, err error,
+) {
+	c.queryTimeseriesHandler = func(
+		ctx context.Context, haveReq *core.QueryTimeseriesRequest,
+	) (*core.QueryTimeseriesResponse, error) {
+		defer func() {
+			c.queryTimeseriesHandler = c.unexpectedQueryTimeseriesCall
+		}()
+		c.suite.Require().Equal(wantReq, haveReq)
+		check(ctx)
+		return resp, err
+	}
+}
+
 func (c *MockEnapterAPIAdapter) QueryTimeseries(
 	ctx context.Context, req *core.QueryTimeseriesRequest,
 ) (*core.QueryTimeseriesResponse, error) {
@@ -74,6 +107,21 @@ func (c *MockEnapterAPIAdapter) ExpectExecuteCommandAndReturn(
 	}
 }
 
+// ExpectExecuteCommandsAndHandle makes every subsequent call to
+// ExecuteCommand use the given handler until the returned function is called.
+func (c *MockEnapterAPIAdapter) ExpectExecuteCommandsAndHandle(
+	handler func(*core.ExecuteCommandRequest) (*core.ExecuteCommandResponse, error),
+) (reset func()) {
+	c.executeCommandHandler = func(
+		_ context.Context, req *core.ExecuteCommandRequest,
+	) (*core.ExecuteCommandResponse, error) {
+		return handler(req)
+	}
+	return func() {
+		c.executeCommandHandler = c.unexpectedExecuteCommandCall
+	}
+}
+
 func (c *MockEnapterAPIAdapter) ExecuteCommand(
 	ctx context.Context, req *core.ExecuteCommandRequest,
 ) (*core.ExecuteCommandResponse, error) {
@@ -88,6 +136,21 @@ func (c *MockEnapterAPIAdapter) unexpectedExecuteCommandCall(
 	return nil, nil
 }
 
+func (c *MockEnapterAPIAdapter) ExpectGetDeviceManifestAndReturn(
+	wantReq *core.GetDeviceManifestRequest,
+	resp *core.GetDeviceManifestResponse, err error,
+) {
+	c.getDeviceManifestHandler = func(
+		_ context.Context, haveReq *core.GetDeviceManifestRequest,
+	) (*core.GetDeviceManifestResponse, error) {
+		defer func() {
+			c.getDeviceManifestHandler = c.unexpectedGetDeviceManifestCall
+		}()
+		c.suite.Require().Equal(wantReq, haveReq)
+		return resp, err
+	}
+}
+
 func (c *MockEnapterAPIAdapter) GetDeviceManifest(
 	ctx context.Context, req *core.GetDeviceManifestRequest,
 ) (*core.GetDeviceManifestResponse, error) {
@@ -102,4 +165,148 @@ func (c *MockEnapterAPIAdapter) unexpectedGetDeviceManifestCall(
 	return nil, nil
 }
 
-func (c *MockEnapterAPIAdapter) Ready(context.Context) error { return nil }
+func (c *MockEnapterAPIAdapter) ExpectListDevicesAndReturn(
+	wantReq *core.ListDevicesRequest,
+	resp *core.ListDevicesResponse, err error,
+) {
+	c.listDevicesHandler = func(
+		_ context.Context, haveReq *core.ListDevicesRequest,
+	) (*core.ListDevicesResponse, error) {
+		defer func() {
+			c.listDevicesHandler = c.unexpectedListDevicesCall
+		}()
+		c.suite.Require().Equal(wantReq, haveReq)
+		return resp, err
+	}
+}
+
+func (c *MockEnapterAPIAdapter) ListDevices(
+	ctx context.Context, req *core.ListDevicesRequest,
+) (*core.ListDevicesResponse, error) {
+	return c.listDevicesHandler(ctx, req)
+}
+
+func (c *MockEnapterAPIAdapter) unexpectedListDevicesCall(
+	context.Context, *core.ListDevicesRequest,
+) (*core.ListDevicesResponse, error) {
+	c.suite.Require().FailNow("unexpected call")
+	//nolint: nilnil // unreachable
+	return nil, nil
+}
+
+func (c *MockEnapterAPIAdapter) ExpectStartCommandExecutionAndReturn(
+	wantReq *core.ExecuteCommandRequest,
+	resp *core.StartCommandExecutionResponse, err error,
+) {
+	c.startCommandExecutionHandler = func(
+		_ context.Context, haveReq *core.ExecuteCommandRequest,
+	) (*core.StartCommandExecutionResponse, error) {
+		defer func() {
+			c.startCommandExecutionHandler = c.unexpectedStartCommandExecutionCall
+		}()
+		c.suite.Require().Equal(wantReq, haveReq)
+		return resp, err
+	}
+}
+
+func (c *MockEnapterAPIAdapter) ExpectStartCommandExecutionsAndHandle(
+	handler func(*core.ExecuteCommandRequest) (*core.StartCommandExecutionResponse, error),
+) (reset func()) {
+	c.startCommandExecutionHandler = func(
+		_ context.Context, req *core.ExecuteCommandRequest,
+	) (*core.StartCommandExecutionResponse, error) {
+		return handler(req)
+	}
+	return func() {
+		c.startCommandExecutionHandler = c.unexpectedStartCommandExecutionCall
+	}
+}
+
+func (c *MockEnapterAPIAdapter) StartCommandExecution(
+	ctx context.Context, req *core.ExecuteCommandRequest,
+) (*core.StartCommandExecutionResponse, error) {
+	return c.startCommandExecutionHandler(ctx, req)
+}
+
+func (c *MockEnapterAPIAdapter) unexpectedStartCommandExecutionCall(
+	context.Context, *core.ExecuteCommandRequest,
+) (*core.StartCommandExecutionResponse, error) {
+	c.suite.Require().FailNow("unexpected call")
+	//nolint: nilnil // unreachable
+	return nil, nil
+}
+
+func (c *MockEnapterAPIAdapter) ExpectGetCommandExecutionAndReturn(
+	wantReq *core.GetCommandExecutionRequest,
+	resp *core.GetCommandExecutionResponse, err error,
+) {
+	c.getCommandExecutionHandler = func(
+		_ context.Context, haveReq *core.GetCommandExecutionRequest,
+	) (*core.GetCommandExecutionResponse, error) {
+		defer func() {
+			c.getCommandExecutionHandler = c.unexpectedGetCommandExecutionCall
+		}()
+		c.suite.Require().Equal(wantReq, haveReq)
+		return resp, err
+	}
+}
+
+func (c *MockEnapterAPIAdapter) ExpectGetCommandExecutionsAndHandle(
+	handler func(*core.GetCommandExecutionRequest) (*core.GetCommandExecutionResponse, error),
+) (reset func()) {
+	c.getCommandExecutionHandler = func(
+		_ context.Context, req *core.GetCommandExecutionRequest,
+	) (*core.GetCommandExecutionResponse, error) {
+		return handler(req)
+	}
+	return func() {
+		c.getCommandExecutionHandler = c.unexpectedGetCommandExecutionCall
+	}
+}
+
+func (c *MockEnapterAPIAdapter) GetCommandExecution(
+	ctx context.Context, req *core.GetCommandExecutionRequest,
+) (*core.GetCommandExecutionResponse, error) {
+	return c.getCommandExecutionHandler(ctx, req)
+}
+
+func (c *MockEnapterAPIAdapter) unexpectedGetCommandExecutionCall(
+	context.Context, *core.GetCommandExecutionRequest,
+) (*core.GetCommandExecutionResponse, error) {
+	c.suite.Require().FailNow("unexpected call")
+	//nolint: nilnil // unreachable
+	return nil, nil
+}
+
+func (c *MockEnapterAPIAdapter) ExpectResolveDeviceIDAndReturn(
+	wantReq *core.ResolveDeviceIDRequest,
+	resp *core.ResolveDeviceIDResponse, err error,
+) {
+	c.resolveDeviceIDHandler = func(
+		_ context.Context, haveReq *core.ResolveDeviceIDRequest,
+	) (*core.ResolveDeviceIDResponse, error) {
+		defer func() {
+			c.resolveDeviceIDHandler = c.unexpectedResolveDeviceIDCall
+		}()
+		c.suite.Require().Equal(wantReq, haveReq)
+		return resp, err
+	}
+}
+
+func (c *MockEnapterAPIAdapter) ResolveDeviceID(
+	ctx context.Context, req *core.ResolveDeviceIDRequest,
+) (*core.ResolveDeviceIDResponse, error) {
+	return c.resolveDeviceIDHandler(ctx, req)
+}
+
+func (c *MockEnapterAPIAdapter) unexpectedResolveDeviceIDCall(
+	context.Context, *core.ResolveDeviceIDRequest,
+) (*core.ResolveDeviceIDResponse, error) {
+	c.suite.Require().FailNow("unexpected call")
+	//nolint: nilnil // unreachable
+	return nil, nil
+}
+
+func (c *MockEnapterAPIAdapter) HealthChecks() []core.HealthCheck {
+	return c.healthChecks
+}
@@ -0,0 +1,17 @@
+package core
+
+import (
+	"context"
+)
+
+type NoopAuditLog struct{}
+
+func (NoopAuditLog) WriteAuditRecord(context.Context, *AuditRecord) error {
+	return nil
+}
+
+func (NoopAuditLog) ReadAuditRecords(
+	context.Context, *ReadAuditRecordsRequest,
+) (*ReadAuditRecordsResponse, error) {
+	return nil, ErrAuditLogDisabled
+}
@@ -0,0 +1,48 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+// Timeouts bound the time spent on handling a query. The zero value
+// disables them.
+type Timeouts struct {
+	// Default applies to every query, health check and resource call.
+	Default time.Duration
+	// Max caps the timeout a query may request with the @timeout
+	// directive. Zero means that the directive cannot exceed Default, or
+	// that it is not capped at all if Default is zero too.
+	Max time.Duration
+}
+
+// withTimeout bounds the context by the timeout requested by the query, or
+// by the default one if the query has not requested any.
+func (d *DataSource) withTimeout(
+	ctx context.Context, requested time.Duration,
+) (context.Context, context.CancelFunc) {
+	timeout := d.timeouts.Default
+	if requested > 0 {
+		timeout = requested
+		if limit := max(d.timeouts.Max, d.timeouts.Default); limit > 0 {
+			timeout = min(requested, limit)
+		}
+		if d.timeouts.Default == 0 || timeout < d.timeouts.Default {
+			ctx = context.WithValue(ctx, queryTimeoutKey{}, true)
+		}
+	}
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryTimeoutKey marks contexts bounded by a timeout the query has
+// requested below the default one. Their expiry is the choice of the query
+// rather than a sign of the API being slow.
+type queryTimeoutKey struct{}
+
+func hasQueryTimeout(ctx context.Context) bool {
+	v, _ := ctx.Value(queryTimeoutKey{}).(bool)
+	return v
+}
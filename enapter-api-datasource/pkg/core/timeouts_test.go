@@ -0,0 +1,106 @@
+package core_test
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/bxcodec/faker/v3"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestQueryTimeout() {
+	defer s.useDataSourceWithTimeouts(core.Timeouts{
+		Default: time.Minute,
+		Max:     5 * time.Minute,
+	})()
+
+	for requested, want := range map[string]time.Duration{
+		"":    time.Minute,
+		"2m":  2 * time.Minute,
+		"10m": 5 * time.Minute,
+	} {
+		req := s.dataRequestWithTimeout(requested)
+		s.expectResolveUserAndReturn(req.user, req.user, nil)
+		s.mockEnapterAPIAdapter.ExpectQueryTimeseriesCheckContextAndReturn(
+			&core.QueryTimeseriesRequest{
+				User:  req.user,
+				Query: s.queryTextWithTimeRange(req.queries[0]),
+			}, func(ctx context.Context) {
+				deadline, ok := ctx.Deadline()
+				s.Require().True(ok)
+				s.Require().WithinDuration(time.Now().Add(want), deadline, time.Second)
+			}, nil, core.ErrTimeseriesEmpty)
+
+		_, err := s.handleDataRequestWithSingleQuery(req)
+		s.Require().NoError(err)
+	}
+}
+
+func (s *DataSourceSuite) TestQueryTimeoutWithoutTimeouts() {
+	defer s.useDataSourceWithTimeouts(core.Timeouts{})()
+
+	for requested, want := range map[string]time.Duration{
+		"":   0,
+		"2m": 2 * time.Minute,
+	} {
+		req := s.dataRequestWithTimeout(requested)
+		s.expectResolveUserAndReturn(req.user, req.user, nil)
+		s.mockEnapterAPIAdapter.ExpectQueryTimeseriesCheckContextAndReturn(
+			&core.QueryTimeseriesRequest{
+				User:  req.user,
+				Query: s.queryTextWithTimeRange(req.queries[0]),
+			}, func(ctx context.Context) {
+				deadline, ok := ctx.Deadline()
+				s.Require().Equal(want != 0, ok)
+				if ok {
+					s.Require().WithinDuration(time.Now().Add(want), deadline, time.Second)
+				}
+			}, nil, core.ErrTimeseriesEmpty)
+
+		_, err := s.handleDataRequestWithSingleQuery(req)
+		s.Require().NoError(err)
+	}
+}
+
+func (s *DataSourceSuite) TestInvalidQueryTimeout() {
+	req := s.dataRequestWithTimeout("soon")
+	s.expectResolveUserAndReturn(req.user, req.user, nil)
+	_, err := s.handleDataRequestWithSingleQuery(req)
+	s.Require().ErrorIs(err, core.ErrInvalidTimeout)
+}
+
+func (s *DataSourceSuite) dataRequestWithTimeout(timeout string) dataRequest {
+	obj := map[string]any{
+		"granularity": "42s",
+		"aggregation": "auto",
+	}
+	if timeout != "" {
+		obj["@timeout"] = timeout
+	}
+	return dataRequest{
+		user: faker.Email(),
+		queries: []query{{
+			refID:    s.randomRefID(),
+			from:     time.Now().Add(-time.Duration(rand.Int()+1) * time.Hour),
+			to:       time.Now().Add(-time.Duration(rand.Int()+1) * time.Minute),
+			interval: time.Duration(rand.Int()) * time.Second,
+			text:     string(s.shouldMarshalJSON(obj)),
+		}},
+	}
+}
+
+func (s *DataSourceSuite) useDataSourceWithTimeouts(
+	timeouts core.Timeouts,
+) (restore func()) {
+	orig := s.dataSource
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:       s.logger,
+		EnapterAPI:   s.mockEnapterAPIAdapter,
+		UserResolver: s.mockUserResolver,
+		AuditLog:     s.mockAuditLog,
+		Timeouts:     timeouts,
+	})
+	return func() { s.dataSource = orig }
+}
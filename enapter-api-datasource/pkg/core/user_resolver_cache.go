@@ -0,0 +1,207 @@
+package core
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
+)
+
+// UserResolverCache configures caching of resolved users. The zero value
+// disables it.
+type UserResolverCache struct {
+	// TTL is how long a resolved user is remembered.
+	TTL time.Duration
+	// NegativeTTL is how long a user not known to the resolver is
+	// remembered. Zero means that such users are not remembered.
+	NegativeTTL time.Duration
+	// StaleGrace is how long an expired entry may still be used while the
+	// resolver fails.
+	StaleGrace time.Duration
+	// MaxEntries bounds the number of remembered users. The least recently
+	// used ones are evicted first. Zero means no limit.
+	MaxEntries int
+}
+
+// cachingUserResolver decorates UserResolverPort. Concurrent lookups of the
+// same user are coalesced into a single call of the resolver.
+type cachingUserResolver struct {
+	next        UserResolverPort
+	ttl         time.Duration
+	negativeTTL time.Duration
+	staleGrace  time.Duration
+	maxEntries  int
+	metrics     *metrics.DataSource
+	now         func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inFlight map[string]*userResolution
+}
+
+type userResolverCacheEntry struct {
+	key       string
+	id        string
+	notFound  bool
+	expiresAt time.Time
+}
+
+type userResolution struct {
+	done chan struct{}
+	resp *ResolveUserResponse
+	err  error
+}
+
+func newCachingUserResolver(
+	next UserResolverPort, p UserResolverCache, m *metrics.DataSource,
+) *cachingUserResolver {
+	return &cachingUserResolver{
+		next:        next,
+		ttl:         p.TTL,
+		negativeTTL: p.NegativeTTL,
+		staleGrace:  p.StaleGrace,
+		maxEntries:  p.MaxEntries,
+		metrics:     m,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		inFlight:    make(map[string]*userResolution),
+	}
+}
+
+func (c *cachingUserResolver) ResolveUser(
+	ctx context.Context, req *ResolveUserRequest,
+) (*ResolveUserResponse, error) {
+	key := userResolverCacheKey(req)
+	for {
+		c.mu.Lock()
+		if entry, ok := c.lookupLocked(key); ok &&
+			!c.now().After(entry.expiresAt) {
+			c.mu.Unlock()
+			c.metrics.CountCacheLookup("user", "hit")
+			return entry.response()
+		}
+		if r, ok := c.inFlight[key]; ok {
+			c.mu.Unlock()
+			c.metrics.CountCacheLookup("user", "coalesced")
+			select {
+			case <-r.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			// The context of the caller which has actually resolved the
+			// user may have been cancelled, which tells nothing about the
+			// user.
+			if isContextError(r.err) && ctx.Err() == nil {
+				continue
+			}
+			return r.resp, r.err
+		}
+		r := &userResolution{done: make(chan struct{})}
+		c.inFlight[key] = r
+		c.mu.Unlock()
+		c.metrics.CountCacheLookup("user", "miss")
+
+		r.resp, r.err = c.resolve(ctx, key, req)
+
+		c.mu.Lock()
+		delete(c.inFlight, key)
+		close(r.done)
+		c.mu.Unlock()
+
+		return r.resp, r.err
+	}
+}
+
+// userResolverCacheKey takes every attribute into account, because
+// resolvers may rely on any of them.
+func userResolverCacheKey(req *ResolveUserRequest) string {
+	return req.Email + "\n" + req.Login + "\n" + req.Name
+}
+
+func (c *cachingUserResolver) resolve(
+	ctx context.Context, key string, req *ResolveUserRequest,
+) (*ResolveUserResponse, error) {
+	resp, err := c.next.ResolveUser(ctx, req)
+	switch {
+	case err == nil:
+		c.store(&userResolverCacheEntry{
+			key:       key,
+			id:        resp.ID,
+			expiresAt: c.now().Add(c.ttl),
+		})
+		return resp, nil
+	case errors.Is(err, ErrUserNotFound):
+		if c.negativeTTL > 0 {
+			c.store(&userResolverCacheEntry{
+				key:       key,
+				notFound:  true,
+				expiresAt: c.now().Add(c.negativeTTL),
+			})
+		}
+		return nil, err
+	case isContextError(err):
+		return nil, err
+	}
+
+	// The resolver is likely unavailable, so an expired entry is better
+	// than nothing.
+	c.mu.Lock()
+	entry, ok := c.lookupLocked(key)
+	c.mu.Unlock()
+	if ok && !c.now().After(entry.expiresAt.Add(c.staleGrace)) {
+		c.metrics.CountCacheLookup("user", "stale")
+		return entry.response()
+	}
+	return nil, err
+}
+
+func (c *cachingUserResolver) lookupLocked(key string) (*userResolverCacheEntry, bool) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*userResolverCacheEntry) //nolint:forcetypeassert // always
+	if c.now().After(entry.expiresAt.Add(c.staleGrace)) {
+		c.lru.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry, true
+}
+
+func (c *cachingUserResolver) store(entry *userResolverCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[entry.key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		//nolint:forcetypeassert // always
+		delete(c.entries, oldest.Value.(*userResolverCacheEntry).key)
+	}
+}
+
+func (e *userResolverCacheEntry) response() (*ResolveUserResponse, error) {
+	if e.notFound {
+		return nil, ErrUserNotFound
+	}
+	return &ResolveUserResponse{ID: e.id}, nil
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
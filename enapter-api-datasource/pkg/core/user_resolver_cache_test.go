@@ -0,0 +1,152 @@
+package core_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bxcodec/faker/v3"
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+func (s *DataSourceSuite) TestUserResolverCache() {
+	resolver := &countingUserResolver{}
+	defer s.useDataSourceWithUserResolverCache(resolver, core.UserResolverCache{
+		TTL:        time.Hour,
+		MaxEntries: 1,
+	})()
+
+	user1, user2 := faker.Email(), faker.Email()
+	s.Require().NoError(s.queryDataAs(user1))
+	s.Require().NoError(s.queryDataAs(user1))
+	s.Require().Equal(1, resolver.Calls())
+
+	// The second user evicts the first one.
+	s.Require().NoError(s.queryDataAs(user2))
+	s.Require().NoError(s.queryDataAs(user1))
+	s.Require().Equal(3, resolver.Calls())
+}
+
+func (s *DataSourceSuite) TestUserResolverCacheCoalescesRequests() {
+	resolver := &countingUserResolver{release: make(chan struct{})}
+	defer s.useDataSourceWithUserResolverCache(resolver, core.UserResolverCache{
+		TTL: time.Hour,
+	})()
+
+	user := faker.Email()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Assert().NoError(s.queryDataAs(user))
+		}()
+	}
+	s.Require().Eventually(func() bool { return resolver.Calls() == 1 },
+		time.Second, time.Millisecond)
+	close(resolver.release)
+	wg.Wait()
+
+	s.Require().Equal(1, resolver.Calls())
+}
+
+func (s *DataSourceSuite) TestUserResolverCacheRemembersUnknownUsers() {
+	resolver := &countingUserResolver{err: core.ErrUserNotFound}
+	defer s.useDataSourceWithUserResolverCache(resolver, core.UserResolverCache{
+		TTL:         time.Hour,
+		NegativeTTL: time.Hour,
+	})()
+
+	user := faker.Email()
+	s.Require().ErrorIs(s.queryDataAs(user), core.ErrUserNotFound)
+	s.Require().ErrorIs(s.queryDataAs(user), core.ErrUserNotFound)
+	s.Require().Equal(1, resolver.Calls())
+}
+
+func (s *DataSourceSuite) TestUserResolverCacheServesStaleEntries() {
+	const ttl = 10 * time.Millisecond
+	resolver := &countingUserResolver{}
+	defer s.useDataSourceWithUserResolverCache(resolver, core.UserResolverCache{
+		TTL:        ttl,
+		StaleGrace: time.Hour,
+	})()
+
+	user := faker.Email()
+	s.Require().NoError(s.queryDataAs(user))
+	time.Sleep(ttl)
+
+	resolver.SetError(errors.New("connection refused"))
+	s.Require().NoError(s.queryDataAs(user))
+	s.Require().Equal(2, resolver.Calls())
+}
+
+func (s *DataSourceSuite) useDataSourceWithUserResolverCache(
+	resolver core.UserResolverPort, cache core.UserResolverCache,
+) (restore func()) {
+	orig := s.dataSource
+	s.dataSource = core.NewDataSource(core.DataSourceParams{
+		Logger:            s.logger,
+		EnapterAPI:        s.mockEnapterAPIAdapter,
+		UserResolver:      resolver,
+		AuditLog:          s.mockAuditLog,
+		UserResolverCache: cache,
+	})
+	return func() { s.dataSource = orig }
+}
+
+// queryDataAs sends a request with a hidden query, so that only the user is
+// resolved. It returns the error of the query.
+func (s *DataSourceSuite) queryDataAs(user string) error {
+	resp, err := s.dataSource.QueryData(s.ctx, &backend.QueryDataRequest{
+		PluginContext: backend.PluginContext{
+			User: &backend.User{Email: user},
+		},
+		Queries: []backend.DataQuery{{
+			RefID: "A",
+			JSON:  s.shouldMarshalJSON(map[string]any{"hide": true}),
+		}},
+	})
+	s.Require().NoError(err)
+	return resp.Responses["A"].Error
+}
+
+// countingUserResolver is safe for concurrent use unlike MockUserResolver.
+type countingUserResolver struct {
+	release chan struct{}
+
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (r *countingUserResolver) ResolveUser(
+	_ context.Context, req *core.ResolveUserRequest,
+) (*core.ResolveUserResponse, error) {
+	r.mu.Lock()
+	r.calls++
+	err := r.err
+	r.mu.Unlock()
+
+	if r.release != nil {
+		<-r.release
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &core.ResolveUserResponse{ID: req.Email}, nil
+}
+
+func (r *countingUserResolver) Calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.calls
+}
+
+func (r *countingUserResolver) SetError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.err = err
+}
@@ -4,6 +4,8 @@ import "context"
 
 type ResolveUserRequest struct {
 	Email string
+	Login string
+	Name  string
 }
 
 type ResolveUserResponse struct {
@@ -1,15 +1,18 @@
 package grafana
 
 import (
-	"encoding/json"
 	"fmt"
+	nethttp "net/http"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
-	"github.com/hashicorp/go-hclog"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 
+	"github.com/Enapter/grafana-plugins/pkg/auditlog"
 	"github.com/Enapter/grafana-plugins/pkg/core"
 	"github.com/Enapter/grafana-plugins/pkg/http"
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
+	"github.com/Enapter/grafana-plugins/pkg/userresolver"
 )
 
 var _ instancemgmt.InstanceDisposer = (*dataSourceInstance)(nil)
@@ -20,16 +23,18 @@ type enapterAPIAdapter interface {
 }
 
 type dataSourceInstance struct {
-	logger            hclog.Logger
-	enapterAPIAdapter enapterAPIAdapter
+	logger             log.Logger
+	enapterAPIAdapters []enapterAPIAdapter
 	backend.QueryDataHandler
 	backend.CheckHealthHandler
+	backend.CallResourceHandler
+	backend.StreamHandler
 }
 
 func NewDataSourceInstance(
-	logger hclog.Logger, settings backend.DataSourceInstanceSettings,
+	logger log.Logger, settings backend.DataSourceInstanceSettings,
 ) (_ *dataSourceInstance, retErr error) {
-	logger = logger.Named(fmt.Sprintf("data_source[%q]", settings.Name))
+	logger = logger.With("data_source", settings.Name)
 
 	defer func() {
 		if retErr != nil {
@@ -38,75 +43,301 @@ func NewDataSourceInstance(
 		}
 	}()
 
-	var jsonData struct {
-		EnapterAPIURL     string `json:"enapterAPIURL"`
-		EnapterAPIVersion string `json:"enapterAPIVersion"`
-		UserResolverURL   string `json:"userResolverURL"`
+	s, err := parseSettings(settings)
+	if err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(settings.JSONData, &jsonData); err != nil {
-		return nil, fmt.Errorf("JSON data: %w", err)
+	logger = newLevelLogger(logger, s.logLevel)
+
+	apiURL := s.EnapterAPIURL
+	apiVersion := s.EnapterAPIVersion
+	apiToken := s.EnapterAPIToken
+
+	dataSourceMetrics := metrics.ForDataSource(settings.UID)
+
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("TLS config: %w", err)
+	}
+	enapterAPITransport, err := http.NewTransport(http.TransportParams{
+		TLSConfig:             tlsConfig,
+		ProxyURL:              s.proxyURL,
+		SecureSocksProxy:      s.secureSocksProxyOptions(),
+		ConnectTimeout:        s.connectTimeout,
+		ResponseHeaderTimeout: s.responseHeaderTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("new Enapter API transport: %w", err)
+	}
+	if s.WireDebug {
+		enapterAPITransport = http.NewDebugTransport(enapterAPITransport,
+			http.DebugTransportParams{
+				Logger:         logger.With("logger", "wire"),
+				RedactedFields: s.WireDebugRedactedFields,
+			})
+	}
+
+	// The rate limit applies to the API token, so the clients using the same
+	// token share a limiter.
+	rateLimiters := make(map[string]*http.RateLimiter)
+	rateLimiter := func(token string) *http.RateLimiter {
+		l, ok := rateLimiters[token]
+		if !ok {
+			l = s.rateLimiter(dataSourceMetrics)
+			rateLimiters[token] = l
+		}
+		return l
+	}
+
+	adapter, err := newEnapterAPIAdapter(
+		logger, s, endpointSettings{
+			EnapterAPIURL:     apiURL,
+			EnapterAPIVersion: apiVersion,
+			EnapterAPIToken:   apiToken,
+		}, enapterAPITransport, rateLimiter(apiToken), dataSourceMetrics)
+	if err != nil {
+		return nil, err
+	}
+	enapterAPIAdapters := []enapterAPIAdapter{adapter}
+	defer func() {
+		if retErr != nil {
+			for _, a := range enapterAPIAdapters {
+				a.Close()
+			}
+		}
+	}()
+
+	endpoints := make([]core.Endpoint, len(s.Endpoints))
+	for i, e := range s.Endpoints {
+		a, err := newEnapterAPIAdapter(logger.With("endpoint", e.Name),
+			s, e, enapterAPITransport, rateLimiter(e.EnapterAPIToken), dataSourceMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("endpoint %q: %w", e.Name, err)
+		}
+		enapterAPIAdapters = append(enapterAPIAdapters, a)
+		endpoints[i] = core.Endpoint{Name: e.Name, EnapterAPI: a}
 	}
 
-	apiURL := jsonData.EnapterAPIURL
-	apiVersion := jsonData.EnapterAPIVersion
-	apiToken := settings.DecryptedSecureJSONData["enapterAPIToken"]
+	var userResolver core.UserResolverPort = core.NoopUserResolver{}
+	if s.OAuthPassThru {
+		if s.userResolverType() != "" {
+			logger.Warn("user resolver is ignored, because OAuth tokens are forwarded")
+		}
+	} else {
+		userResolver, err = newUserResolver(s)
+		if err != nil {
+			return nil, fmt.Errorf("new user resolver: %w", err)
+		}
+	}
 
-	var enapterAPIAdapter enapterAPIAdapter
+	auditLog, err := newAuditLog(logger, s)
+	if err != nil {
+		return nil, fmt.Errorf("new audit log: %w", err)
+	}
 
-	switch apiVersion {
+	dataSource := core.NewDataSource(core.DataSourceParams{
+		Logger:               logger,
+		EnapterAPI:           adapter,
+		EnapterAPIName:       s.EnapterAPIName,
+		Endpoints:            endpoints,
+		UserResolver:         userResolver,
+		AuditLog:             auditLog,
+		AuditLogMinRole:      core.Role(s.AuditLogMinRole),
+		CommandPolicy:        s.commandPolicy(),
+		CommandDeduplication: s.commandDeduplication(),
+		CircuitBreaker:       s.circuitBreaker(),
+		Timeouts:             s.timeouts(),
+		ForwardOAuthToken:    s.OAuthPassThru,
+		UserResolverCache:    s.userResolverCache(),
+		Metrics:              dataSourceMetrics,
+	})
+
+	logger.Info("created new data source",
+		"api_url", apiURL,
+		"api_version", apiVersion,
+		"endpoints", len(s.Endpoints),
+		"oauth_pass_thru", s.OAuthPassThru,
+		"user_resolver_type", s.userResolverType(),
+		"audit_log_sink", s.AuditLogSink,
+		"audit_log_min_role", s.AuditLogMinRole,
+		"commands_read_only", s.CommandsReadOnly,
+		"commands_min_role", s.CommandsMinRole,
+		"commands_debounce_interval", s.commandsDebounceInterval,
+		"retry_max_attempts", s.RetryMaxAttempts,
+		"rate_limit_rps", s.RateLimitRequestsPerSecond,
+		"circuit_breaker_disabled", s.CircuitBreakerDisabled,
+		"user_resolver_cache_disabled", s.UserResolverCacheDisabled,
+		"tls_skip_verify", s.TLSSkipVerify,
+		"tls_client_auth", s.TLSAuth,
+		"http_proxy", s.proxyURL.Redacted(),
+		"secure_socks_proxy", s.EnableSecureSocksProxy,
+		"query_timeout", s.queryTimeout,
+		"query_max_timeout", s.queryMaxTimeout,
+		"log_level", s.LogLevel,
+		"wire_debug", s.WireDebug,
+	)
+
+	return &dataSourceInstance{
+		logger:              logger,
+		enapterAPIAdapters:  enapterAPIAdapters,
+		QueryDataHandler:    dataSource,
+		CheckHealthHandler:  dataSource,
+		CallResourceHandler: dataSource,
+		StreamHandler:       dataSource,
+	}, nil
+}
+
+func newEnapterAPIAdapter(
+	logger log.Logger, s *dataSourceSettings, e endpointSettings,
+	transport nethttp.RoundTripper, rateLimiter *http.RateLimiter,
+	m *metrics.DataSource,
+) (enapterAPIAdapter, error) {
+	switch e.EnapterAPIVersion {
 	case "v1":
 		a, err := http.NewEnapterAPIv1Adapter(http.EnapterAPIv1AdapterParams{
-			Logger:   logger,
-			APIURL:   apiURL,
-			APIToken: apiToken,
+			Logger:      logger,
+			APIURL:      e.EnapterAPIURL,
+			APIToken:    e.EnapterAPIToken,
+			Retry:       s.retryPolicy(),
+			RateLimiter: rateLimiter,
+			Transport:   transport,
+			Timeout:     s.clientTimeout(),
 		})
 		if err != nil {
 			return nil, fmt.Errorf("new Enapter API v1 adapter: %w", err)
 		}
-		enapterAPIAdapter = a
+		return a, nil
 	case "v3":
 		a, err := http.NewEnapterAPIv3Adapter(http.EnapterAPIv3AdapterParams{
-			Logger:   logger,
-			APIURL:   apiURL,
-			APIToken: apiToken,
+			Logger:      logger,
+			APIURL:      e.EnapterAPIURL,
+			APIToken:    e.EnapterAPIToken,
+			Retry:       s.retryPolicy(),
+			RateLimiter: rateLimiter,
+			Transport:   transport,
+			Timeout:     s.clientTimeout(),
+			Metrics:     m,
 		})
 		if err != nil {
 			return nil, fmt.Errorf("new Enapter API v3 adapter: %w", err)
 		}
-		enapterAPIAdapter = a
+		return a, nil
+	case "auto":
+		a, err := http.NewEnapterAPIAutoAdapter(http.EnapterAPIAutoAdapterParams{
+			Logger:      logger,
+			APIURL:      e.EnapterAPIURL,
+			APIToken:    e.EnapterAPIToken,
+			Retry:       s.retryPolicy(),
+			RateLimiter: rateLimiter,
+			Transport:   transport,
+			Timeout:     s.clientTimeout(),
+			Metrics:     m,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("new Enapter API auto adapter: %w", err)
+		}
+		// The data source remains usable while the API is unreachable, the
+		// version is detected again later.
+		a.DetectInBackground()
+		return a, nil
 	default:
-		return nil, fmt.Errorf(`%w: want "v1" or "v3", have %q`,
-			errUnsupportedAPIVersion, apiVersion)
+		return nil, fmt.Errorf(`%w: want "v1", "v3" or "auto", have %q`,
+			errUnsupportedAPIVersion, e.EnapterAPIVersion)
 	}
+}
 
-	var userResolver core.UserResolverPort = core.NoopUserResolver{}
-	if url := jsonData.UserResolverURL; url != "" {
-		userResolver = http.NewUserResolverAdapter(http.UserResolverAdapterParams{
-			URL: url,
-		})
+func newUserResolver(s *dataSourceSettings) (core.UserResolverPort, error) {
+	resolverType := s.userResolverType()
+	if resolverType == "" {
+		return core.NoopUserResolver{}, nil
 	}
 
-	dataSource := core.NewDataSource(core.DataSourceParams{
-		Logger:       logger,
-		EnapterAPI:   enapterAPIAdapter,
-		UserResolver: userResolver,
+	tlsConfig, err := s.userResolverTLSConfig()
+	if err != nil {
+		return nil, fmt.Errorf("TLS config: %w", err)
+	}
+	// The Enapter API TLS settings are not applied.
+	transport, err := http.NewTransport(http.TransportParams{
+		TLSConfig:             tlsConfig,
+		ProxyURL:              s.proxyURL,
+		SecureSocksProxy:      s.secureSocksProxyOptions(),
+		ConnectTimeout:        s.connectTimeout,
+		ResponseHeaderTimeout: s.responseHeaderTimeout,
 	})
+	if err != nil {
+		return nil, fmt.Errorf("transport: %w", err)
+	}
+	transport = http.NewTracingTransport(
+		http.NewAuthTransport(transport, s.userResolverAuth()))
 
-	logger.Info("created new data source",
-		"api_url", apiURL,
-		"api_version", apiVersion,
-	)
+	switch resolverType {
+	case "service":
+		return http.NewUserResolverAdapter(http.UserResolverAdapterParams{
+			URL:             s.UserResolverURL,
+			Timeout:         s.clientTimeout(),
+			Transport:       transport,
+			ResponseSchemas: s.UserResolverResponseSchemas,
+		})
+	case "file":
+		return userresolver.NewFileAdapter(userresolver.FileAdapterParams{
+			Path: s.UserResolverFilePath,
+		})
+	case "attribute":
+		return userresolver.NewAttributeAdapter(userresolver.AttributeAdapterParams{
+			Attribute: s.UserResolverAttribute,
+			Pattern:   s.UserResolverAttributePattern,
+		})
+	case "http":
+		return userresolver.NewHTTPAdapter(userresolver.HTTPAdapterParams{
+			URLTemplate:     s.UserResolverURLTemplate,
+			Method:          s.UserResolverMethod,
+			AuthHeaderName:  s.UserResolverAuthHeaderName,
+			AuthHeaderValue: s.UserResolverAuthHeaderValue,
+			IDPath:          s.UserResolverIDPath,
+			Timeout:         s.clientTimeout(),
+			Transport:       transport,
+		})
+	default:
+		return nil, fmt.Errorf(`%w: want "service", "file", "attribute" or "http", have %q`,
+			errUnsupportedUserResolverType, resolverType)
+	}
+}
 
-	return &dataSourceInstance{
-		logger:             logger,
-		enapterAPIAdapter:  enapterAPIAdapter,
-		QueryDataHandler:   dataSource,
-		CheckHealthHandler: dataSource,
-	}, nil
+func newAuditLog(logger log.Logger, s *dataSourceSettings) (core.AuditLogPort, error) {
+	switch s.AuditLogSink {
+	case "":
+		return core.NoopAuditLog{}, nil
+	case "file":
+		return auditlog.NewFileAdapter(auditlog.FileAdapterParams{
+			Logger: logger,
+			Path:   s.AuditLogFilePath,
+		})
+	case "webhook":
+		// The Enapter API TLS settings are not applied.
+		transport, err := http.NewTransport(http.TransportParams{
+			ProxyURL:              s.proxyURL,
+			SecureSocksProxy:      s.secureSocksProxyOptions(),
+			ConnectTimeout:        s.connectTimeout,
+			ResponseHeaderTimeout: s.responseHeaderTimeout,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("transport: %w", err)
+		}
+		return auditlog.NewWebhookAdapter(auditlog.WebhookAdapterParams{
+			URL:       s.AuditLogWebhookURL,
+			Token:     s.AuditLogWebhookToken,
+			Transport: http.NewTracingTransport(transport),
+		})
+	default:
+		return nil, fmt.Errorf(`%w: want "file" or "webhook", have %q`,
+			errUnsupportedAuditLogSink, s.AuditLogSink)
+	}
 }
 
 func (d *dataSourceInstance) Dispose() {
-	d.enapterAPIAdapter.Close()
+	for _, a := range d.enapterAPIAdapters {
+		a.Close()
+	}
 
 	d.logger.Info("disposed data source")
 }
@@ -1,18 +1,26 @@
 package grafana_test
 
 import (
+	"cmp"
+	"context"
 	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
-	"github.com/hashicorp/go-hclog"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 	"github.com/stretchr/testify/require"
 
+	"github.com/Enapter/grafana-plugins/pkg/core"
 	"github.com/Enapter/grafana-plugins/pkg/grafana"
 )
 
 func TestNewDataSourceInstance(t *testing.T) {
-	logger := hclog.NewNullLogger()
+	logger := log.DefaultLogger
 
 	t.Run("should NOT fail if JSONData contains boolean", func(t *testing.T) {
 		jsonData, err := json.Marshal(map[string]any{
@@ -30,4 +38,420 @@ func TestNewDataSourceInstance(t *testing.T) {
 		require.NoError(t, err)
 		require.NotNil(t, instance)
 	})
+
+	t.Run("should fail if commands minimum role is invalid", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "https://api.enapter.com",
+			"enapterAPIVersion": "v3",
+			"commandsMinRole":   "Superuser",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, `invalid role: "Superuser"`)
+	})
+
+	t.Run("should fail if user resolver auth header is overwritten", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":           "https://api.enapter.com",
+			"enapterAPIVersion":       "v3",
+			"userResolverType":        "http",
+			"userResolverURLTemplate": "https://idp.example/users/{{.Login}}",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+			DecryptedSecureJSONData: map[string]string{
+				"userResolverAuthHeaderValue": "Token secret",
+				"userResolverBearerToken":     "secret",
+			},
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, "user resolver auth header conflicts")
+	})
+
+	t.Run("should fail if commands debounce interval is invalid", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":            "https://api.enapter.com",
+			"enapterAPIVersion":        "v3",
+			"commandsDebounceInterval": "soon",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, "commands debounce interval")
+	})
+
+	t.Run("should fail if retry max backoff is invalid", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "https://api.enapter.com",
+			"enapterAPIVersion": "v3",
+			"retryMaxBackoff":   "-1s",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, "retry max backoff: negative duration")
+	})
+
+	t.Run("should detect API version", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     server.URL,
+			"enapterAPIVersion": "auto",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		// The instance is created even though no version is detected.
+		instance, err := grafana.NewDataSourceInstance(logger, settings)
+		require.NoError(t, err)
+		defer instance.Dispose()
+
+		result, err := instance.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusError, result.Status)
+		require.Contains(t, result.Message, "api_version: Enapter API version not detected")
+	})
+
+	t.Run("should report health per endpoint", func(t *testing.T) {
+		var mu sync.Mutex
+		tokens := make(map[string]bool)
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				tokens[r.Header.Get("X-Enapter-Auth-Token")] = true
+				mu.Unlock()
+				w.WriteHeader(http.StatusNotFound)
+			}))
+		defer server.Close()
+
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     server.URL,
+			"enapterAPIVersion": "v3",
+			"enapterAPIName":    "plant-a",
+			"endpoints": []map[string]any{{
+				"name":          "plant-b",
+				"enapterAPIURL": server.URL,
+			}},
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+			DecryptedSecureJSONData: map[string]string{
+				"enapterAPIToken":         "token-a",
+				"enapterAPIToken.plant-b": "token-b",
+			},
+		}
+
+		instance, err := grafana.NewDataSourceInstance(logger, settings)
+		require.NoError(t, err)
+		defer instance.Dispose()
+
+		result, err := instance.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusError, result.Status)
+		require.Contains(t, result.Message, "plant-a/devices_api: ")
+		require.Contains(t, result.Message, "plant-b/devices_api: ")
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.True(t, tokens["token-a"])
+		require.True(t, tokens["token-b"])
+	})
+
+	t.Run("should fail if endpoint names are not unique", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "https://api.enapter.com",
+			"enapterAPIVersion": "v3",
+			"endpoints": []map[string]any{{
+				"name":          "default",
+				"enapterAPIURL": "https://gateway.local",
+			}},
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, `endpoint name must be non-empty and unique: "default"`)
+	})
+
+	t.Run("should fail if audit log file path is missing", func(t *testing.T) {
+		t.Setenv("GF_PATHS_DATA", "")
+
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "https://api.enapter.com",
+			"enapterAPIVersion": "v3",
+			"auditLogSink":      "file",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, `audit log file path is required`)
+	})
+
+	t.Run("should put audit log into Grafana data directory", func(t *testing.T) {
+		dataPath := t.TempDir()
+		t.Setenv("GF_PATHS_DATA", dataPath)
+
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "https://api.enapter.com",
+			"enapterAPIVersion": "v3",
+			"auditLogSink":      "file",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			UID:      "ds",
+			JSONData: jsonData,
+		}
+
+		instance, err := grafana.NewDataSourceInstance(logger, settings)
+		require.NoError(t, err)
+		defer instance.Dispose()
+
+		require.DirExists(t, filepath.Join(dataPath, "plugins-data", "enapter-api", "ds"))
+	})
+
+	t.Run("should fail if log level is unsupported", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "https://api.enapter.com",
+			"enapterAPIVersion": "v3",
+			"logLevel":          "verbose",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, `unsupported log level`)
+	})
+
+	t.Run("should enable wire debugging", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":           "https://api.enapter.com",
+			"enapterAPIVersion":       "v3",
+			"logLevel":                "debug",
+			"wireDebug":               true,
+			"wireDebugRedactedFields": []string{"pin"},
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		instance, err := grafana.NewDataSourceInstance(logger, settings)
+		require.NoError(t, err)
+		instance.Dispose()
+	})
+
+	t.Run("should accept CA certificate", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.NotFoundHandler())
+		defer server.Close()
+		caCert := pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: server.Certificate().Raw,
+		})
+
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     server.URL,
+			"enapterAPIVersion": "v3",
+			"tlsAuthWithCACert": true,
+			"serverName":        "example.com",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+			DecryptedSecureJSONData: map[string]string{
+				"tlsCACert": string(caCert),
+			},
+		}
+
+		instance, err := grafana.NewDataSourceInstance(logger, settings)
+		require.NoError(t, err)
+		defer instance.Dispose()
+
+		// The server does not implement the API, but the TLS handshake
+		// must succeed.
+		result, err := instance.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusError, result.Status)
+		require.NotContains(t, result.Message, "certificate")
+		require.Contains(t, result.Message, "process timeseries response")
+	})
+
+	t.Run("should fail if CA certificate is invalid", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "https://gateway.local",
+			"enapterAPIVersion": "v3",
+			"tlsAuthWithCACert": true,
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+			DecryptedSecureJSONData: map[string]string{
+				"tlsCACert": "not a certificate",
+			},
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, "no valid PEM certificates in CA certificate")
+	})
+
+	t.Run("should fail if client certificate is missing", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "https://gateway.local",
+			"enapterAPIVersion": "v1",
+			"tlsAuth":           true,
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, "client certificate")
+	})
+
+	t.Run("should send requests through HTTP proxy", func(t *testing.T) {
+		var (
+			proxiedHost string
+			proxyAuth   string
+		)
+		proxyServer := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				proxiedHost = r.Host
+				proxyAuth = r.Header.Get("Proxy-Authorization")
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+		defer proxyServer.Close()
+
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "http://enapter-api.example",
+			"enapterAPIVersion": "v3",
+			"httpProxyURL":      proxyServer.URL,
+			"httpProxyUsername": "grafana",
+			"retryMaxAttempts":  1,
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+			DecryptedSecureJSONData: map[string]string{
+				"httpProxyPassword": "secret",
+			},
+		}
+
+		instance, err := grafana.NewDataSourceInstance(logger, settings)
+		require.NoError(t, err)
+		defer instance.Dispose()
+
+		_, err = instance.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+		require.Equal(t, "enapter-api.example", proxiedHost)
+		require.Equal(t, "Basic Z3JhZmFuYTpzZWNyZXQ=", proxyAuth)
+	})
+
+	t.Run("should forward OAuth token", func(t *testing.T) {
+		var (
+			mu            sync.Mutex
+			authorization string
+			apiToken      string
+		)
+		// The health checks run concurrently, and the connection check
+		// sends no credentials.
+		server := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				authorization = cmp.Or(r.Header.Get("Authorization"), authorization)
+				apiToken = cmp.Or(r.Header.Get("X-Enapter-Auth-Token"), apiToken)
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+		defer server.Close()
+
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     server.URL,
+			"enapterAPIVersion": "v3",
+			"oauthPassThru":     true,
+			"retryMaxAttempts":  1,
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+			DecryptedSecureJSONData: map[string]string{
+				"enapterAPIToken": "static",
+			},
+		}
+
+		instance, err := grafana.NewDataSourceInstance(logger, settings)
+		require.NoError(t, err)
+		defer instance.Dispose()
+
+		result, err := instance.CheckHealth(context.Background(), &backend.CheckHealthRequest{})
+		require.NoError(t, err)
+		require.Equal(t, backend.HealthStatusError, result.Status)
+		require.Equal(t, core.ErrOAuthTokenMissing.Error(), result.Message)
+
+		_, err = instance.CheckHealth(context.Background(), &backend.CheckHealthRequest{
+			Headers: map[string]string{
+				backend.OAuthIdentityTokenHeaderName: "Bearer user-token",
+			},
+		})
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		require.Equal(t, "Bearer user-token", authorization)
+		require.Empty(t, apiToken)
+	})
+
+	t.Run("should fail if HTTP proxy scheme is unsupported", func(t *testing.T) {
+		jsonData, err := json.Marshal(map[string]any{
+			"enapterAPIURL":     "https://api.enapter.com",
+			"enapterAPIVersion": "v3",
+			"httpProxyURL":      "ftp://proxy.local",
+		})
+		require.NoError(t, err)
+
+		settings := backend.DataSourceInstanceSettings{
+			JSONData: jsonData,
+		}
+
+		_, err = grafana.NewDataSourceInstance(logger, settings)
+		require.ErrorContains(t, err, `unsupported proxy scheme: "ftp"`)
+	})
 }
@@ -2,4 +2,21 @@ package grafana
 
 import "errors"
 
-var errUnsupportedAPIVersion = errors.New("unsupported API version")
+var (
+	errUnsupportedAPIVersion       = errors.New("unsupported API version")
+	errUnsupportedAuditLogSink     = errors.New("unsupported audit log sink")
+	errAuditLogFilePathMissing     = errors.New("audit log file path is required without GF_PATHS_DATA")
+	errUnsupportedUserResolverType = errors.New("unsupported user resolver type")
+	errInvalidRole                 = errors.New("invalid role")
+	errNegativeDuration            = errors.New("negative duration")
+	errInvalidRetryMaxAttempts     = errors.New("invalid retry max attempts")
+	errInvalidRateLimit            = errors.New("rate limit settings must not be negative")
+	errInvalidFailureThreshold     = errors.New("invalid circuit breaker failure threshold")
+	errInvalidCacheMaxEntries      = errors.New("invalid user resolver cache max entries")
+	errInvalidCACert               = errors.New("no valid PEM certificates in CA certificate")
+	errUnsupportedProxyScheme      = errors.New("unsupported proxy scheme")
+	errConflictingUserResolverAuth = errors.New("user resolver bearer token and basic auth are mutually exclusive")
+	errOverwrittenAuthHeader       = errors.New("user resolver auth header conflicts with bearer token and basic auth")
+	errUnsupportedLogLevel         = errors.New("unsupported log level")
+	errInvalidEndpointName         = errors.New("endpoint name must be non-empty and unique")
+)
@@ -0,0 +1,73 @@
+package grafana
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+// levelLogger drops messages less severe than its level. The SDK logger is
+// shared by all data sources, so their levels cannot be set on it.
+type levelLogger struct {
+	next  log.Logger
+	level log.Level
+}
+
+func newLevelLogger(next log.Logger, level log.Level) log.Logger {
+	if level == log.NoLevel {
+		return next
+	}
+	return &levelLogger{next: next, level: level}
+}
+
+func (l *levelLogger) Debug(msg string, args ...any) {
+	if l.level <= log.Debug {
+		l.next.Debug(msg, args...)
+	}
+}
+
+func (l *levelLogger) Info(msg string, args ...any) {
+	if l.level <= log.Info {
+		l.next.Info(msg, args...)
+	}
+}
+
+func (l *levelLogger) Warn(msg string, args ...any) {
+	if l.level <= log.Warn {
+		l.next.Warn(msg, args...)
+	}
+}
+
+func (l *levelLogger) Error(msg string, args ...any) {
+	if l.level <= log.Error {
+		l.next.Error(msg, args...)
+	}
+}
+
+func (l *levelLogger) With(args ...any) log.Logger {
+	return &levelLogger{next: l.next.With(args...), level: l.level}
+}
+
+func (l *levelLogger) Level() log.Level {
+	return max(l.level, l.next.Level())
+}
+
+func parseLogLevel(s string) (log.Level, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return log.NoLevel, nil
+	case "debug":
+		return log.Debug, nil
+	case "info":
+		return log.Info, nil
+	case "warn":
+		return log.Warn, nil
+	case "error":
+		return log.Error, nil
+	default:
+		return log.NoLevel, fmt.Errorf(
+			`%w: want "debug", "info", "warn" or "error", have %q`,
+			errUnsupportedLogLevel, s)
+	}
+}
@@ -1,27 +1,21 @@
 package grafana
 
 import (
-	"os"
-
 	"github.com/grafana/grafana-plugin-sdk-go/backend"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/datasource"
 	"github.com/grafana/grafana-plugin-sdk-go/backend/instancemgmt"
-	"github.com/hashicorp/go-hclog"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 )
 
 type Plugin struct {
 	name   string
-	logger hclog.Logger
+	logger log.Logger
 }
 
 func NewPlugin() *Plugin {
 	return &Plugin{
-		name: "enapter-api",
-		logger: hclog.New(&hclog.LoggerOptions{
-			Level:      hclog.Debug,
-			Output:     os.Stderr,
-			JSONFormat: true,
-		}),
+		name:   "enapter-api",
+		logger: log.DefaultLogger,
 	}
 }
 
@@ -0,0 +1,596 @@
+package grafana
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/proxy"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/http"
+	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi"
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
+)
+
+//nolint:tagliatelle // js
+type dataSourceSettings struct {
+	EnapterAPIURL      string `json:"enapterAPIURL"`
+	EnapterAPIVersion  string `json:"enapterAPIVersion"`
+	EnapterAPIName     string `json:"enapterAPIName"`
+	UserResolverURL    string `json:"userResolverURL"`
+	UserResolverType   string `json:"userResolverType"`
+	AuditLogSink       string `json:"auditLogSink"`
+	AuditLogFilePath   string `json:"auditLogFilePath"`
+	AuditLogWebhookURL string `json:"auditLogWebhookURL"`
+	AuditLogMinRole    string `json:"auditLogMinRole"`
+
+	CommandsReadOnly        bool     `json:"commandsReadOnly"`
+	CommandsMinRole         string   `json:"commandsMinRole"`
+	CommandsAllowedCommands []string `json:"commandsAllowedCommands"`
+	CommandsAllowedDevices  []string `json:"commandsAllowedDevices"`
+
+	CommandsIdempotencyKeyTTL string   `json:"commandsIdempotencyKeyTTL"`
+	CommandsDebounceInterval  string   `json:"commandsDebounceInterval"`
+	CommandsNonIdempotent     []string `json:"commandsNonIdempotent"`
+
+	RetryMaxAttempts    int    `json:"retryMaxAttempts"`
+	RetryInitialBackoff string `json:"retryInitialBackoff"`
+	RetryMaxBackoff     string `json:"retryMaxBackoff"`
+
+	RateLimitRequestsPerSecond float64 `json:"rateLimitRequestsPerSecond"`
+	RateLimitBurst             int     `json:"rateLimitBurst"`
+	RateLimitMaxQueued         int     `json:"rateLimitMaxQueued"`
+
+	UserResolverFilePath         string `json:"userResolverFilePath"`
+	UserResolverAttribute        string `json:"userResolverAttribute"`
+	UserResolverAttributePattern string `json:"userResolverAttributePattern"`
+	UserResolverURLTemplate      string `json:"userResolverURLTemplate"`
+	UserResolverMethod           string `json:"userResolverMethod"`
+	UserResolverAuthHeaderName   string `json:"userResolverAuthHeaderName"`
+	UserResolverIDPath           string `json:"userResolverIDPath"`
+
+	UserResolverResponseSchemas   []string `json:"userResolverResponseSchemas"`
+	UserResolverBasicAuthUser     string   `json:"userResolverBasicAuthUser"`
+	UserResolverTLSAuth           bool     `json:"userResolverTlsAuth"`
+	UserResolverTLSAuthWithCACert bool     `json:"userResolverTlsAuthWithCACert"`
+
+	UserResolverCacheDisabled    bool   `json:"userResolverCacheDisabled"`
+	UserResolverCacheTTL         string `json:"userResolverCacheTTL"`
+	UserResolverCacheNegativeTTL string `json:"userResolverCacheNegativeTTL"`
+	UserResolverCacheStaleGrace  string `json:"userResolverCacheStaleGrace"`
+	UserResolverCacheMaxEntries  int    `json:"userResolverCacheMaxEntries"`
+
+	CircuitBreakerDisabled         bool   `json:"circuitBreakerDisabled"`
+	CircuitBreakerFailureThreshold int    `json:"circuitBreakerFailureThreshold"`
+	CircuitBreakerCooldown         string `json:"circuitBreakerCooldown"`
+
+	TLSSkipVerify     bool   `json:"tlsSkipVerify"`
+	TLSAuth           bool   `json:"tlsAuth"`
+	TLSAuthWithCACert bool   `json:"tlsAuthWithCACert"`
+	TLSServerName     string `json:"serverName"`
+
+	QueryTimeout          string `json:"queryTimeout"`
+	QueryMaxTimeout       string `json:"queryMaxTimeout"`
+	ConnectTimeout        string `json:"connectTimeout"`
+	ResponseHeaderTimeout string `json:"responseHeaderTimeout"`
+
+	HTTPProxyURL           string `json:"httpProxyURL"`
+	HTTPProxyUsername      string `json:"httpProxyUsername"`
+	EnableSecureSocksProxy bool   `json:"enableSecureSocksProxy"`
+
+	// OAuthPassThru is the standard Grafana option forwarding the OAuth
+	// identity of the user to the data source.
+	OAuthPassThru bool `json:"oauthPassThru"`
+
+	// Endpoints are the Enapter APIs telemetry queries are federated
+	// across in addition to the one above.
+	Endpoints []endpointSettings `json:"endpoints"`
+
+	LogLevel string `json:"logLevel"`
+	// WireDebug logs Enapter API requests and responses at the debug level.
+	WireDebug               bool     `json:"wireDebug"`
+	WireDebugRedactedFields []string `json:"wireDebugRedactedFields"`
+
+	EnapterAPIToken             string `json:"-"`
+	UserResolverAuthHeaderValue string `json:"-"`
+	UserResolverBearerToken     string `json:"-"`
+	UserResolverBasicAuthPass   string `json:"-"`
+	UserResolverHMACKey         string `json:"-"`
+	UserResolverTLSCACert       string `json:"-"`
+	UserResolverTLSClientCert   string `json:"-"`
+	UserResolverTLSClientKey    string `json:"-"`
+	AuditLogWebhookToken        string `json:"-"`
+	TLSCACert                   string `json:"-"`
+	TLSClientCert               string `json:"-"`
+	TLSClientKey                string `json:"-"`
+	HTTPProxyPassword           string `json:"-"`
+	SecureSocksProxyPass        string `json:"-"`
+
+	commandsIdempotencyKeyTTL    time.Duration
+	commandsDebounceInterval     time.Duration
+	retryInitialBackoff          time.Duration
+	retryMaxBackoff              time.Duration
+	circuitBreakerCooldown       time.Duration
+	userResolverCacheTTL         time.Duration
+	userResolverCacheNegativeTTL time.Duration
+	userResolverCacheStaleGrace  time.Duration
+	queryTimeout                 time.Duration
+	queryMaxTimeout              time.Duration
+	connectTimeout               time.Duration
+	responseHeaderTimeout        time.Duration
+	proxyURL                     *url.URL
+	logLevel                     log.Level
+	uid                          string
+}
+
+//nolint:tagliatelle // js
+type endpointSettings struct {
+	Name              string `json:"name"`
+	EnapterAPIURL     string `json:"enapterAPIURL"`
+	EnapterAPIVersion string `json:"enapterAPIVersion"`
+	EnapterAPIToken   string `json:"-"`
+}
+
+const (
+	defaultCommandsIdempotencyKeyTTL = 10 * time.Minute
+	defaultRateLimitMaxQueued        = 100
+
+	defaultUserResolverCacheTTL         = 5 * time.Minute
+	defaultUserResolverCacheNegativeTTL = 30 * time.Second
+	defaultUserResolverCacheStaleGrace  = time.Hour
+	defaultUserResolverCacheMaxEntries  = 10000
+
+	defaultCircuitBreakerFailureThreshold = 5
+	defaultCircuitBreakerCooldown         = 30 * time.Second
+
+	defaultEnapterAPIName = "default"
+
+	pluginID = "enapter-api"
+
+	defaultQueryTimeout    = 15 * time.Second
+	defaultQueryMaxTimeout = time.Minute
+	defaultConnectTimeout  = 10 * time.Second
+)
+
+func parseSettings(s backend.DataSourceInstanceSettings) (*dataSourceSettings, error) {
+	var out dataSourceSettings
+	if err := json.Unmarshal(s.JSONData, &out); err != nil {
+		return nil, fmt.Errorf("JSON data: %w", err)
+	}
+
+	out.EnapterAPIToken = s.DecryptedSecureJSONData["enapterAPIToken"]
+	out.UserResolverAuthHeaderValue = s.DecryptedSecureJSONData["userResolverAuthHeaderValue"]
+	out.UserResolverBearerToken = s.DecryptedSecureJSONData["userResolverBearerToken"]
+	out.UserResolverBasicAuthPass = s.DecryptedSecureJSONData["userResolverBasicAuthPassword"]
+	out.UserResolverHMACKey = s.DecryptedSecureJSONData["userResolverHMACKey"]
+	out.UserResolverTLSCACert = s.DecryptedSecureJSONData["userResolverTlsCACert"]
+	out.UserResolverTLSClientCert = s.DecryptedSecureJSONData["userResolverTlsClientCert"]
+	out.UserResolverTLSClientKey = s.DecryptedSecureJSONData["userResolverTlsClientKey"]
+	out.AuditLogWebhookToken = s.DecryptedSecureJSONData["auditLogWebhookToken"]
+	out.TLSCACert = s.DecryptedSecureJSONData["tlsCACert"]
+	out.TLSClientCert = s.DecryptedSecureJSONData["tlsClientCert"]
+	out.TLSClientKey = s.DecryptedSecureJSONData["tlsClientKey"]
+	out.HTTPProxyPassword = s.DecryptedSecureJSONData["httpProxyPassword"]
+	out.SecureSocksProxyPass = s.DecryptedSecureJSONData["secureSocksProxyPassword"]
+	out.uid = s.UID
+
+	if err := out.parseEndpoints(s.DecryptedSecureJSONData); err != nil {
+		return nil, err
+	}
+
+	if out.CommandsMinRole == "" {
+		// Commands change the state of devices, which viewers are not
+		// expected to do.
+		out.CommandsMinRole = string(core.RoleEditor)
+	}
+	if !core.Role(out.CommandsMinRole).Valid() {
+		return nil, fmt.Errorf("%w: %q", errInvalidRole, out.CommandsMinRole)
+	}
+	if out.AuditLogMinRole == "" {
+		// The audit log records the commands of every user.
+		out.AuditLogMinRole = string(core.RoleAdmin)
+	}
+	if !core.Role(out.AuditLogMinRole).Valid() {
+		return nil, fmt.Errorf("%w: %q", errInvalidRole, out.AuditLogMinRole)
+	}
+
+	var err error
+	out.commandsIdempotencyKeyTTL, err = parseDurationSetting(
+		out.CommandsIdempotencyKeyTTL, defaultCommandsIdempotencyKeyTTL)
+	if err != nil {
+		return nil, fmt.Errorf("commands idempotency key TTL: %w", err)
+	}
+	// Debouncing is disabled by default.
+	out.commandsDebounceInterval, err = parseDurationSetting(
+		out.CommandsDebounceInterval, 0)
+	if err != nil {
+		return nil, fmt.Errorf("commands debounce interval: %w", err)
+	}
+
+	if out.RetryMaxAttempts == 0 {
+		out.RetryMaxAttempts = enapterapi.DefaultRetryMaxAttempts
+	}
+	if out.RetryMaxAttempts < 0 {
+		return nil, fmt.Errorf("%w: %d", errInvalidRetryMaxAttempts, out.RetryMaxAttempts)
+	}
+	out.retryInitialBackoff, err = parseDurationSetting(
+		out.RetryInitialBackoff, enapterapi.DefaultRetryInitialBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("retry initial backoff: %w", err)
+	}
+	out.retryMaxBackoff, err = parseDurationSetting(
+		out.RetryMaxBackoff, enapterapi.DefaultRetryMaxBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("retry max backoff: %w", err)
+	}
+
+	// Rate limiting is disabled by default.
+	if out.RateLimitRequestsPerSecond < 0 || out.RateLimitBurst < 0 ||
+		out.RateLimitMaxQueued < 0 {
+		return nil, errInvalidRateLimit
+	}
+	if out.RateLimitMaxQueued == 0 {
+		out.RateLimitMaxQueued = defaultRateLimitMaxQueued
+	}
+
+	if out.CircuitBreakerFailureThreshold == 0 {
+		out.CircuitBreakerFailureThreshold = defaultCircuitBreakerFailureThreshold
+	}
+	if out.CircuitBreakerFailureThreshold < 0 {
+		return nil, fmt.Errorf("%w: %d", errInvalidFailureThreshold,
+			out.CircuitBreakerFailureThreshold)
+	}
+	out.circuitBreakerCooldown, err = parseDurationSetting(
+		out.CircuitBreakerCooldown, defaultCircuitBreakerCooldown)
+	if err != nil {
+		return nil, fmt.Errorf("circuit breaker cooldown: %w", err)
+	}
+
+	if out.UserResolverBearerToken != "" && out.UserResolverBasicAuthUser != "" {
+		return nil, errConflictingUserResolverAuth
+	}
+	if out.userResolverAuthHeaderOverwritten() {
+		return nil, errOverwrittenAuthHeader
+	}
+
+	if err := out.parseUserResolverCache(); err != nil {
+		return nil, err
+	}
+
+	if err := out.parseTimeouts(); err != nil {
+		return nil, err
+	}
+
+	if out.HTTPProxyURL != "" {
+		out.proxyURL, err = parseProxyURL(
+			out.HTTPProxyURL, out.HTTPProxyUsername, out.HTTPProxyPassword)
+		if err != nil {
+			return nil, fmt.Errorf("HTTP proxy URL: %w", err)
+		}
+	}
+
+	out.logLevel, err = parseLogLevel(out.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	if out.AuditLogSink == "file" && out.AuditLogFilePath == "" {
+		out.AuditLogFilePath, err = defaultAuditLogFilePath(out.uid)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &out, nil
+}
+
+func parseDurationSetting(s string, defaultValue time.Duration) (time.Duration, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%w: %s", errNegativeDuration, s)
+	}
+	return d, nil
+}
+
+func (s *dataSourceSettings) parseUserResolverCache() error {
+	if s.UserResolverCacheMaxEntries == 0 {
+		s.UserResolverCacheMaxEntries = defaultUserResolverCacheMaxEntries
+	}
+	if s.UserResolverCacheMaxEntries < 0 {
+		return fmt.Errorf("%w: %d", errInvalidCacheMaxEntries,
+			s.UserResolverCacheMaxEntries)
+	}
+	var err error
+	s.userResolverCacheTTL, err = parseDurationSetting(
+		s.UserResolverCacheTTL, defaultUserResolverCacheTTL)
+	if err != nil {
+		return fmt.Errorf("user resolver cache TTL: %w", err)
+	}
+	s.userResolverCacheNegativeTTL, err = parseDurationSetting(
+		s.UserResolverCacheNegativeTTL, defaultUserResolverCacheNegativeTTL)
+	if err != nil {
+		return fmt.Errorf("user resolver cache negative TTL: %w", err)
+	}
+	s.userResolverCacheStaleGrace, err = parseDurationSetting(
+		s.UserResolverCacheStaleGrace, defaultUserResolverCacheStaleGrace)
+	if err != nil {
+		return fmt.Errorf("user resolver cache stale grace: %w", err)
+	}
+	return nil
+}
+
+func (s *dataSourceSettings) parseTimeouts() error {
+	var err error
+	s.queryTimeout, err = parseDurationSetting(s.QueryTimeout, defaultQueryTimeout)
+	if err != nil {
+		return fmt.Errorf("query timeout: %w", err)
+	}
+	s.queryMaxTimeout, err = parseDurationSetting(s.QueryMaxTimeout, defaultQueryMaxTimeout)
+	if err != nil {
+		return fmt.Errorf("query max timeout: %w", err)
+	}
+	s.connectTimeout, err = parseDurationSetting(s.ConnectTimeout, defaultConnectTimeout)
+	if err != nil {
+		return fmt.Errorf("connect timeout: %w", err)
+	}
+	// Waiting for response headers is bounded by the query timeout only by
+	// default.
+	s.responseHeaderTimeout, err = parseDurationSetting(s.ResponseHeaderTimeout, 0)
+	if err != nil {
+		return fmt.Errorf("response header timeout: %w", err)
+	}
+	return nil
+}
+
+// parseEndpoints reads the token of every endpoint from the secure JSON data
+// under a key of its own.
+func (s *dataSourceSettings) parseEndpoints(secure map[string]string) error {
+	if len(s.Endpoints) == 0 {
+		return nil
+	}
+	if s.EnapterAPIName == "" {
+		s.EnapterAPIName = defaultEnapterAPIName
+	}
+
+	names := map[string]bool{s.EnapterAPIName: true}
+	for i := range s.Endpoints {
+		e := &s.Endpoints[i]
+		if e.Name == "" || names[e.Name] {
+			return fmt.Errorf("%w: %q", errInvalidEndpointName, e.Name)
+		}
+		names[e.Name] = true
+
+		if e.EnapterAPIVersion == "" {
+			e.EnapterAPIVersion = s.EnapterAPIVersion
+		}
+		e.EnapterAPIToken = secure["enapterAPIToken."+e.Name]
+	}
+	return nil
+}
+
+// defaultAuditLogFilePath points into the data directory of Grafana rather
+// than into the plugin directory, which is replaced on upgrades. Every data
+// source gets a directory of its own.
+func defaultAuditLogFilePath(uid string) (string, error) {
+	dataPath := os.Getenv("GF_PATHS_DATA")
+	if dataPath == "" {
+		return "", errAuditLogFilePathMissing
+	}
+	return filepath.Join(dataPath, "plugins-data", pluginID, uid, "audit_log.jsonl"), nil
+}
+
+func parseProxyURL(s, username, password string) (*url.URL, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: %q", errUnsupportedProxyScheme, u.Scheme)
+	}
+	if username != "" {
+		u.User = url.UserPassword(username, password)
+	}
+	return u, nil
+}
+
+func (s *dataSourceSettings) commandPolicy() core.CommandPolicy {
+	return core.CommandPolicy{
+		ReadOnly:        s.CommandsReadOnly,
+		MinRole:         core.Role(s.CommandsMinRole),
+		AllowedCommands: s.CommandsAllowedCommands,
+		AllowedDevices:  s.CommandsAllowedDevices,
+	}
+}
+
+func (s *dataSourceSettings) commandDeduplication() core.CommandDeduplication {
+	return core.CommandDeduplication{
+		IdempotencyKeyTTL:     s.commandsIdempotencyKeyTTL,
+		DebounceInterval:      s.commandsDebounceInterval,
+		NonIdempotentCommands: s.CommandsNonIdempotent,
+	}
+}
+
+func (s *dataSourceSettings) retryPolicy() enapterapi.RetryPolicy {
+	return enapterapi.RetryPolicy{
+		MaxAttempts:    s.RetryMaxAttempts,
+		InitialBackoff: s.retryInitialBackoff,
+		MaxBackoff:     s.retryMaxBackoff,
+	}
+}
+
+// rateLimiter returns nil if rate limiting is disabled.
+func (s *dataSourceSettings) rateLimiter(m *metrics.DataSource) *http.RateLimiter {
+	if s.RateLimitRequestsPerSecond == 0 {
+		return nil
+	}
+	return http.NewRateLimiter(http.RateLimiterParams{
+		RequestsPerSecond: s.RateLimitRequestsPerSecond,
+		Burst:             s.RateLimitBurst,
+		MaxQueued:         s.RateLimitMaxQueued,
+		Metrics:           m,
+	})
+}
+
+// userResolverType returns an empty string if users are not resolved. The
+// resolver service is used by default if its URL is set, as it has always
+// been.
+func (s *dataSourceSettings) userResolverType() string {
+	if s.UserResolverType == "" && s.UserResolverURL != "" {
+		return "service"
+	}
+	return s.UserResolverType
+}
+
+// userResolverCache caches only the resolvers calling remote services.
+func (s *dataSourceSettings) userResolverCache() core.UserResolverCache {
+	switch s.userResolverType() {
+	case "", "file", "attribute":
+		return core.UserResolverCache{}
+	}
+	if s.UserResolverCacheDisabled {
+		return core.UserResolverCache{}
+	}
+	return core.UserResolverCache{
+		TTL:         s.userResolverCacheTTL,
+		NegativeTTL: s.userResolverCacheNegativeTTL,
+		StaleGrace:  s.userResolverCacheStaleGrace,
+		MaxEntries:  s.UserResolverCacheMaxEntries,
+	}
+}
+
+func (s *dataSourceSettings) circuitBreaker() core.CircuitBreaker {
+	if s.CircuitBreakerDisabled {
+		return core.CircuitBreaker{}
+	}
+	return core.CircuitBreaker{
+		FailureThreshold: s.CircuitBreakerFailureThreshold,
+		Cooldown:         s.circuitBreakerCooldown,
+	}
+}
+
+// tlsConfig follows the conventions of the Grafana TLS settings. It returns
+// nil if the default configuration is to be used.
+func (s *dataSourceSettings) tlsConfig() (*tls.Config, error) {
+	if !s.TLSSkipVerify && !s.TLSAuth && !s.TLSAuthWithCACert && s.TLSServerName == "" {
+		return nil, nil //nolint:nilnil // default config
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		//nolint:gosec // explicitly requested by the administrator
+		InsecureSkipVerify: s.TLSSkipVerify,
+		ServerName:         s.TLSServerName,
+	}
+	if err := configureTLS(config, s.TLSAuthWithCACert, s.TLSCACert,
+		s.TLSAuth, s.TLSClientCert, s.TLSClientKey); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// userResolverTLSConfig is separate from the Enapter API one, because the
+// resolver is usually run by another party. It returns nil if the default
+// configuration is to be used.
+func (s *dataSourceSettings) userResolverTLSConfig() (*tls.Config, error) {
+	if !s.UserResolverTLSAuth && !s.UserResolverTLSAuthWithCACert {
+		return nil, nil //nolint:nilnil // default config
+	}
+
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+	if err := configureTLS(config,
+		s.UserResolverTLSAuthWithCACert, s.UserResolverTLSCACert,
+		s.UserResolverTLSAuth, s.UserResolverTLSClientCert, s.UserResolverTLSClientKey,
+	); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func configureTLS(
+	config *tls.Config, withCACert bool, caCert string,
+	withClientCert bool, clientCert, clientKey string,
+) error {
+	if withCACert {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caCert)) {
+			return errInvalidCACert
+		}
+		config.RootCAs = pool
+	}
+
+	if withClientCert {
+		cert, err := tls.X509KeyPair([]byte(clientCert), []byte(clientKey))
+		if err != nil {
+			return fmt.Errorf("client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return nil
+}
+
+// userResolverAuthHeaderOverwritten reports whether the bearer token or the
+// basic auth would replace the header the HTTP user resolver sets, which is
+// Authorization unless configured otherwise.
+func (s *dataSourceSettings) userResolverAuthHeaderOverwritten() bool {
+	if s.UserResolverAuthHeaderValue == "" ||
+		(s.UserResolverBearerToken == "" && s.UserResolverBasicAuthUser == "") {
+		return false
+	}
+	name := s.UserResolverAuthHeaderName
+	return name == "" || strings.EqualFold(name, "Authorization")
+}
+
+func (s *dataSourceSettings) userResolverAuth() http.AuthTransportParams {
+	return http.AuthTransportParams{
+		BearerToken: s.UserResolverBearerToken,
+		Username:    s.UserResolverBasicAuthUser,
+		Password:    s.UserResolverBasicAuthPass,
+		HMACKey:     s.UserResolverHMACKey,
+	}
+}
+
+// secureSocksProxyOptions authenticates the data source to the proxy the
+// same way Grafana does: by its UID and a password of its own.
+func (s *dataSourceSettings) secureSocksProxyOptions() *proxy.Options {
+	if !s.EnableSecureSocksProxy {
+		return nil
+	}
+	return &proxy.Options{
+		Enabled: true,
+		Auth: &proxy.AuthOptions{
+			Username: s.uid,
+			Password: s.SecureSocksProxyPass,
+		},
+	}
+}
+
+func (s *dataSourceSettings) timeouts() core.Timeouts {
+	return core.Timeouts{
+		Default: s.queryTimeout,
+		Max:     s.queryMaxTimeout,
+	}
+}
+
+// clientTimeout backs up the query timeouts, which are applied to request
+// contexts, in case a request is made without a deadline.
+func (s *dataSourceSettings) clientTimeout() time.Duration {
+	return max(s.queryTimeout, s.queryMaxTimeout)
+}
@@ -0,0 +1,102 @@
+package http
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type AuthTransportParams struct {
+	// BearerToken takes precedence over Username and Password.
+	BearerToken string
+	Username    string
+	Password    string
+	// HMACKey, if set, is used to sign every request. The signature is
+	// sent in the X-Signature header as "sha256=<hex>" and covers the
+	// timestamp sent in the X-Signature-Timestamp header, the method, the
+	// request URI and the SHA-256 of the body, separated by new lines.
+	HMACKey string
+}
+
+// NewAuthTransport returns a transport authenticating requests to services
+// other than Enapter API.
+func NewAuthTransport(next http.RoundTripper, p AuthTransportParams) http.RoundTripper {
+	if p.BearerToken == "" && p.Username == "" && p.HMACKey == "" {
+		return next
+	}
+	return &authTransport{
+		next:        next,
+		bearerToken: p.BearerToken,
+		username:    p.Username,
+		password:    p.Password,
+		hmacKey:     []byte(p.HMACKey),
+		now:         time.Now,
+	}
+}
+
+type authTransport struct {
+	next        http.RoundTripper
+	bearerToken string
+	username    string
+	password    string
+	hmacKey     []byte
+	now         func() time.Time
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrip must not modify the request.
+	req = req.Clone(req.Context())
+
+	switch {
+	case t.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+t.bearerToken)
+	case t.username != "":
+		req.SetBasicAuth(t.username, t.password)
+	}
+
+	if len(t.hmacKey) != 0 {
+		if err := t.sign(req); err != nil {
+			return nil, fmt.Errorf("sign request: %w", err)
+		}
+	}
+
+	return t.next.RoundTrip(req)
+}
+
+func (t *authTransport) sign(req *http.Request) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	bodyHash := sha256.Sum256(body)
+
+	timestamp := strconv.FormatInt(t.now().Unix(), 10)
+	mac := hmac.New(sha256.New, t.hmacKey)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s", timestamp, req.Method,
+		req.URL.RequestURI(), hex.EncodeToString(bodyHash[:]))
+
+	req.Header.Set("X-Signature-Timestamp", timestamp)
+	req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+func (t *authTransport) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if c, ok := t.next.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,70 @@
+package http_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Enapter/grafana-plugins/pkg/http"
+)
+
+func TestAuthTransport(t *testing.T) {
+	var received *nethttp.Request
+	var receivedBody []byte
+	server := httptest.NewServer(nethttp.HandlerFunc(
+		func(_ nethttp.ResponseWriter, r *nethttp.Request) {
+			received = r
+			receivedBody, _ = io.ReadAll(r.Body)
+		}))
+	defer server.Close()
+
+	do := func(t *testing.T, p http.AuthTransportParams, body string) {
+		t.Helper()
+		client := &nethttp.Client{
+			Transport: http.NewAuthTransport(nethttp.DefaultTransport, p),
+		}
+		req, err := nethttp.NewRequest(nethttp.MethodPost,
+			server.URL+"/users?email=jack%40example.com", strings.NewReader(body))
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	t.Run("should send bearer token", func(t *testing.T) {
+		do(t, http.AuthTransportParams{BearerToken: "secret"}, "")
+		require.Equal(t, "Bearer secret", received.Header.Get("Authorization"))
+	})
+
+	t.Run("should send basic auth", func(t *testing.T) {
+		do(t, http.AuthTransportParams{Username: "grafana", Password: "secret"}, "")
+		username, password, ok := received.BasicAuth()
+		require.True(t, ok)
+		require.Equal(t, "grafana", username)
+		require.Equal(t, "secret", password)
+	})
+
+	t.Run("should sign request", func(t *testing.T) {
+		const key = "hmac-key"
+		do(t, http.AuthTransportParams{HMACKey: key}, `{"a":1}`)
+		require.Equal(t, `{"a":1}`, string(receivedBody))
+
+		timestamp := received.Header.Get("X-Signature-Timestamp")
+		require.NotEmpty(t, timestamp)
+
+		bodyHash := sha256.Sum256(receivedBody)
+		mac := hmac.New(sha256.New, []byte(key))
+		fmt.Fprintf(mac, "%s\n%s\n%s\n%s", timestamp, nethttp.MethodPost,
+			"/users?email=jack%40example.com", hex.EncodeToString(bodyHash[:]))
+		require.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)),
+			received.Header.Get("X-Signature"))
+	})
+}
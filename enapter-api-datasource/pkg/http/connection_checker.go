@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	httputil "github.com/Enapter/grafana-plugins/pkg/http/util"
+)
+
+// connectionChecker tells TLS and proxy problems apart from the problems of
+// Enapter API itself. Any response means that the connection works.
+type connectionChecker struct {
+	httpClient *http.Client
+	url        string
+}
+
+func newConnectionChecker(
+	transport http.RoundTripper, url string, timeout time.Duration,
+) *connectionChecker {
+	return &connectionChecker{
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: NewTracingTransport(transport),
+		},
+		url: url,
+	}
+}
+
+func (c *connectionChecker) check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, c.url, nil)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return describeConnectionError(err)
+	}
+	return httputil.DrainAndClose(resp.Body)
+}
+
+func describeConnectionError(err error) error {
+	var (
+		certVerificationErr *tls.CertificateVerificationError
+		unknownAuthorityErr x509.UnknownAuthorityError
+		hostnameErr         x509.HostnameError
+		certInvalidErr      x509.CertificateInvalidError
+		recordHeaderErr     tls.RecordHeaderError
+		dnsErr              *net.DNSError
+		opErr               *net.OpError
+	)
+	switch {
+	case errors.As(err, &certVerificationErr),
+		errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &certInvalidErr),
+		errors.As(err, &recordHeaderErr):
+		return fmt.Errorf("%w: %w", errTLSHandshake, err)
+	case errors.As(err, &opErr) && opErr.Op == "proxyconnect":
+		return fmt.Errorf("%w: %w", errProxyConnect, err)
+	case errors.As(err, &dnsErr):
+		return fmt.Errorf("%w: %w", errDNSLookup, err)
+	default:
+		return err
+	}
+}
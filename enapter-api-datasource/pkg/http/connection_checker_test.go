@@ -0,0 +1,46 @@
+package http_test
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Enapter/grafana-plugins/pkg/http"
+)
+
+func TestConnectionHealthCheck(t *testing.T) {
+	server := httptest.NewTLSServer(nethttp.NotFoundHandler())
+	defer server.Close()
+
+	check := func(t *testing.T, transport nethttp.RoundTripper) error {
+		t.Helper()
+		adapter, err := http.NewEnapterAPIv3Adapter(http.EnapterAPIv3AdapterParams{
+			Logger:    log.DefaultLogger,
+			APIURL:    server.URL,
+			Transport: transport,
+		})
+		require.NoError(t, err)
+		defer adapter.Close()
+
+		for _, c := range adapter.HealthChecks() {
+			if c.Name == "connection" {
+				return c.Check(context.Background())
+			}
+		}
+		require.FailNow(t, "no connection check")
+		return nil
+	}
+
+	t.Run("should report TLS errors", func(t *testing.T) {
+		err := check(t, nethttp.DefaultTransport)
+		require.ErrorContains(t, err, "TLS handshake failed")
+	})
+
+	t.Run("should ignore response status", func(t *testing.T) {
+		require.NoError(t, check(t, server.Client().Transport))
+	})
+}
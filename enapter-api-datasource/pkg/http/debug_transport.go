@@ -0,0 +1,220 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+)
+
+const DefaultDebugMaxBodySize = 2048
+
+const redacted = "[REDACTED]"
+
+//nolint:gochecknoglobals // constant
+var redactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Enapter-Auth-Token",
+	"X-Enapter-Auth-User",
+}
+
+// defaultRedactedFieldParts redact JSON fields whose names contain any of
+// them, e.g. "password" and "wifi_password".
+//
+//nolint:gochecknoglobals // constant
+var defaultRedactedFieldParts = []string{
+	"password",
+	"secret",
+	"token",
+	"credential",
+}
+
+type DebugTransportParams struct {
+	Logger log.Logger
+	// MaxBodySize bounds the logged part of bodies. Zero means
+	// DefaultDebugMaxBodySize.
+	MaxBodySize int
+	// RedactedFields are names of JSON fields, e.g. sensitive command
+	// arguments, redacted in addition to the default ones.
+	RedactedFields []string
+}
+
+// NewDebugTransport returns a transport logging requests and responses at
+// the debug level. Credentials, user IDs and sensitive JSON fields are
+// redacted.
+func NewDebugTransport(next http.RoundTripper, p DebugTransportParams) http.RoundTripper {
+	if p.MaxBodySize <= 0 {
+		p.MaxBodySize = DefaultDebugMaxBodySize
+	}
+	redactedFields := make(map[string]struct{}, len(p.RedactedFields))
+	for _, f := range p.RedactedFields {
+		redactedFields[strings.ToLower(f)] = struct{}{}
+	}
+	return &debugTransport{
+		next:           next,
+		logger:         p.Logger,
+		maxBodySize:    p.MaxBodySize,
+		redactedFields: redactedFields,
+	}
+}
+
+type debugTransport struct {
+	next           http.RoundTripper
+	logger         log.Logger
+	maxBodySize    int
+	redactedFields map[string]struct{}
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read body: %w", err)
+		}
+		// RoundTrip must not modify the request.
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	t.logger.Debug("sending Enapter API request",
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		"headers", t.formatHeaders(req.Header),
+		"body", t.formatBody(req.Header, reqBody, true))
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.logger.Debug("Enapter API request failed",
+			"method", req.Method,
+			"url", req.URL.Redacted(),
+			"duration", time.Since(start),
+			"error", err)
+		return nil, err
+	}
+
+	// Only the logged part of the body is read in advance, the rest is
+	// left to the caller.
+	prefix, err := io.ReadAll(io.LimitReader(resp.Body, int64(t.maxBodySize)+1))
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("read body: %w", err)
+	}
+	complete := len(prefix) <= t.maxBodySize
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), resp.Body), resp.Body}
+
+	t.logger.Debug("received Enapter API response",
+		"method", req.Method,
+		"url", req.URL.Redacted(),
+		"status", resp.StatusCode,
+		"duration", time.Since(start),
+		"headers", t.formatHeaders(resp.Header),
+		"body", t.formatBody(resp.Header, prefix, complete))
+
+	return resp, nil
+}
+
+func (t *debugTransport) formatHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		out[name] = strings.Join(values, ", ")
+	}
+	for _, name := range redactedHeaders {
+		if _, ok := out[name]; ok {
+			out[name] = redacted
+		}
+	}
+	return out
+}
+
+// formatBody redacts JSON bodies. An incomplete JSON body cannot be
+// redacted, so it is omitted.
+func (t *debugTransport) formatBody(h http.Header, body []byte, complete bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+
+	if looksLikeJSON(h, body) {
+		if !complete {
+			return fmt.Sprintf("[JSON body larger than %d bytes omitted]", t.maxBodySize)
+		}
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return "[invalid JSON body omitted]"
+		}
+		redactedBody, err := json.Marshal(t.redact(v))
+		if err != nil {
+			return "[invalid JSON body omitted]"
+		}
+		body = redactedBody
+	}
+
+	if len(body) > t.maxBodySize {
+		return string(body[:t.maxBodySize]) + "...(truncated)"
+	}
+	return string(body)
+}
+
+func looksLikeJSON(h http.Header, body []byte) bool {
+	if strings.Contains(h.Get("Content-Type"), "json") {
+		return true
+	}
+	body = bytes.TrimSpace(body)
+	return len(body) != 0 && (body[0] == '{' || body[0] == '[')
+}
+
+func (t *debugTransport) redact(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, fv := range v {
+			if t.sensitiveField(k) {
+				v[k] = redacted
+				continue
+			}
+			v[k] = t.redact(fv)
+		}
+	case []any:
+		for i, ev := range v {
+			v[i] = t.redact(ev)
+		}
+	}
+	return v
+}
+
+func (t *debugTransport) sensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	if _, ok := t.redactedFields[name]; ok {
+		return true
+	}
+	for _, part := range defaultRedactedFieldParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *debugTransport) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if c, ok := t.next.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+}
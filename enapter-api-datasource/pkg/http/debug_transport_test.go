@@ -0,0 +1,104 @@
+package http_test
+
+import (
+	"fmt"
+	"io"
+	nethttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Enapter/grafana-plugins/pkg/http"
+)
+
+func TestDebugTransport(t *testing.T) {
+	const respBody = `{"device_id":"dev","access_token":"resp-secret"}`
+	server := httptest.NewServer(nethttp.HandlerFunc(
+		func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, respBody)
+		}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := &nethttp.Client{
+		Transport: http.NewDebugTransport(nethttp.DefaultTransport,
+			http.DebugTransportParams{
+				Logger:         logger,
+				RedactedFields: []string{"PIN"},
+			}),
+	}
+
+	req, err := nethttp.NewRequest(nethttp.MethodPost, server.URL,
+		strings.NewReader(`{"arguments":{"pin":"1234","wifi_password":"p","mode":"eco"}}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Enapter-Auth-Token", "api-token")
+	req.Header.Set("X-Enapter-Auth-User", "user-id")
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, respBody, string(body), "body must be left intact")
+
+	logs := logger.String()
+	for _, secret := range []string{"api-token", "user-id", "1234", `"p"`, "resp-secret"} {
+		require.NotContains(t, logs, secret)
+	}
+	require.Contains(t, logs, `"mode":"eco"`)
+	require.Contains(t, logs, `"device_id":"dev"`)
+}
+
+func TestDebugTransportTruncatesBodies(t *testing.T) {
+	server := httptest.NewServer(nethttp.HandlerFunc(
+		func(w nethttp.ResponseWriter, _ *nethttp.Request) {
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = io.WriteString(w, "ts,value\n1,2\n3,4\n")
+		}))
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := &nethttp.Client{
+		Transport: http.NewDebugTransport(nethttp.DefaultTransport,
+			http.DebugTransportParams{Logger: logger, MaxBodySize: 8}),
+	}
+
+	resp, err := client.Get(server.URL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	require.Equal(t, "ts,value\n1,2\n3,4\n", string(body))
+
+	require.Contains(t, logger.String(), "ts,value...(truncated)")
+}
+
+type recordingLogger struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (l *recordingLogger) record(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(&l.buf, append([]any{msg}, args...)...)
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) { l.record(msg, args...) }
+func (l *recordingLogger) Info(msg string, args ...any)  { l.record(msg, args...) }
+func (l *recordingLogger) Warn(msg string, args ...any)  { l.record(msg, args...) }
+func (l *recordingLogger) Error(msg string, args ...any) { l.record(msg, args...) }
+func (l *recordingLogger) With(_ ...any) log.Logger      { return l }
+func (l *recordingLogger) Level() log.Level              { return log.Debug }
+
+func (l *recordingLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buf.String()
+}
@@ -0,0 +1,395 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi"
+	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi/v3/devicesapi"
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
+)
+
+const (
+	defaultAPIVersionReprobeInterval = 30 * time.Second
+	defaultAPIVersionDetectTimeout   = 10 * time.Second
+)
+
+type EnapterAPIAutoAdapterParams struct {
+	Logger   log.Logger
+	APIURL   string
+	APIToken string
+	Retry    enapterapi.RetryPolicy
+	// RateLimiter is optional. It is shared by the adapters of every
+	// version.
+	RateLimiter *RateLimiter
+	// Transport is optional and is shared by every client.
+	Transport http.RoundTripper
+	// Timeout backs up the deadlines of the request contexts, so it should
+	// not be less than any of them. Zero means the clients' default.
+	Timeout time.Duration
+	Metrics *metrics.DataSource
+}
+
+// EnapterAPIAutoAdapter detects the version of Enapter API and delegates
+// to the adapter of that version. The version is detected again once a
+// request hits a missing endpoint, and no more often than every
+// reprobeInterval while it is unknown.
+type EnapterAPIAutoAdapter struct {
+	logger          log.Logger
+	v1              *EnapterAPIv1Adapter
+	v3              *EnapterAPIv3Adapter
+	reprobeInterval time.Duration
+	detectTimeout   time.Duration
+	now             func() time.Time
+
+	// closeCtx is done once the adapter is closed, which stops the
+	// detection in the background.
+	closeCtx context.Context
+	cancel   context.CancelFunc
+
+	mu       sync.Mutex
+	current  enapterAPIVersionAdapter
+	version  string
+	probes   []apiVersionProbe
+	probedAt time.Time
+	probeErr error
+	// detecting is closed once the detection in flight, if any, has
+	// published its result.
+	detecting chan struct{}
+}
+
+type enapterAPIVersionAdapter interface {
+	core.EnapterAPIPort
+	Close()
+}
+
+type apiVersionProbe struct {
+	version string
+	err     error
+}
+
+func NewEnapterAPIAutoAdapter(p EnapterAPIAutoAdapterParams) (*EnapterAPIAutoAdapter, error) {
+	v1, err := NewEnapterAPIv1Adapter(EnapterAPIv1AdapterParams{
+		Logger:      p.Logger,
+		APIURL:      p.APIURL,
+		APIToken:    p.APIToken,
+		Retry:       p.Retry,
+		RateLimiter: p.RateLimiter,
+		Transport:   p.Transport,
+		Timeout:     p.Timeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("v1: %w", err)
+	}
+	v3, err := NewEnapterAPIv3Adapter(EnapterAPIv3AdapterParams{
+		Logger:      p.Logger,
+		APIURL:      p.APIURL,
+		APIToken:    p.APIToken,
+		Retry:       p.Retry,
+		RateLimiter: p.RateLimiter,
+		Transport:   p.Transport,
+		Timeout:     p.Timeout,
+		Metrics:     p.Metrics,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("v3: %w", err)
+	}
+	closeCtx, cancel := context.WithCancel(context.Background())
+	return &EnapterAPIAutoAdapter{
+		logger:          p.Logger.With("logger", "enapter_api_auto_adapter"),
+		v1:              v1,
+		v3:              v3,
+		reprobeInterval: defaultAPIVersionReprobeInterval,
+		detectTimeout:   defaultAPIVersionDetectTimeout,
+		now:             time.Now,
+		closeCtx:        closeCtx,
+		cancel:          cancel,
+		probeErr:        errAPIVersionNotDetected,
+	}, nil
+}
+
+func (a *EnapterAPIAutoAdapter) Close() {
+	a.cancel()
+	a.v1.Close()
+	a.v3.Close()
+}
+
+// Detect probes the API for every supported version, the latest first.
+func (a *EnapterAPIAutoAdapter) Detect(ctx context.Context) error {
+	_, err := a.detect(ctx)
+	return err
+}
+
+// DetectInBackground starts the detection without waiting for it, so that
+// an unreachable API does not delay the caller. Requests made meanwhile wait
+// for the detection to complete.
+func (a *EnapterAPIAutoAdapter) DetectInBackground() {
+	go func() {
+		ctx, cancel := context.WithTimeout(a.closeCtx, a.detectTimeout)
+		defer cancel()
+		if err := a.Detect(ctx); err != nil {
+			a.logger.Warn("failed to detect Enapter API version", "error", err)
+		}
+	}()
+}
+
+// Version returns the detected version or an empty string.
+func (a *EnapterAPIAutoAdapter) Version() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.version
+}
+
+// detect joins the detection in flight or starts one. The API is probed
+// without the lock held, so that the waiters can give up with their contexts
+// and the rest of the adapter stays usable meanwhile.
+func (a *EnapterAPIAutoAdapter) detect(ctx context.Context) (core.EnapterAPIPort, error) {
+	a.mu.Lock()
+	for a.detecting != nil {
+		done := a.detecting
+		a.mu.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		a.mu.Lock()
+		if a.current != nil {
+			defer a.mu.Unlock()
+			return a.current, nil
+		}
+		// Unless the detection has been cut short by its caller, its
+		// result stands.
+		if !a.probedAt.IsZero() {
+			defer a.mu.Unlock()
+			return nil, a.probeErr
+		}
+	}
+	done := make(chan struct{})
+	a.detecting = done
+	a.mu.Unlock()
+
+	probedAt := a.now()
+	version, adapter, probes := a.probe(ctx)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	defer close(done)
+	a.detecting = nil
+	a.probes, a.probedAt = probes, probedAt
+	if adapter != nil {
+		a.useLocked(version, adapter, a.version)
+		return adapter, nil
+	}
+	a.current, a.version = nil, ""
+	// The caller has given up, which tells nothing about the API.
+	if ctx.Err() != nil {
+		a.probedAt = time.Time{}
+	}
+	a.probeErr = fmt.Errorf("%w: %s", errAPIVersionNotDetected, a.describeProbesLocked())
+	return nil, a.probeErr
+}
+
+func (a *EnapterAPIAutoAdapter) probe(
+	ctx context.Context,
+) (string, enapterAPIVersionAdapter, []apiVersionProbe) {
+	// A rejected token proves that the endpoint exists.
+	v3Err := a.v3.devicesAPIClient.Ready(ctx)
+	probes := []apiVersionProbe{{version: "v3", err: v3Err}}
+	if v3Err == nil || errors.Is(v3Err, devicesapi.ErrAuthFailed) {
+		return "v3", a.v3, probes
+	}
+
+	v1Err := a.v1.assetsAPIClient.Ready(ctx)
+	probes = append(probes, apiVersionProbe{version: "v1", err: v1Err})
+	if v1Err == nil || isAuthError(v1Err) {
+		return "v1", a.v1, probes
+	}
+	return "", nil, probes
+}
+
+func (a *EnapterAPIAutoAdapter) useLocked(
+	version string, adapter enapterAPIVersionAdapter, prevVersion string,
+) {
+	a.current, a.version, a.probeErr = adapter, version, nil
+	if version != prevVersion {
+		a.logger.Info("detected Enapter API version",
+			"version", version,
+			"previous_version", prevVersion)
+	}
+}
+
+func (a *EnapterAPIAutoAdapter) describeProbesLocked() string {
+	results := make([]string, len(a.probes))
+	for i, p := range a.probes {
+		result := "ok"
+		if p.err != nil {
+			result = p.err.Error()
+		}
+		results[i] = fmt.Sprintf("%s: %s", p.version, result)
+	}
+	return strings.Join(results, "; ")
+}
+
+func (a *EnapterAPIAutoAdapter) adapter(ctx context.Context) (core.EnapterAPIPort, error) {
+	a.mu.Lock()
+	current, probedAt, probeErr := a.current, a.probedAt, a.probeErr
+	a.mu.Unlock()
+
+	if current != nil {
+		return current, nil
+	}
+	if !probedAt.IsZero() && a.now().Sub(probedAt) < a.reprobeInterval {
+		return nil, probeErr
+	}
+	return a.detect(ctx)
+}
+
+// forget makes the next request detect the version again, unless it has
+// already been detected again since the adapter was used.
+func (a *EnapterAPIAutoAdapter) forget(adapter core.EnapterAPIPort, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.current == nil || a.current != adapter {
+		return
+	}
+	a.logger.Warn("Enapter API endpoint is missing, version will be detected again",
+		"version", a.version,
+		"error", err)
+	a.current, a.version = nil, ""
+	a.probedAt = time.Time{}
+	a.probeErr = errAPIVersionNotDetected
+}
+
+func autoAdapterCall[Req, Resp any](
+	ctx context.Context, a *EnapterAPIAutoAdapter, req Req,
+	fn func(core.EnapterAPIPort, context.Context, Req) (Resp, error),
+) (Resp, error) {
+	adapter, err := a.adapter(ctx)
+	if err != nil {
+		var zero Resp
+		return zero, err
+	}
+	resp, err := fn(adapter, ctx, req)
+	if err != nil && isEndpointMissing(err) {
+		a.forget(adapter, err)
+	}
+	return resp, err
+}
+
+// isEndpointMissing tells a missing endpoint apart from a missing resource,
+// which the API reports with errors of its own.
+func isEndpointMissing(err error) bool {
+	if errors.Is(err, devicesapi.ErrEndpointMissing) {
+		return true
+	}
+	var (
+		coder    interface{ HTTPStatusCode() int }
+		multiErr *enapterapi.MultiError
+		apiErr   core.EnapterAPIError
+	)
+	if !errors.As(err, &coder) || errors.As(err, &multiErr) || errors.As(err, &apiErr) {
+		return false
+	}
+	code := coder.HTTPStatusCode()
+	return code == http.StatusNotFound || code == http.StatusMethodNotAllowed
+}
+
+func isAuthError(err error) bool {
+	var multiErr *enapterapi.MultiError
+	if !errors.As(err, &multiErr) {
+		return false
+	}
+	return multiErr.StatusCode == http.StatusUnauthorized ||
+		multiErr.StatusCode == http.StatusForbidden
+}
+
+// HealthChecks starts with the detection of the version, which is repeated
+// if the version is unknown, followed by the checks of the detected
+// version.
+func (a *EnapterAPIAutoAdapter) HealthChecks() []core.HealthCheck {
+	checks := []core.HealthCheck{{
+		Name:  "api_version",
+		Check: a.checkAPIVersion,
+		Info:  a.describeAPIVersion,
+	}}
+
+	a.mu.Lock()
+	current := a.current
+	a.mu.Unlock()
+	if current != nil {
+		checks = append(checks, current.HealthChecks()...)
+	}
+	return checks
+}
+
+func (a *EnapterAPIAutoAdapter) checkAPIVersion(ctx context.Context) error {
+	a.mu.Lock()
+	current := a.current
+	a.mu.Unlock()
+	if current != nil {
+		return nil
+	}
+	_, err := a.detect(ctx)
+	return err
+}
+
+func (a *EnapterAPIAutoAdapter) describeAPIVersion() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.probes) == 0 {
+		return a.version
+	}
+	return fmt.Sprintf("%s (%s)", a.version, a.describeProbesLocked())
+}
+
+func (a *EnapterAPIAutoAdapter) QueryTimeseries(
+	ctx context.Context, req *core.QueryTimeseriesRequest,
+) (*core.QueryTimeseriesResponse, error) {
+	return autoAdapterCall(ctx, a, req, core.EnapterAPIPort.QueryTimeseries)
+}
+
+func (a *EnapterAPIAutoAdapter) ExecuteCommand(
+	ctx context.Context, req *core.ExecuteCommandRequest,
+) (*core.ExecuteCommandResponse, error) {
+	return autoAdapterCall(ctx, a, req, core.EnapterAPIPort.ExecuteCommand)
+}
+
+func (a *EnapterAPIAutoAdapter) GetDeviceManifest(
+	ctx context.Context, req *core.GetDeviceManifestRequest,
+) (*core.GetDeviceManifestResponse, error) {
+	return autoAdapterCall(ctx, a, req, core.EnapterAPIPort.GetDeviceManifest)
+}
+
+func (a *EnapterAPIAutoAdapter) ListDevices(
+	ctx context.Context, req *core.ListDevicesRequest,
+) (*core.ListDevicesResponse, error) {
+	return autoAdapterCall(ctx, a, req, core.EnapterAPIPort.ListDevices)
+}
+
+func (a *EnapterAPIAutoAdapter) StartCommandExecution(
+	ctx context.Context, req *core.ExecuteCommandRequest,
+) (*core.StartCommandExecutionResponse, error) {
+	return autoAdapterCall(ctx, a, req, core.EnapterAPIPort.StartCommandExecution)
+}
+
+func (a *EnapterAPIAutoAdapter) GetCommandExecution(
+	ctx context.Context, req *core.GetCommandExecutionRequest,
+) (*core.GetCommandExecutionResponse, error) {
+	return autoAdapterCall(ctx, a, req, core.EnapterAPIPort.GetCommandExecution)
+}
+
+func (a *EnapterAPIAutoAdapter) ResolveDeviceID(
+	ctx context.Context, req *core.ResolveDeviceIDRequest,
+) (*core.ResolveDeviceIDResponse, error) {
+	return autoAdapterCall(ctx, a, req, core.EnapterAPIPort.ResolveDeviceID)
+}
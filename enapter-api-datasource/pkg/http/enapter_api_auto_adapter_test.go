@@ -0,0 +1,200 @@
+package http_test
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/http"
+)
+
+func TestEnapterAPIAutoAdapter(t *testing.T) {
+	var version atomic.Value
+	mux := nethttp.NewServeMux()
+	mux.HandleFunc("GET /v3/devices", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if version.Load() != "v3" {
+			nethttp.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"devices":[{"id":"dev"}]}`))
+	})
+	mux.HandleFunc("GET /assets/v1/devices/{id}", func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		if version.Load() != "v1" {
+			nethttp.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(nethttp.StatusNotFound)
+		_, _ = w.Write([]byte(`{"errors":[{"code":"not_found","message":"Not found."}]}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	newAdapter := func(t *testing.T) *http.EnapterAPIAutoAdapter {
+		t.Helper()
+		a, err := http.NewEnapterAPIAutoAdapter(http.EnapterAPIAutoAdapterParams{
+			Logger: log.DefaultLogger,
+			APIURL: server.URL,
+		})
+		require.NoError(t, err)
+		t.Cleanup(a.Close)
+		return a
+	}
+	ctx := context.Background()
+
+	t.Run("should detect v3", func(t *testing.T) {
+		version.Store("v3")
+		a := newAdapter(t)
+		require.NoError(t, a.Detect(ctx))
+		require.Equal(t, "v3", a.Version())
+
+		resp, err := a.ListDevices(ctx, &core.ListDevicesRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.Devices, 1)
+	})
+
+	t.Run("should detect v1", func(t *testing.T) {
+		version.Store("v1")
+		a := newAdapter(t)
+		require.NoError(t, a.Detect(ctx))
+		require.Equal(t, "v1", a.Version())
+
+		checks := a.HealthChecks()
+		require.Equal(t, "api_version", checks[0].Name)
+		require.NoError(t, checks[0].Check(ctx))
+		require.Contains(t, checks[0].Info(), "v1 (v3: devices API endpoint missing")
+	})
+
+	t.Run("should detect version again once endpoint is missing", func(t *testing.T) {
+		version.Store("v3")
+		a := newAdapter(t)
+		require.NoError(t, a.Detect(ctx))
+
+		version.Store("none")
+		_, err := a.ListDevices(ctx, &core.ListDevicesRequest{})
+		require.Error(t, err)
+
+		version.Store("v3")
+		_, err = a.ListDevices(ctx, &core.ListDevicesRequest{})
+		require.NoError(t, err)
+		require.Equal(t, "v3", a.Version())
+	})
+
+	t.Run("should fail if no version is detected", func(t *testing.T) {
+		version.Store("none")
+		a := newAdapter(t)
+		require.ErrorContains(t, a.Detect(ctx), "Enapter API version not detected")
+		require.Empty(t, a.Version())
+
+		// The API is not probed again right away.
+		version.Store("v3")
+		_, err := a.ListDevices(ctx, &core.ListDevicesRequest{})
+		require.ErrorContains(t, err, "Enapter API version not detected")
+
+		// The health check does probe it.
+		checks := a.HealthChecks()
+		require.Len(t, checks, 1)
+		require.NoError(t, checks[0].Check(ctx))
+		require.Equal(t, "v3", a.Version())
+	})
+	t.Run("should detect version in background", func(t *testing.T) {
+		version.Store("v3")
+		a := newAdapter(t)
+		a.DetectInBackground()
+
+		resp, err := a.ListDevices(ctx, &core.ListDevicesRequest{})
+		require.NoError(t, err)
+		require.Len(t, resp.Devices, 1)
+		require.Equal(t, "v3", a.Version())
+	})
+}
+
+func TestEnapterAPIAutoAdapterStopsDetectionOnClose(t *testing.T) {
+	started := make(chan struct{}, 1)
+	canceled := make(chan struct{}, 1)
+	server := httptest.NewServer(nethttp.HandlerFunc(
+		func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-r.Context().Done()
+			select {
+			case canceled <- struct{}{}:
+			default:
+			}
+		}))
+	defer server.Close()
+
+	a, err := http.NewEnapterAPIAutoAdapter(http.EnapterAPIAutoAdapterParams{
+		Logger: log.DefaultLogger,
+		APIURL: server.URL,
+	})
+	require.NoError(t, err)
+
+	// The unreachable API does not block the caller.
+	a.DetectInBackground()
+	<-started
+	a.Close()
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "detection has not been stopped")
+	}
+}
+
+func TestEnapterAPIAutoAdapterProbesWithoutLock(t *testing.T) {
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	var probes atomic.Int32
+	server := httptest.NewServer(nethttp.HandlerFunc(
+		func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			probes.Add(1)
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"devices":[]}`))
+		}))
+	defer server.Close()
+
+	a, err := http.NewEnapterAPIAutoAdapter(http.EnapterAPIAutoAdapterParams{
+		Logger: log.DefaultLogger,
+		APIURL: server.URL,
+	})
+	require.NoError(t, err)
+	defer a.Close()
+
+	a.DetectInBackground()
+	<-started
+
+	// The adapter stays usable while the API is being probed, and the
+	// requests waiting for the detection give up with their contexts.
+	require.Empty(t, a.Version())
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = a.ListDevices(ctx, &core.ListDevicesRequest{})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The requests join the detection in flight.
+	done := make(chan error)
+	go func() {
+		_, err := a.ListDevices(context.Background(), &core.ListDevicesRequest{})
+		done <- err
+	}()
+	close(release)
+	require.NoError(t, <-done)
+	require.Equal(t, "v3", a.Version())
+	require.Equal(t, int32(2), probes.Load())
+}
@@ -1,11 +1,13 @@
 package http
 
 import (
+	"cmp"
 	"context"
 	"errors"
-	"fmt"
+	"net/http"
+	"time"
 
-	"github.com/hashicorp/go-hclog"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 
 	"github.com/Enapter/grafana-plugins/pkg/core"
 	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi"
@@ -15,39 +17,69 @@ import (
 )
 
 type EnapterAPIv1AdapterParams struct {
-	Logger   hclog.Logger
+	Logger   log.Logger
 	APIURL   string
 	APIToken string
+	Retry    enapterapi.RetryPolicy
+	// RateLimiter is optional. Every attempt of a retried request goes
+	// through it.
+	RateLimiter *RateLimiter
+	// Transport is optional and is shared by every client.
+	Transport http.RoundTripper
+	// Timeout backs up the deadlines of the request contexts, so it should
+	// not be less than any of them. Zero means the clients' default.
+	Timeout time.Duration
 }
 
 type EnapterAPIv1Adapter struct {
-	logger             hclog.Logger
+	logger             log.Logger
 	telemetryAPIClient *telemetryapi.Client
 	commandsAPIClient  *commandsapi.Client
 	assetsAPIClient    *assetsapi.Client
+	connectionChecker  *connectionChecker
 }
 
 func NewEnapterAPIv1Adapter(p EnapterAPIv1AdapterParams) (*EnapterAPIv1Adapter, error) {
 	if p.APIURL == "" {
 		return nil, errEnapterAPIURLEmptyOrMissing
 	}
+	if p.Transport == nil {
+		p.Transport = http.DefaultTransport
+	}
+	// Every attempt is traced on its own.
+	transport := enapterapi.NewRetryTransport(
+		newRateLimitTransport(
+			newOAuthTransport(NewTracingTransport(p.Transport)), p.RateLimiter),
+		p.Retry)
 	telemetryAPIClient := telemetryapi.NewClient(telemetryapi.ClientParams{
+		HTTPClient: &http.Client{
+			Timeout:   cmp.Or(p.Timeout, telemetryapi.DefaultTimeout),
+			Transport: transport,
+		},
 		BaseURL: p.APIURL + "/telemetry",
 		Token:   p.APIToken,
 	})
+	// The transport never retries command executions, because they are
+	// not idempotent.
 	commandsAPIClient := commandsapi.NewClient(commandsapi.ClientParams{
-		APIURL: p.APIURL,
-		Token:  p.APIToken,
+		APIURL:    p.APIURL,
+		Token:     p.APIToken,
+		Timeout:   p.Timeout,
+		Transport: transport,
 	})
 	assetsAPIClient := assetsapi.NewClient(assetsapi.ClientParams{
-		APIURL: p.APIURL,
-		Token:  p.APIToken,
+		APIURL:    p.APIURL,
+		Token:     p.APIToken,
+		Timeout:   p.Timeout,
+		Transport: transport,
 	})
 	return &EnapterAPIv1Adapter{
-		logger:             p.Logger.Named("enapter_api_v1_adapter"),
+		logger:             p.Logger.With("logger", "enapter_api_v1_adapter"),
 		telemetryAPIClient: telemetryAPIClient,
 		commandsAPIClient:  commandsAPIClient,
 		assetsAPIClient:    assetsAPIClient,
+		connectionChecker: newConnectionChecker(
+			p.Transport, p.APIURL, cmp.Or(p.Timeout, telemetryapi.DefaultTimeout)),
 	}, nil
 }
 
@@ -55,14 +87,12 @@ func (a *EnapterAPIv1Adapter) Close() {
 	a.telemetryAPIClient.Close()
 }
 
-func (a *EnapterAPIv1Adapter) Ready(ctx context.Context) error {
-	if err := a.telemetryAPIClient.Ready(ctx); err != nil {
-		return fmt.Errorf("telemetry api: %w", err)
+func (a *EnapterAPIv1Adapter) HealthChecks() []core.HealthCheck {
+	return []core.HealthCheck{
+		{Name: "connection", Check: a.connectionChecker.check},
+		{Name: "telemetry_api", Check: a.telemetryAPIClient.Ready},
+		{Name: "assets_api", Check: a.assetsAPIClient.Ready},
 	}
-	if err := a.assetsAPIClient.Ready(ctx); err != nil {
-		return fmt.Errorf("assets api: %w", err)
-	}
-	return nil
 }
 
 func (a *EnapterAPIv1Adapter) QueryTimeseries(
@@ -78,7 +108,7 @@ func (a *EnapterAPIv1Adapter) QueryTimeseries(
 			return nil, core.ErrTimeseriesEmpty
 		}
 		if multiErr := new(enapterapi.MultiError); errors.As(err, &multiErr) {
-			return nil, a.convertMultiError(multiErr)
+			return nil, convertMultiError(multiErr)
 		}
 		return nil, err
 	}
@@ -111,6 +141,9 @@ func (a *EnapterAPIv1Adapter) ExecuteCommand(
 		},
 	})
 	if err != nil {
+		if multiErr := new(enapterapi.MultiError); errors.As(err, &multiErr) {
+			return nil, convertMultiError(multiErr)
+		}
 		return nil, err
 	}
 	return &core.ExecuteCommandResponse{
@@ -122,6 +155,10 @@ func (a *EnapterAPIv1Adapter) ExecuteCommand(
 func (a *EnapterAPIv1Adapter) GetDeviceManifest(
 	ctx context.Context, req *core.GetDeviceManifestRequest,
 ) (*core.GetDeviceManifestResponse, error) {
+	if req.DeviceID == "" && req.HardwareID != "" {
+		// Assets API v1 looks devices up by device ID only.
+		return nil, core.ErrHardwareIDNotSupported
+	}
 	device, err := a.assetsAPIClient.DeviceByID(ctx, assetsapi.DeviceByIDParams{
 		User:     req.User,
 		DeviceID: req.DeviceID,
@@ -130,6 +167,9 @@ func (a *EnapterAPIv1Adapter) GetDeviceManifest(
 		},
 	})
 	if err != nil {
+		if multiErr := new(enapterapi.MultiError); errors.As(err, &multiErr) {
+			return nil, convertMultiError(multiErr)
+		}
 		return nil, err
 	}
 	return &core.GetDeviceManifestResponse{
@@ -137,26 +177,32 @@ func (a *EnapterAPIv1Adapter) GetDeviceManifest(
 	}, nil
 }
 
-func (a *EnapterAPIv1Adapter) convertMultiError(
-	multiErr *enapterapi.MultiError,
-) error {
-	switch len(multiErr.Errors) {
-	case 0:
-		// should never happen, return error as is
-		return multiErr
-	case 1:
-		return core.EnapterAPIError{
-			Code:    multiErr.Errors[0].Code,
-			Message: multiErr.Errors[0].Message,
-			Details: multiErr.Errors[0].Details,
-		}
-	default:
-		a.logger.Warn("multi error contains multiple errors, " +
-			"but this is not supported yet; will return only the first error")
-		return core.EnapterAPIError{
-			Code:    multiErr.Errors[0].Code,
-			Message: multiErr.Errors[0].Message,
-			Details: multiErr.Errors[0].Details,
-		}
+func (a *EnapterAPIv1Adapter) ListDevices(
+	context.Context, *core.ListDevicesRequest,
+) (*core.ListDevicesResponse, error) {
+	// Assets API v1 does not expose sites and blueprints of devices.
+	return nil, core.ErrDeviceSelectorNotSupported
+}
+
+func (a *EnapterAPIv1Adapter) StartCommandExecution(
+	context.Context, *core.ExecuteCommandRequest,
+) (*core.StartCommandExecutionResponse, error) {
+	// Commands API v1 executes commands synchronously only.
+	return nil, core.ErrAsyncCommandsNotSupported
+}
+
+func (a *EnapterAPIv1Adapter) GetCommandExecution(
+	context.Context, *core.GetCommandExecutionRequest,
+) (*core.GetCommandExecutionResponse, error) {
+	return nil, core.ErrAsyncCommandsNotSupported
+}
+
+func (a *EnapterAPIv1Adapter) ResolveDeviceID(
+	_ context.Context, req *core.ResolveDeviceIDRequest,
+) (*core.ResolveDeviceIDResponse, error) {
+	if req.DeviceID == "" && req.HardwareID != "" {
+		// Commands API v1 looks devices up by hardware ID on its own.
+		return nil, core.ErrHardwareIDNotSupported
 	}
+	return &core.ResolveDeviceIDResponse{DeviceID: req.DeviceID}, nil
 }
@@ -1,62 +1,96 @@
 package http
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
-	"github.com/hashicorp/go-hclog"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
 
 	"github.com/Enapter/grafana-plugins/pkg/core"
 	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi"
 	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi/v3/devicesapi"
 	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi/v3/telemetryapi"
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
 )
 
 type EnapterAPIv3AdapterParams struct {
-	Logger   hclog.Logger
+	Logger   log.Logger
 	APIURL   string
 	APIToken string
+	Retry    enapterapi.RetryPolicy
+	// RateLimiter is optional. Every attempt of a retried request goes
+	// through it.
+	RateLimiter *RateLimiter
+	// Transport is optional and is shared by every client.
+	Transport http.RoundTripper
+	// Timeout backs up the deadlines of the request contexts, so it should
+	// not be less than any of them. Zero means the clients' default.
+	Timeout time.Duration
+	Metrics *metrics.DataSource
 }
 
 type EnapterAPIv3Adapter struct {
-	logger             hclog.Logger
+	logger             log.Logger
 	telemetryAPIClient *telemetryapi.Client
 	devicesAPIClient   *devicesapi.Client
+	hardwareIDResolver *hardwareIDResolver
+	connectionChecker  *connectionChecker
 }
 
 func NewEnapterAPIv3Adapter(p EnapterAPIv3AdapterParams) (*EnapterAPIv3Adapter, error) {
 	if p.APIURL == "" {
 		return nil, errEnapterAPIURLEmptyOrMissing
 	}
+	if p.Transport == nil {
+		p.Transport = http.DefaultTransport
+	}
+	// Every attempt is traced on its own.
+	transport := enapterapi.NewRetryTransport(
+		newRateLimitTransport(
+			newOAuthTransport(NewTracingTransport(p.Transport)), p.RateLimiter),
+		p.Retry)
 	telemetryAPIClient := telemetryapi.NewClient(telemetryapi.ClientParams{
+		HTTPClient: &http.Client{
+			Timeout:   cmp.Or(p.Timeout, telemetryapi.DefaultTimeout),
+			Transport: transport,
+		},
 		BaseURL: p.APIURL + "/v3/telemetry",
 		Token:   p.APIToken,
 	})
 	devicesAPIClient := devicesapi.NewClient(devicesapi.ClientParams{
+		HTTPClient: &http.Client{
+			Timeout:   cmp.Or(p.Timeout, devicesapi.DefaultTimeout),
+			Transport: transport,
+		},
 		BaseURL: p.APIURL + "/v3/devices",
 		Token:   p.APIToken,
 	})
-	return &EnapterAPIv3Adapter{
-		logger:             p.Logger.Named("enapter_api_v3_adapter"),
+	a := &EnapterAPIv3Adapter{
+		logger:             p.Logger.With("logger", "enapter_api_v3_adapter"),
 		telemetryAPIClient: telemetryAPIClient,
 		devicesAPIClient:   devicesAPIClient,
-	}, nil
+		connectionChecker: newConnectionChecker(
+			p.Transport, p.APIURL, cmp.Or(p.Timeout, telemetryapi.DefaultTimeout)),
+	}
+	a.hardwareIDResolver = newHardwareIDResolver(a.listUserDevices, p.Metrics)
+	return a, nil
 }
 
 func (a *EnapterAPIv3Adapter) Close() {
 	a.telemetryAPIClient.Close()
 }
 
-func (a *EnapterAPIv3Adapter) Ready(ctx context.Context) error {
-	if err := a.telemetryAPIClient.Ready(ctx); err != nil {
-		return fmt.Errorf("telemetry api: %w", err)
-	}
-	if err := a.devicesAPIClient.Ready(ctx); err != nil {
-		return fmt.Errorf("devices api: %w", err)
+func (a *EnapterAPIv3Adapter) HealthChecks() []core.HealthCheck {
+	return []core.HealthCheck{
+		{Name: "connection", Check: a.connectionChecker.check},
+		{Name: "telemetry_api", Check: a.telemetryAPIClient.Ready},
+		{Name: "devices_api", Check: a.devicesAPIClient.Ready},
 	}
-	return nil
 }
 
 func (a *EnapterAPIv3Adapter) QueryTimeseries(
@@ -72,7 +106,7 @@ func (a *EnapterAPIv3Adapter) QueryTimeseries(
 			return nil, core.ErrTimeseriesEmpty
 		}
 		if multiErr := new(enapterapi.MultiError); errors.As(err, &multiErr) {
-			return nil, a.convertMultiError(multiErr)
+			return nil, convertMultiError(multiErr)
 		}
 		return nil, err
 	}
@@ -95,10 +129,15 @@ func (a *EnapterAPIv3Adapter) QueryTimeseries(
 func (a *EnapterAPIv3Adapter) ExecuteCommand(
 	ctx context.Context, req *core.ExecuteCommandRequest,
 ) (*core.ExecuteCommandResponse, error) {
+	deviceID, err := a.hardwareIDResolver.resolveDeviceID(
+		ctx, req.User, req.DeviceID, req.HardwareID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve device ID: %w", err)
+	}
 	cmdResp, err := a.devicesAPIClient.ExecuteCommand(ctx,
 		devicesapi.ExecuteCommandParams{
 			User:     req.User,
-			DeviceID: req.DeviceID,
+			DeviceID: deviceID,
 			Request: devicesapi.CommandRequest{
 				Name:      req.CommandName,
 				Arguments: req.CommandArgs,
@@ -106,7 +145,7 @@ func (a *EnapterAPIv3Adapter) ExecuteCommand(
 		})
 	if err != nil {
 		if multiErr := new(enapterapi.MultiError); errors.As(err, &multiErr) {
-			return nil, a.convertMultiError(multiErr)
+			return nil, convertMultiError(multiErr)
 		}
 		return nil, err
 	}
@@ -116,16 +155,76 @@ func (a *EnapterAPIv3Adapter) ExecuteCommand(
 	}, nil
 }
 
+func (a *EnapterAPIv3Adapter) StartCommandExecution(
+	ctx context.Context, req *core.ExecuteCommandRequest,
+) (*core.StartCommandExecutionResponse, error) {
+	deviceID, err := a.hardwareIDResolver.resolveDeviceID(
+		ctx, req.User, req.DeviceID, req.HardwareID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve device ID: %w", err)
+	}
+	executionID, err := a.devicesAPIClient.StartCommandExecution(ctx,
+		devicesapi.ExecuteCommandParams{
+			User:     req.User,
+			DeviceID: deviceID,
+			Request: devicesapi.CommandRequest{
+				Name:      req.CommandName,
+				Arguments: req.CommandArgs,
+			},
+		})
+	if err != nil {
+		if multiErr := new(enapterapi.MultiError); errors.As(err, &multiErr) {
+			return nil, convertMultiError(multiErr)
+		}
+		return nil, err
+	}
+	return &core.StartCommandExecutionResponse{
+		DeviceID:    deviceID,
+		ExecutionID: executionID,
+	}, nil
+}
+
+func (a *EnapterAPIv3Adapter) GetCommandExecution(
+	ctx context.Context, req *core.GetCommandExecutionRequest,
+) (*core.GetCommandExecutionResponse, error) {
+	execution, err := a.devicesAPIClient.GetCommandExecution(ctx,
+		devicesapi.GetCommandExecutionParams{
+			User:        req.User,
+			DeviceID:    req.DeviceID,
+			ExecutionID: req.ExecutionID,
+		})
+	if err != nil {
+		if multiErr := new(enapterapi.MultiError); errors.As(err, &multiErr) {
+			return nil, convertMultiError(multiErr)
+		}
+		return nil, err
+	}
+	// The response state is only known once the execution is complete.
+	state := execution.State
+	if execution.Response.State != "" {
+		state = execution.Response.State
+	}
+	return &core.GetCommandExecutionResponse{
+		State:   strings.ToLower(state),
+		Payload: execution.Response.Payload,
+	}, nil
+}
+
 func (a *EnapterAPIv3Adapter) GetDeviceManifest(
 	ctx context.Context, req *core.GetDeviceManifestRequest,
 ) (*core.GetDeviceManifestResponse, error) {
+	deviceID, err := a.hardwareIDResolver.resolveDeviceID(
+		ctx, req.User, req.DeviceID, req.HardwareID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve device ID: %w", err)
+	}
 	manifest, err := a.devicesAPIClient.GetManifest(ctx, devicesapi.GetManifestParams{
 		User:     req.User,
-		DeviceID: req.DeviceID,
+		DeviceID: deviceID,
 	})
 	if err != nil {
 		if multiErr := new(enapterapi.MultiError); errors.As(err, &multiErr) {
-			return nil, a.convertMultiError(multiErr)
+			return nil, convertMultiError(multiErr)
 		}
 		return nil, err
 	}
@@ -134,26 +233,54 @@ func (a *EnapterAPIv3Adapter) GetDeviceManifest(
 	}, nil
 }
 
-func (a *EnapterAPIv3Adapter) convertMultiError(
-	multiErr *enapterapi.MultiError,
-) error {
-	switch len(multiErr.Errors) {
-	case 0:
-		// should never happen, return error as is
-		return multiErr
-	case 1:
-		return core.EnapterAPIError{
-			Code:    multiErr.Errors[0].Code,
-			Message: multiErr.Errors[0].Message,
-			Details: multiErr.Errors[0].Details,
+func (a *EnapterAPIv3Adapter) ListDevices(
+	ctx context.Context, req *core.ListDevicesRequest,
+) (*core.ListDevicesResponse, error) {
+	devices, err := a.devicesAPIClient.ListDevices(ctx, devicesapi.ListDevicesParams{
+		User:        req.User,
+		SiteID:      req.SiteID,
+		BlueprintID: req.BlueprintID,
+	})
+	if err != nil {
+		if multiErr := new(enapterapi.MultiError); errors.As(err, &multiErr) {
+			return nil, convertMultiError(multiErr)
 		}
-	default:
-		a.logger.Warn("multi error contains multiple errors, " +
-			"but this is not supported yet; will return only the first error")
-		return core.EnapterAPIError{
-			Code:    multiErr.Errors[0].Code,
-			Message: multiErr.Errors[0].Message,
-			Details: multiErr.Errors[0].Details,
+		return nil, err
+	}
+	resp := &core.ListDevicesResponse{
+		Devices: make([]core.Device, len(devices)),
+	}
+	for i, d := range devices {
+		resp.Devices[i] = core.Device{
+			ID:          d.ID,
+			HardwareID:  d.HardwareID,
+			Name:        d.Name,
+			SiteID:      d.SiteID,
+			BlueprintID: d.BlueprintID,
 		}
 	}
+	return resp, nil
+}
+
+func (a *EnapterAPIv3Adapter) ResolveDeviceID(
+	ctx context.Context, req *core.ResolveDeviceIDRequest,
+) (*core.ResolveDeviceIDResponse, error) {
+	deviceID, err := a.hardwareIDResolver.resolveDeviceID(
+		ctx, req.User, req.DeviceID, req.HardwareID)
+	if err != nil {
+		return nil, err
+	}
+	return &core.ResolveDeviceIDResponse{DeviceID: deviceID}, nil
+}
+
+func (a *EnapterAPIv3Adapter) listUserDevices(
+	ctx context.Context, user string,
+) ([]core.Device, error) {
+	resp, err := a.ListDevices(ctx, &core.ListDevicesRequest{
+		User: user,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Devices, nil
 }
@@ -36,7 +36,12 @@ func (e *Error) Error() string {
 }
 
 type MultiError struct {
-	Errors []Error `json:"errors"`
+	Errors     []Error `json:"errors"`
+	StatusCode int     `json:"-"`
+}
+
+func (m *MultiError) HTTPStatusCode() int {
+	return m.StatusCode
 }
 
 func (m *MultiError) Error() string {
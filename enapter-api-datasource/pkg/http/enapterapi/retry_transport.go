@@ -0,0 +1,211 @@
+package enapterapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	httputil "github.com/Enapter/grafana-plugins/pkg/http/util"
+)
+
+// RetryPolicy describes how failed requests are retried. The zero value
+// disables retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = 200 * time.Millisecond
+	DefaultRetryMaxBackoff     = 5 * time.Second
+)
+
+// NewRetryTransport returns a transport retrying idempotent requests failed
+// with a network error, 429 or a 5xx status code.
+//
+// A request is considered idempotent under the same rules net/http applies:
+// its method is GET, HEAD, OPTIONS or TRACE, or it has an Idempotency-Key or
+// X-Idempotency-Key header. Setting the header to nil marks the request as
+// idempotent without sending the header.
+//
+// Delays grow exponentially with jitter. Retry-After is honored unless it
+// exceeds MaxBackoff. Retries stop early when the next attempt would not
+// fit in the request context deadline.
+func NewRetryTransport(next http.RoundTripper, p RetryPolicy) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = p.InitialBackoff
+	}
+	return &retryTransport{
+		next:   next,
+		policy: p,
+	}
+}
+
+type retryTransport struct {
+	next   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.policy.MaxAttempts <= 1 || !isIdempotent(req) || !isRewindable(req) {
+		return t.next.RoundTrip(req)
+	}
+
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		resp, err := t.next.RoundTrip(req)
+		if attempt >= t.policy.MaxAttempts || !shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+
+		delay, ok := t.delay(attempt, resp)
+		if !ok || !fitsDeadline(ctx, delay) {
+			return resp, err
+		}
+
+		if resp != nil {
+			// The response is discarded, so a failure to drain it does
+			// not matter.
+			_ = httputil.DrainAndClose(resp.Body)
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		req, err = rewind(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (t *retryTransport) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if c, ok := t.next.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// delay returns how long to wait before the next attempt. It reports false
+// if the server has asked to wait longer than the policy allows.
+func (t *retryTransport) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return d, d <= t.policy.MaxBackoff
+		}
+	}
+
+	backoff := t.policy.MaxBackoff
+	if shift := attempt - 1; shift < 32 {
+		if b := t.policy.InitialBackoff << shift; b > 0 && b < backoff {
+			backoff = b
+		}
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+
+	// Equal jitter keeps at least half of the backoff, so that retries of
+	// concurrent requests are spread out but still back off.
+	half := backoff / 2
+	//nolint:gosec // jitter does not need a secure source
+	return half + time.Duration(rand.Int63n(int64(backoff-half)+1)), true
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	if _, ok := req.Header["Idempotency-Key"]; ok {
+		return true
+	}
+	if _, ok := req.Header["X-Idempotency-Key"]; ok {
+		return true
+	}
+	return false
+}
+
+func isRewindable(req *http.Request) bool {
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.Body = body
+	return req, nil
+}
+
+func shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		// Only network failures are worth another attempt. Other errors,
+		// e.g. those of inner transports, are final.
+		var netErr net.Error
+		return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+func fitsDeadline(ctx context.Context, delay time.Duration) bool {
+	deadline, ok := ctx.Deadline()
+	return !ok || time.Now().Add(delay).Before(deadline)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,152 @@
+package enapterapi_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi"
+)
+
+func TestRetryTransport(t *testing.T) {
+	policy := enapterapi.RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     10 * time.Millisecond,
+	}
+
+	t.Run("should retry idempotent request", func(t *testing.T) {
+		var calls atomic.Int32
+		server := newStatusSequenceServer(t, &calls,
+			http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK)
+
+		resp := doRequest(t, context.Background(), policy,
+			http.MethodGet, server.URL, nil)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.EqualValues(t, 3, calls.Load())
+	})
+
+	t.Run("should rewind body of request with idempotency key", func(t *testing.T) {
+		var calls atomic.Int32
+		var bodies []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			bodies = append(bodies, string(body))
+			if calls.Add(1) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		}))
+		t.Cleanup(server.Close)
+
+		resp := doRequest(t, context.Background(), policy,
+			http.MethodPost, server.URL, http.Header{"Idempotency-Key": nil})
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, []string{"query", "query"}, bodies)
+	})
+
+	t.Run("should NOT retry non-idempotent request", func(t *testing.T) {
+		var calls atomic.Int32
+		server := newStatusSequenceServer(t, &calls,
+			http.StatusServiceUnavailable, http.StatusOK)
+
+		resp := doRequest(t, context.Background(), policy,
+			http.MethodPost, server.URL, nil)
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("should NOT retry client errors", func(t *testing.T) {
+		var calls atomic.Int32
+		server := newStatusSequenceServer(t, &calls,
+			http.StatusNotFound, http.StatusOK)
+
+		resp := doRequest(t, context.Background(), policy,
+			http.MethodGet, server.URL, nil)
+		require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		require.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("should stop after max attempts", func(t *testing.T) {
+		var calls atomic.Int32
+		server := newStatusSequenceServer(t, &calls,
+			http.StatusInternalServerError, http.StatusInternalServerError,
+			http.StatusInternalServerError, http.StatusOK)
+
+		resp := doRequest(t, context.Background(), policy,
+			http.MethodGet, server.URL, nil)
+		require.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		require.EqualValues(t, 3, calls.Load())
+	})
+
+	t.Run("should NOT wait for Retry-After beyond max backoff", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			calls.Add(1)
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		t.Cleanup(server.Close)
+
+		resp := doRequest(t, context.Background(), policy,
+			http.MethodGet, server.URL, nil)
+		require.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		require.EqualValues(t, 1, calls.Load())
+	})
+
+	t.Run("should NOT retry beyond context deadline", func(t *testing.T) {
+		var calls atomic.Int32
+		server := newStatusSequenceServer(t, &calls,
+			http.StatusServiceUnavailable, http.StatusOK)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+		defer cancel()
+
+		slowPolicy := policy
+		slowPolicy.InitialBackoff = time.Second
+		slowPolicy.MaxBackoff = time.Second
+
+		resp := doRequest(t, ctx, slowPolicy, http.MethodGet, server.URL, nil)
+		require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		require.EqualValues(t, 1, calls.Load())
+	})
+}
+
+func newStatusSequenceServer(
+	t *testing.T, calls *atomic.Int32, statuses ...int,
+) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		i := int(calls.Add(1)) - 1
+		w.WriteHeader(statuses[min(i, len(statuses)-1)])
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doRequest(
+	t *testing.T, ctx context.Context, policy enapterapi.RetryPolicy,
+	method, url string, header http.Header,
+) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader("query"))
+	require.NoError(t, err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	client := &http.Client{
+		Transport: enapterapi.NewRetryTransport(http.DefaultTransport, policy),
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
@@ -0,0 +1,27 @@
+package enapterapi
+
+// WithStatusCode attaches the HTTP status code of the response the error has
+// been derived from. The code is available through HTTPStatusCode method.
+func WithStatusCode(err error, statusCode int) error {
+	return &statusCodeError{
+		err:        err,
+		statusCode: statusCode,
+	}
+}
+
+type statusCodeError struct {
+	err        error
+	statusCode int
+}
+
+func (e *statusCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusCodeError) Unwrap() error {
+	return e.err
+}
+
+func (e *statusCodeError) HTTPStatusCode() int {
+	return e.statusCode
+}
@@ -18,15 +18,17 @@ type (
 )
 
 type Client struct {
-	apiURL  string
-	token   string
-	timeout time.Duration
+	apiURL    string
+	token     string
+	timeout   time.Duration
+	transport http.RoundTripper
 }
 
 type ClientParams struct {
-	APIURL  string
-	Token   string
-	Timeout time.Duration
+	APIURL    string
+	Token     string
+	Timeout   time.Duration
+	Transport http.RoundTripper
 }
 
 const DefaultTimeout = 15 * time.Second
@@ -38,10 +40,14 @@ func NewClient(p ClientParams) *Client {
 	if p.Timeout == 0 {
 		p.Timeout = DefaultTimeout
 	}
+	if p.Transport == nil {
+		p.Transport = http.DefaultTransport
+	}
 	return &Client{
-		apiURL:  p.APIURL,
-		token:   p.Token,
-		timeout: p.Timeout,
+		apiURL:    p.APIURL,
+		token:     p.Token,
+		timeout:   p.Timeout,
+		transport: p.Transport,
 	}
 }
 
@@ -97,10 +103,12 @@ func (c *Client) DeviceByID(
 
 func (c *Client) respErrorToMultiError(respErr enapterhttp.ResponseError) error {
 	if len(respErr.Errors) == 0 {
-		return respErr
+		return enapterapi.WithStatusCode(respErr, respErr.StatusCode)
 	}
 
-	multiErr := new(enapterapi.MultiError)
+	multiErr := &enapterapi.MultiError{
+		StatusCode: respErr.StatusCode,
+	}
 
 	for _, e := range respErr.Errors {
 		if len(e.Code) == 0 {
@@ -117,7 +125,7 @@ func (c *Client) respErrorToMultiError(respErr enapterhttp.ResponseError) error
 }
 
 func (c *Client) newEnapterHTTPClient(user string) (*enapterhttp.Client, error) {
-	transport := http.DefaultTransport
+	transport := c.transport
 
 	if c.token != "" {
 		transport = enapterhttp.NewAuthTokenTransport(transport, c.token)
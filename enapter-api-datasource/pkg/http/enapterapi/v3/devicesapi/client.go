@@ -4,9 +4,11 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi"
@@ -47,22 +49,65 @@ func (c *Client) Close() {
 	c.httpClient.CloseIdleConnections()
 }
 
-func (c *Client) Ready(ctx context.Context) error {
-	_, err := c.GetManifest(ctx, GetManifestParams{
-		User:     "does_not_exist",
-		DeviceID: "does_not_exist",
-	})
-	if err == nil {
-		return errUnexpectedAbsenceOfError
+// Ready probes the list of devices, which exists in every deployment of the
+// API. A single device is requested, so that the probe stays cheap however
+// many devices there are. It returns
+// ErrEndpointMissing if the base URL does not point to the devices API and
+// ErrAuthFailed if the token is rejected.
+func (c *Client) Ready(ctx context.Context) (retErr error) {
+	req, err := c.newListDevicesRequest(ctx, ListDevicesParams{Limit: 1}, 0)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
 	}
-	var multiErr *enapterapi.MultiError
-	if ok := errors.As(err, &multiErr); !ok || len(multiErr.Errors) != 1 {
-		return err
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("do request: %w", err)
 	}
-	// FIXME: Cannot differentiate between a non-existing API endpoint and
-	// a non-existing device as there's no error code in the response at
-	// the moment. Checking the text of the error message could help but
-	// that is unreliable as message are subject to change.
+	defer func() {
+		if err := httputil.DrainAndClose(resp.Body); err != nil {
+			if retErr == nil {
+				retErr = err
+			}
+		}
+	}()
+
+	return c.processReadyResponse(resp)
+}
+
+func (c *Client) processReadyResponse(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		break
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("%w: %w", ErrAuthFailed, c.processError(resp))
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return fmt.Errorf("%w: %w", ErrEndpointMissing, c.processUnexpectedStatus(resp))
+	case http.StatusBadRequest, http.StatusConflict, http.StatusUnprocessableEntity,
+		http.StatusTooManyRequests, http.StatusInternalServerError:
+		return c.processError(resp)
+	default:
+		return c.processUnexpectedStatus(resp)
+	}
+
+	// Web servers answering in place of the API tend to reply with HTML.
+	const wantContentType = "application/json"
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != wantContentType {
+		return fmt.Errorf("%w: %w: want %s, have %s", ErrEndpointMissing,
+			errUnexpectedContentType, wantContentType, mediaType)
+	}
+
+	var payload struct {
+		Devices *[]json.RawMessage `json:"devices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return fmt.Errorf("%w: parse body: %w", ErrEndpointMissing, err)
+	}
+	if payload.Devices == nil {
+		return fmt.Errorf("%w: %w", ErrEndpointMissing, errDevicesMissing)
+	}
+
 	return nil
 }
 
@@ -102,7 +147,11 @@ func (c *Client) GetManifest(
 func (c *Client) newGetManifestRequest(
 	ctx context.Context, p GetManifestParams,
 ) (*http.Request, error) {
-	urlString := c.baseURL + fmt.Sprintf("/%s/manifest", p.DeviceID)
+	deviceID, err := pathSegment(p.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("device ID: %w", err)
+	}
+	urlString := c.baseURL + fmt.Sprintf("/%s/manifest", deviceID)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlString, nil)
 	if err != nil {
@@ -150,6 +199,145 @@ func (c *Client) processGetManifestResponse(resp *http.Response) ([]byte, error)
 	return payload.Manifest, nil
 }
 
+type ListDevicesParams struct {
+	User        string
+	SiteID      string
+	BlueprintID string
+	// Limit caps the number of devices returned. Zero means no limit.
+	Limit int
+}
+
+// listDevicesPageSize is the number of devices requested at once.
+const listDevicesPageSize = 100
+
+type Device struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	HardwareID  string `json:"hardware_id"`
+	SiteID      string `json:"site_id"`
+	BlueprintID string `json:"blueprint_id"`
+}
+
+// ListDevices follows the pages of the list until it is exhausted or the
+// limit is reached.
+func (c *Client) ListDevices(
+	ctx context.Context, p ListDevicesParams,
+) ([]Device, error) {
+	var devices []Device
+	for {
+		page := p
+		page.Limit = listDevicesPageSize
+		if p.Limit > 0 {
+			page.Limit = min(page.Limit, p.Limit-len(devices))
+		}
+		pageDevices, err := c.listDevicesPage(ctx, page, len(devices))
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, pageDevices...)
+		if len(pageDevices) < page.Limit ||
+			(p.Limit > 0 && len(devices) >= p.Limit) {
+			return devices, nil
+		}
+	}
+}
+
+func (c *Client) listDevicesPage(
+	ctx context.Context, p ListDevicesParams, offset int,
+) (_ []Device, retErr error) {
+	req, err := c.newListDevicesRequest(ctx, p, offset)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() {
+		if err := httputil.DrainAndClose(resp.Body); err != nil {
+			if retErr == nil {
+				retErr = err
+			}
+		}
+	}()
+
+	devices, err := c.processListDevicesResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("process response: %w", err)
+	}
+
+	return devices, nil
+}
+
+func (c *Client) newListDevicesRequest(
+	ctx context.Context, p ListDevicesParams, offset int,
+) (*http.Request, error) {
+	query := url.Values{}
+	if p.SiteID != "" {
+		query.Set("site_id", p.SiteID)
+	}
+	if p.BlueprintID != "" {
+		query.Set("blueprint_id", p.BlueprintID)
+	}
+	if p.Limit > 0 {
+		query.Set("limit", strconv.Itoa(p.Limit))
+	}
+	if offset > 0 {
+		query.Set("offset", strconv.Itoa(offset))
+	}
+
+	urlString := c.baseURL
+	if len(query) > 0 {
+		urlString += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlString, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header["Accept"] = []string{"application/json"}
+
+	if p.User != "" {
+		const userField = "X-Enapter-Auth-User"
+		req.Header[userField] = []string{p.User}
+	}
+
+	const tokenField = "X-Enapter-Auth-Token" //nolint: gosec // false positive
+	req.Header[tokenField] = []string{c.token}
+
+	return req, nil
+}
+
+func (c *Client) processListDevicesResponse(resp *http.Response) ([]Device, error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		break
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+		http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity,
+		http.StatusTooManyRequests, http.StatusInternalServerError:
+		return nil, c.processError(resp)
+	default:
+		return nil, c.processUnexpectedStatus(resp)
+	}
+
+	const wantContentType = "application/json"
+	if have := resp.Header.Get("Content-Type"); have != wantContentType {
+		return nil, fmt.Errorf("%w: want %s, have %s",
+			errUnexpectedContentType, wantContentType, have)
+	}
+
+	var payload struct {
+		Devices []Device `json:"devices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("parse body: %w", err)
+	}
+
+	return payload.Devices, nil
+}
+
 type ExecuteCommandParams struct {
 	User     string
 	DeviceID string
@@ -202,7 +390,11 @@ func (c *Client) ExecuteCommand(
 func (c *Client) newExecuteCommandRequest(
 	ctx context.Context, p ExecuteCommandParams,
 ) (*http.Request, error) {
-	urlString := c.baseURL + fmt.Sprintf("/%s/execute_command", p.DeviceID)
+	deviceID, err := pathSegment(p.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("device ID: %w", err)
+	}
+	urlString := c.baseURL + fmt.Sprintf("/%s/execute_command", deviceID)
 
 	body := new(bytes.Buffer)
 	if err := json.NewEncoder(body).Encode(p.Request); err != nil {
@@ -258,11 +450,173 @@ func (c *Client) processExecuteCommandResponse(
 	return &payload.Execution, nil
 }
 
+func (c *Client) StartCommandExecution(
+	ctx context.Context, p ExecuteCommandParams,
+) (_ string, retErr error) {
+	req, err := c.newStartCommandExecutionRequest(ctx, p)
+	if err != nil {
+		return "", fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("do request: %w", err)
+	}
+	defer func() {
+		if err := httputil.DrainAndClose(resp.Body); err != nil {
+			if retErr == nil {
+				retErr = err
+			}
+		}
+	}()
+
+	executionID, err := c.processStartCommandExecutionResponse(resp)
+	if err != nil {
+		return "", fmt.Errorf("process response: %w", err)
+	}
+
+	return executionID, nil
+}
+
+func (c *Client) newStartCommandExecutionRequest(
+	ctx context.Context, p ExecuteCommandParams,
+) (*http.Request, error) {
+	deviceID, err := pathSegment(p.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("device ID: %w", err)
+	}
+	urlString := c.baseURL + fmt.Sprintf("/%s/command_executions", deviceID)
+
+	body := new(bytes.Buffer)
+	if err := json.NewEncoder(body).Encode(p.Request); err != nil {
+		return nil, fmt.Errorf("marshal body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlString, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header["Content-Type"] = []string{"application/json"}
+	req.Header["Accept"] = []string{"application/json"}
+
+	if p.User != "" {
+		const userField = "X-Enapter-Auth-User"
+		req.Header[userField] = []string{p.User}
+	}
+
+	const tokenField = "X-Enapter-Auth-Token" //nolint: gosec // false positive
+	req.Header[tokenField] = []string{c.token}
+
+	return req, nil
+}
+
+func (c *Client) processStartCommandExecutionResponse(
+	resp *http.Response,
+) (string, error) {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
+		break
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden,
+		http.StatusNotFound, http.StatusConflict, http.StatusUnprocessableEntity,
+		http.StatusTooManyRequests, http.StatusInternalServerError:
+		return "", c.processError(resp)
+	default:
+		return "", c.processUnexpectedStatus(resp)
+	}
+
+	const wantContentType = "application/json"
+	if have := resp.Header.Get("Content-Type"); have != wantContentType {
+		return "", fmt.Errorf("%w: want %s, have %s",
+			errUnexpectedContentType, wantContentType, have)
+	}
+
+	var payload struct {
+		ExecutionID string `json:"execution_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return "", fmt.Errorf("parse body: %w", err)
+	}
+	if payload.ExecutionID == "" {
+		return "", errExecutionIDMissing
+	}
+
+	return payload.ExecutionID, nil
+}
+
+type GetCommandExecutionParams struct {
+	User        string
+	DeviceID    string
+	ExecutionID string
+}
+
+func (c *Client) GetCommandExecution(
+	ctx context.Context, p GetCommandExecutionParams,
+) (_ *CommandExecution, retErr error) {
+	req, err := c.newGetCommandExecutionRequest(ctx, p)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() {
+		if err := httputil.DrainAndClose(resp.Body); err != nil {
+			if retErr == nil {
+				retErr = err
+			}
+		}
+	}()
+
+	execution, err := c.processExecuteCommandResponse(resp)
+	if err != nil {
+		return nil, fmt.Errorf("process response: %w", err)
+	}
+
+	return execution, nil
+}
+
+func (c *Client) newGetCommandExecutionRequest(
+	ctx context.Context, p GetCommandExecutionParams,
+) (*http.Request, error) {
+	deviceID, err := pathSegment(p.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("device ID: %w", err)
+	}
+	executionID, err := pathSegment(p.ExecutionID)
+	if err != nil {
+		return nil, fmt.Errorf("execution ID: %w", err)
+	}
+	urlString := c.baseURL + fmt.Sprintf("/%s/command_executions/%s",
+		deviceID, executionID)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlString, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header["Accept"] = []string{"application/json"}
+
+	if p.User != "" {
+		const userField = "X-Enapter-Auth-User"
+		req.Header[userField] = []string{p.User}
+	}
+
+	const tokenField = "X-Enapter-Auth-Token" //nolint: gosec // false positive
+	req.Header[tokenField] = []string{c.token}
+
+	return req, nil
+}
+
 func (c *Client) processError(resp *http.Response) error {
 	multiErr, err := enapterapi.ParseMultiError(resp.Body)
 	if err != nil {
-		return fmt.Errorf("multi-error: <not available>: %w", err)
+		return enapterapi.WithStatusCode(
+			fmt.Errorf("multi-error: <not available>: %w", err), resp.StatusCode)
 	}
+	multiErr.StatusCode = resp.StatusCode
 
 	return multiErr
 }
@@ -271,10 +625,22 @@ func (c *Client) processUnexpectedStatus(resp *http.Response) error {
 	dump, err := httputil.DumpBody(resp.Body)
 	if err != nil {
 		//nolint:errorlint // two errors
-		return fmt.Errorf("%w: %s: body dump: <not available>: %v",
-			errUnexpectedStatus, resp.Status, err)
+		return enapterapi.WithStatusCode(fmt.Errorf(
+			"%w: %s: body dump: <not available>: %v",
+			errUnexpectedStatus, resp.Status, err), resp.StatusCode)
 	}
 
-	return fmt.Errorf("%w: %s: body dump: %s",
-		errUnexpectedStatus, resp.Status, dump)
+	return enapterapi.WithStatusCode(fmt.Errorf("%w: %s: body dump: %s",
+		errUnexpectedStatus, resp.Status, dump), resp.StatusCode)
+}
+
+// pathSegment escapes an ID taken from a query for use in a URL path. The
+// segments "." and ".." are rejected, because they would address another
+// path of the API.
+func pathSegment(id string) (string, error) {
+	switch id {
+	case "", ".", "..":
+		return "", fmt.Errorf("%w: %q", errInvalidPathSegment, id)
+	}
+	return url.PathEscape(id), nil
 }
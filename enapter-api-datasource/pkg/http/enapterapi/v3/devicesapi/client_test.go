@@ -38,31 +38,50 @@ func (s *ClientSuite) TearDownTest() {
 }
 
 func (s *ClientSuite) TestReadyOK() {
-	const errorJSON = `{"errors":[{"message":"Oops."}]}`
-	s.server.ExpectGetManifestRequestAndReturnCode(
-		http.StatusNotFound, errorJSON)
+	s.server.ExpectListDevicesRequestAndReturn(http.StatusOK,
+		"application/json; charset=utf-8", `{"devices":[]}`)
 	err := s.client.Ready(s.ctx)
 	s.Require().NoError(err)
 }
 
-func (s *ClientSuite) TestReadyNoMultiError() {
-	const errorJSON = `{"error_message":"Oops."}`
-	s.server.ExpectGetManifestRequestAndReturnCode(
-		http.StatusNotFound, errorJSON)
+func (s *ClientSuite) TestReadyRequestsSingleDevice() {
+	s.server.ExpectListDevicesRequestCheckItAndReturnData(func(r *http.Request) {
+		s.Require().Equal("1", r.URL.Query().Get("limit"))
+	}, []devicesapi.Device{})
 	err := s.client.Ready(s.ctx)
-	s.Require().Error(err)
-	s.Require().Equal(
-		`process response: multi-error: <not available>: empty error list`,
-		err.Error())
+	s.Require().NoError(err)
 }
 
-func (s *ClientSuite) TestReadyUnexpectedAbsenseOfError() {
-	s.server.ExpectGetManifestRequestAndReturnData(`{}`)
+func (s *ClientSuite) TestReadyAuthFailed() {
+	s.server.ExpectListDevicesRequestAndReturn(http.StatusUnauthorized,
+		"application/json", `{"errors":[{"code":"unauthorized","message":"Oops."}]}`)
 	err := s.client.Ready(s.ctx)
-	s.Require().Error(err)
-	s.Require().Equal(
-		"unexpected absence of error",
-		err.Error())
+	s.Require().ErrorIs(err, devicesapi.ErrAuthFailed)
+	multiErr := new(enapterapi.MultiError)
+	s.Require().ErrorAs(err, &multiErr)
+	s.Require().Equal(http.StatusUnauthorized, multiErr.HTTPStatusCode())
+}
+
+func (s *ClientSuite) TestReadyEndpointMissing() {
+	s.server.ExpectListDevicesRequestAndReturn(http.StatusNotFound,
+		"application/json", `{"errors":[{"message":"Oops."}]}`)
+	err := s.client.Ready(s.ctx)
+	s.Require().ErrorIs(err, devicesapi.ErrEndpointMissing)
+}
+
+func (s *ClientSuite) TestReadyUnexpectedContentType() {
+	s.server.ExpectListDevicesRequestAndReturn(http.StatusOK,
+		"text/html", `<html></html>`)
+	err := s.client.Ready(s.ctx)
+	s.Require().ErrorIs(err, devicesapi.ErrEndpointMissing)
+}
+
+func (s *ClientSuite) TestReadyUnexpectedShape() {
+	s.server.ExpectListDevicesRequestAndReturn(http.StatusOK,
+		"application/json", `{"items":[]}`)
+	err := s.client.Ready(s.ctx)
+	s.Require().ErrorIs(err, devicesapi.ErrEndpointMissing)
+	s.Require().Equal("devices API endpoint missing: devices missing", err.Error())
 }
 
 func (s *ClientSuite) TestGetManifestInvalidContentType() {
@@ -129,6 +148,118 @@ func (s *ClientSuite) TestExecuteCommand() {
 	s.Require().Equal(expectedExecution, execution)
 }
 
+func (s *ClientSuite) TestListDevices() {
+	params := devicesapi.ListDevicesParams{
+		User:   faker.Word(),
+		SiteID: faker.UUIDHyphenated(),
+	}
+	expectedDevices := []devicesapi.Device{{
+		ID:          faker.UUIDHyphenated(),
+		Name:        faker.Word(),
+		HardwareID:  faker.Word(),
+		SiteID:      params.SiteID,
+		BlueprintID: faker.UUIDHyphenated(),
+	}}
+	s.server.ExpectListDevicesRequestCheckItAndReturnData(func(r *http.Request) {
+		s.Require().Equal([]string{params.User}, r.Header["X-Enapter-Auth-User"])
+		s.Require().Equal([]string{s.token}, r.Header["X-Enapter-Auth-Token"])
+		s.Require().Equal(params.SiteID, r.URL.Query().Get("site_id"))
+		s.Require().False(r.URL.Query().Has("blueprint_id"))
+	}, expectedDevices)
+	devices, err := s.client.ListDevices(s.ctx, params)
+	s.Require().NoError(err)
+	s.Require().Equal(expectedDevices, devices)
+}
+
+func (s *ClientSuite) TestListDevicesPages() {
+	params := devicesapi.ListDevicesParams{
+		User: faker.Word(),
+	}
+	expectedDevices := make([]devicesapi.Device, 101)
+	for i := range expectedDevices {
+		expectedDevices[i] = devicesapi.Device{ID: faker.UUIDHyphenated()}
+	}
+	// The handlers are stacked, so the last page is expected first.
+	s.server.ExpectListDevicesRequestCheckItAndReturnData(func(r *http.Request) {
+		s.Require().Equal([]string{params.User}, r.Header["X-Enapter-Auth-User"])
+		s.Require().Equal("100", r.URL.Query().Get("limit"))
+		s.Require().Equal("100", r.URL.Query().Get("offset"))
+	}, expectedDevices[100:])
+	s.server.ExpectListDevicesRequestCheckItAndReturnData(func(r *http.Request) {
+		s.Require().Equal("100", r.URL.Query().Get("limit"))
+		s.Require().False(r.URL.Query().Has("offset"))
+	}, expectedDevices[:100])
+	devices, err := s.client.ListDevices(s.ctx, params)
+	s.Require().NoError(err)
+	s.Require().Equal(expectedDevices, devices)
+}
+
+func (s *ClientSuite) TestListDevicesLimit() {
+	params := devicesapi.ListDevicesParams{
+		Limit: 3,
+	}
+	s.server.ExpectListDevicesRequestCheckItAndReturnData(func(r *http.Request) {
+		s.Require().Equal("3", r.URL.Query().Get("limit"))
+	}, make([]devicesapi.Device, 3))
+	devices, err := s.client.ListDevices(s.ctx, params)
+	s.Require().NoError(err)
+	s.Require().Len(devices, 3)
+}
+
+func (s *ClientSuite) TestStartCommandExecution() {
+	params := s.randomExecuteCommandParams()
+	expectedID := faker.UUIDHyphenated()
+	s.server.ExpectStartExecutionRequestCheckItAndReturnID(func(r *http.Request) {
+		s.Require().Equal([]string{params.User}, r.Header["X-Enapter-Auth-User"])
+		s.Require().Equal([]string{s.token}, r.Header["X-Enapter-Auth-Token"])
+		s.Require().Equal(params.DeviceID, r.PathValue("device_id"))
+	}, expectedID)
+	executionID, err := s.client.StartCommandExecution(s.ctx, params)
+	s.Require().NoError(err)
+	s.Require().Equal(expectedID, executionID)
+}
+
+func (s *ClientSuite) TestGetCommandExecution() {
+	params := devicesapi.GetCommandExecutionParams{
+		User:        faker.Word(),
+		DeviceID:    faker.UUIDHyphenated(),
+		ExecutionID: faker.UUIDHyphenated(),
+	}
+	expectedExecution := &devicesapi.CommandExecution{
+		State: "IN_PROGRESS",
+	}
+	s.server.ExpectGetExecutionRequestCheckItAndReturnData(func(r *http.Request) {
+		s.Require().Equal([]string{params.User}, r.Header["X-Enapter-Auth-User"])
+		s.Require().Equal([]string{s.token}, r.Header["X-Enapter-Auth-Token"])
+		s.Require().Equal(params.DeviceID, r.PathValue("device_id"))
+		s.Require().Equal(params.ExecutionID, r.PathValue("execution_id"))
+	}, expectedExecution)
+	execution, err := s.client.GetCommandExecution(s.ctx, params)
+	s.Require().NoError(err)
+	s.Require().Equal(expectedExecution, execution)
+}
+
+func (s *ClientSuite) TestGetCommandExecutionEscapesPath() {
+	params := devicesapi.GetCommandExecutionParams{
+		DeviceID:    "device/../other",
+		ExecutionID: faker.UUIDHyphenated(),
+	}
+	s.server.ExpectGetExecutionRequestCheckItAndReturnData(func(r *http.Request) {
+		s.Require().Equal(params.DeviceID, r.PathValue("device_id"))
+		s.Require().Equal(params.ExecutionID, r.PathValue("execution_id"))
+	}, &devicesapi.CommandExecution{State: "SUCCESS"})
+	_, err := s.client.GetCommandExecution(s.ctx, params)
+	s.Require().NoError(err)
+}
+
+func (s *ClientSuite) TestGetCommandExecutionRejectsDotSegments() {
+	_, err := s.client.GetCommandExecution(s.ctx, devicesapi.GetCommandExecutionParams{
+		DeviceID:    faker.UUIDHyphenated(),
+		ExecutionID: "..",
+	})
+	s.Require().Error(err)
+}
+
 func (s *ClientSuite) randomGetManifestParams() devicesapi.GetManifestParams {
 	return devicesapi.GetManifestParams{
 		User:     faker.Word(),
@@ -3,8 +3,12 @@ package devicesapi
 import "errors"
 
 var (
-	errUnexpectedStatus         = errors.New("unexpected status")
-	errUnexpectedContentType    = errors.New("unexpected content type")
-	errUnexpectedAbsenceOfError = errors.New("unexpected absence of error")
-	ErrNoValues                 = errors.New("no values")
+	errUnexpectedStatus      = errors.New("unexpected status")
+	errUnexpectedContentType = errors.New("unexpected content type")
+	errDevicesMissing        = errors.New("devices missing")
+	errExecutionIDMissing    = errors.New("execution ID missing")
+	errInvalidPathSegment    = errors.New("invalid path segment")
+	ErrNoValues              = errors.New("no values")
+	ErrEndpointMissing       = errors.New("devices API endpoint missing")
+	ErrAuthFailed            = errors.New("authentication failed")
 )
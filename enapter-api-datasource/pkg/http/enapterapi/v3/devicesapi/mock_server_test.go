@@ -16,6 +16,9 @@ type MockServer struct {
 	server                *httptest.Server
 	getManifestHandler    http.HandlerFunc
 	executeCommandHandler http.HandlerFunc
+	listDevicesHandler    http.HandlerFunc
+	startExecutionHandler http.HandlerFunc
+	getExecutionHandler   http.HandlerFunc
 }
 
 func StartMockServer(t *testing.T) *MockServer {
@@ -26,12 +29,21 @@ func StartMockServer(t *testing.T) *MockServer {
 	s.t = t
 	s.getManifestHandler = s.unexpectedRequestHandler
 	s.executeCommandHandler = s.unexpectedRequestHandler
+	s.listDevicesHandler = s.unexpectedRequestHandler
+	s.startExecutionHandler = s.unexpectedRequestHandler
+	s.getExecutionHandler = s.unexpectedRequestHandler
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /v3/devices/{device_id}/manifest",
 		s.handleGetManifest)
 	mux.HandleFunc("POST /v3/devices/{device_id}/execute_command",
 		s.handleExecuteCommand)
+	mux.HandleFunc("GET /v3/devices",
+		s.handleListDevices)
+	mux.HandleFunc("POST /v3/devices/{device_id}/command_executions",
+		s.handleStartExecution)
+	mux.HandleFunc("GET /v3/devices/{device_id}/command_executions/{execution_id}",
+		s.handleGetExecution)
 
 	s.server = httptest.NewServer(mux)
 
@@ -46,6 +58,18 @@ func (s *MockServer) handleExecuteCommand(w http.ResponseWriter, r *http.Request
 	s.executeCommandHandler(w, r)
 }
 
+func (s *MockServer) handleListDevices(w http.ResponseWriter, r *http.Request) {
+	s.listDevicesHandler(w, r)
+}
+
+func (s *MockServer) handleStartExecution(w http.ResponseWriter, r *http.Request) {
+	s.startExecutionHandler(w, r)
+}
+
+func (s *MockServer) handleGetExecution(w http.ResponseWriter, r *http.Request) {
+	s.getExecutionHandler(w, r)
+}
+
 func (s *MockServer) Stop() {
 	s.server.Close()
 }
@@ -68,14 +92,6 @@ func (s *MockServer) ExpectGetManifestRequestAndReturnInvalidContentType() {
 	})
 }
 
-func (s *MockServer) ExpectGetManifestRequestAndReturnCode(code int, description string) {
-	s.replaceGetManifestHandler(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(code)
-		_, err := w.Write([]byte(description))
-		require.NoError(s.t, err)
-	})
-}
-
 func (s *MockServer) ExpectGetManifestRequestCheckItAndReturnData(
 	checkFn func(*http.Request), manifest string,
 ) {
@@ -88,15 +104,6 @@ func (s *MockServer) ExpectGetManifestRequestCheckItAndReturnData(
 	})
 }
 
-func (s *MockServer) ExpectGetManifestRequestAndReturnData(manifest string) {
-	s.replaceGetManifestHandler(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusOK)
-		_, err := w.Write([]byte(`{"manifest":` + manifest + `}`))
-		require.NoError(s.t, err)
-	})
-}
-
 func (s *MockServer) ExpectExecuteCommandRequestAndReturnInvalidContentType() {
 	s.replaceExecuteCommandHandler(func(w http.ResponseWriter, r *http.Request) {
 		data := []byte("{}")
@@ -131,6 +138,71 @@ func (s *MockServer) ExpectExecuteCommandRequestCheckItAndReturnData(
 	})
 }
 
+func (s *MockServer) ExpectListDevicesRequestCheckItAndReturnData(
+	checkFn func(*http.Request), devices []devicesapi.Device,
+) {
+	s.replaceListDevicesHandler(func(w http.ResponseWriter, r *http.Request) {
+		checkFn(r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err := json.NewEncoder(w).Encode(map[string]any{
+			"devices": devices,
+		})
+		require.NoError(s.t, err)
+	})
+}
+
+func (s *MockServer) ExpectListDevicesRequestAndReturn(
+	code int, contentType, body string,
+) {
+	s.replaceListDevicesHandler(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(code)
+		_, err := w.Write([]byte(body))
+		require.NoError(s.t, err)
+	})
+}
+
+func (s *MockServer) ExpectStartExecutionRequestCheckItAndReturnID(
+	checkFn func(*http.Request), executionID string,
+) {
+	s.replaceStartExecutionHandler(func(w http.ResponseWriter, r *http.Request) {
+		checkFn(r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		err := json.NewEncoder(w).Encode(map[string]any{
+			"execution_id": executionID,
+		})
+		require.NoError(s.t, err)
+	})
+}
+
+func (s *MockServer) ExpectGetExecutionRequestCheckItAndReturnData(
+	checkFn func(*http.Request), execution *devicesapi.CommandExecution,
+) {
+	s.replaceGetExecutionHandler(func(w http.ResponseWriter, r *http.Request) {
+		checkFn(r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		err := json.NewEncoder(w).Encode(map[string]any{
+			"execution": execution,
+		})
+		require.NoError(s.t, err)
+	})
+}
+
+func (s *MockServer) replaceStartExecutionHandler(h http.HandlerFunc) {
+	s.replaceHandler(&s.startExecutionHandler, h)
+}
+
+func (s *MockServer) replaceGetExecutionHandler(h http.HandlerFunc) {
+	s.replaceHandler(&s.getExecutionHandler, h)
+}
+
+func (s *MockServer) replaceListDevicesHandler(h http.HandlerFunc) {
+	s.replaceHandler(&s.listDevicesHandler, h)
+}
+
 func (s *MockServer) replaceGetManifestHandler(h http.HandlerFunc) {
 	s.replaceHandler(&s.getManifestHandler, h)
 }
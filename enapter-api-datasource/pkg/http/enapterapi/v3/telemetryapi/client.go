@@ -12,6 +12,10 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/grafana/grafana-plugin-sdk-go/backend/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi"
 	httputil "github.com/Enapter/grafana-plugins/pkg/http/util"
@@ -108,7 +112,7 @@ func (c *Client) Timeseries(ctx context.Context, p TimeseriesParams) (_ *Timeser
 		}
 	}()
 
-	timeseries, err := c.processTimeseriesResponse(resp)
+	timeseries, err := c.processTimeseriesResponse(ctx, resp)
 	if err != nil {
 		return nil, fmt.Errorf("process timeseries response: %w", err)
 	}
@@ -127,6 +131,11 @@ func (c *Client) newTimeseriesRequest(ctx context.Context, p TimeseriesParams) (
 
 	req.Header["Accept"] = []string{"text/csv"}
 
+	// Querying timeseries does not change anything, so the request may be
+	// retried. The nil value marks it as idempotent without sending the
+	// header.
+	req.Header["Idempotency-Key"] = nil
+
 	if p.User != "" {
 		const userField = "X-Enapter-Auth-User"
 		req.Header[userField] = []string{p.User}
@@ -138,7 +147,9 @@ func (c *Client) newTimeseriesRequest(ctx context.Context, p TimeseriesParams) (
 	return req, nil
 }
 
-func (c *Client) processTimeseriesResponse(resp *http.Response) (*Timeseries, error) {
+func (c *Client) processTimeseriesResponse(
+	ctx context.Context, resp *http.Response,
+) (*Timeseries, error) {
 	switch resp.StatusCode {
 	case http.StatusOK:
 		break
@@ -180,7 +191,7 @@ func (c *Client) processTimeseriesResponse(resp *http.Response) (*Timeseries, er
 		return nil, fmt.Errorf("parse data types: %w", err)
 	}
 
-	timeseries, err := c.parseTimeseriesCSV(resp.Body, dataTypes)
+	timeseries, err := c.parseTimeseriesCSV(ctx, resp.Body, dataTypes)
 	if err != nil {
 		return nil, fmt.Errorf("parse CSV: %w", err)
 	}
@@ -189,8 +200,20 @@ func (c *Client) processTimeseriesResponse(resp *http.Response) (*Timeseries, er
 }
 
 func (c *Client) parseTimeseriesCSV(
-	reader io.Reader, dataTypes []TimeseriesDataType,
-) (*Timeseries, error) {
+	ctx context.Context, reader io.Reader, dataTypes []TimeseriesDataType,
+) (_ *Timeseries, retErr error) {
+	_, span := tracing.DefaultTracer().Start(ctx, "parseTimeseriesCSV",
+		trace.WithAttributes(attribute.Int("series_count", len(dataTypes))))
+	var rows int
+	defer func() {
+		span.SetAttributes(attribute.Int("row_count", rows))
+		if retErr != nil {
+			span.RecordError(retErr)
+			span.SetStatus(codes.Error, retErr.Error())
+		}
+		span.End()
+	}()
+
 	csvReader := csv.NewReader(reader)
 
 	const dontCheckNumberOfFields = -1
@@ -226,6 +249,7 @@ func (c *Client) parseTimeseriesCSV(
 		}
 
 		timeseries.TimeField = append(timeseries.TimeField, timestamp)
+		rows++
 		for j, value := range values {
 			dataField := timeseries.DataFields[j]
 			dataField.Values = append(dataField.Values, value)
@@ -297,8 +321,10 @@ func (c *Client) parseTimeseriesCSVRecord(
 func (c *Client) processError(resp *http.Response) error {
 	multiErr, err := enapterapi.ParseMultiError(resp.Body)
 	if err != nil {
-		return fmt.Errorf("multi-error: <not available>: %w", err)
+		return enapterapi.WithStatusCode(
+			fmt.Errorf("multi-error: <not available>: %w", err), resp.StatusCode)
 	}
+	multiErr.StatusCode = resp.StatusCode
 
 	return multiErr
 }
@@ -307,10 +333,11 @@ func (c *Client) processUnexpectedStatus(resp *http.Response) error {
 	dump, err := httputil.DumpBody(resp.Body)
 	if err != nil {
 		//nolint:errorlint // two errors
-		return fmt.Errorf("%w: %s: body dump: <not available>: %v",
-			errUnexpectedStatus, resp.Status, err)
+		return enapterapi.WithStatusCode(fmt.Errorf(
+			"%w: %s: body dump: <not available>: %v",
+			errUnexpectedStatus, resp.Status, err), resp.StatusCode)
 	}
 
-	return fmt.Errorf("%w: %s: body dump: %s",
-		errUnexpectedStatus, resp.Status, dump)
+	return enapterapi.WithStatusCode(fmt.Errorf("%w: %s: body dump: %s",
+		errUnexpectedStatus, resp.Status, dump), resp.StatusCode)
 }
@@ -11,6 +11,7 @@ import (
 	"github.com/bxcodec/faker/v3"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi"
 	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi/v3/telemetryapi"
 )
 
@@ -56,6 +57,10 @@ func (s *ClientSuite) TestReadyUnexpectedCode() {
 	s.Require().Equal(
 		`process timeseries response: code=invalid_query_parameter_format, message="Oops."`,
 		err.Error())
+
+	var multiErr *enapterapi.MultiError
+	s.Require().ErrorAs(err, &multiErr)
+	s.Require().Equal(http.StatusUnprocessableEntity, multiErr.HTTPStatusCode())
 }
 
 func (s *ClientSuite) TestReadyUnexpectedAbsenseOfError() {
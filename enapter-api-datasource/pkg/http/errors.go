@@ -5,4 +5,10 @@ import "errors"
 var (
 	errEnapterAPIURLEmptyOrMissing = errors.New("Enapter API URL empty or missing")
 	errUnexpectedStatusCode        = errors.New("unexpected status code")
+	errUnsupportedResponseSchema   = errors.New("unsupported response schema")
+	errUnexpectedResponseSchema    = errors.New("response does not match any allowed schema")
+	errTLSHandshake                = errors.New("TLS handshake failed")
+	errProxyConnect                = errors.New("proxy connection failed")
+	errDNSLookup                   = errors.New("DNS lookup failed")
+	errAPIVersionNotDetected       = errors.New("Enapter API version not detected")
 )
@@ -0,0 +1,242 @@
+package http
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
+)
+
+const (
+	defaultHardwareIDCacheTTL = 5 * time.Minute
+	// A hardware ID that is not found is looked up again only once the
+	// devices listed are this old.
+	defaultHardwareIDNegativeTTL = 10 * time.Second
+	// Forwarded OAuth tokens rotate, and every token gets an entry of its
+	// own, so the number of entries is bounded.
+	defaultHardwareIDCacheMaxEntries = 1000
+)
+
+// hardwareIDResolver maps hardware IDs to device IDs. Mappings are cached
+// per user, because different users may see different sets of devices.
+// Users whose OAuth tokens are forwarded are told apart by the tokens.
+// Concurrent listings of the devices of the same user are coalesced.
+type hardwareIDResolver struct {
+	listDevices func(ctx context.Context, user string) ([]core.Device, error)
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	metrics     *metrics.DataSource
+	now         func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]*list.Element
+	lru      *list.List
+	inFlight map[string]*deviceListing
+}
+
+type hardwareIDCacheEntry struct {
+	key       string
+	deviceIDs map[string]string
+	listedAt  time.Time
+	expiresAt time.Time
+}
+
+type deviceListing struct {
+	done      chan struct{}
+	deviceIDs map[string]string
+	err       error
+}
+
+func newHardwareIDResolver(
+	listDevices func(ctx context.Context, user string) ([]core.Device, error),
+	m *metrics.DataSource,
+) *hardwareIDResolver {
+	return &hardwareIDResolver{
+		listDevices: listDevices,
+		ttl:         defaultHardwareIDCacheTTL,
+		negativeTTL: defaultHardwareIDNegativeTTL,
+		maxEntries:  defaultHardwareIDCacheMaxEntries,
+		metrics:     m,
+		now:         time.Now,
+		entries:     make(map[string]*list.Element),
+		lru:         list.New(),
+		inFlight:    make(map[string]*deviceListing),
+	}
+}
+
+// resolveDeviceID returns the ID of the device addressed by the given pair
+// of IDs. Either of them may be empty, but not both.
+func (r *hardwareIDResolver) resolveDeviceID(
+	ctx context.Context, user, deviceID, hardwareID string,
+) (string, error) {
+	if hardwareID == "" {
+		return deviceID, nil
+	}
+
+	resolved, err := r.resolve(ctx, user, hardwareID)
+	if err != nil {
+		return "", err
+	}
+
+	if deviceID != "" && deviceID != resolved {
+		return "", core.ErrDeviceIDMismatch
+	}
+
+	return resolved, nil
+}
+
+func (r *hardwareIDResolver) resolve(
+	ctx context.Context, user, hardwareID string,
+) (string, error) {
+	key := cacheKey(ctx, user)
+	for {
+		r.mu.Lock()
+		if entry, ok := r.lookupLocked(key); ok {
+			_, found := entry.deviceIDs[hardwareID]
+			// The device may have been added after the devices were
+			// listed, so they are listed again unless that was recent.
+			if found || r.now().Sub(entry.listedAt) < r.negativeTTL {
+				r.mu.Unlock()
+				r.metrics.CountCacheLookup("hardware_id", "hit")
+				return lookupDeviceID(entry.deviceIDs, hardwareID)
+			}
+		}
+		if l, ok := r.inFlight[key]; ok {
+			r.mu.Unlock()
+			r.metrics.CountCacheLookup("hardware_id", "coalesced")
+			select {
+			case <-l.done:
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+			// The context of the caller which has actually listed the
+			// devices may have been cancelled, which tells nothing about
+			// the devices.
+			if isContextError(l.err) && ctx.Err() == nil {
+				continue
+			}
+			if l.err != nil {
+				return "", l.err
+			}
+			return lookupDeviceID(l.deviceIDs, hardwareID)
+		}
+		l := &deviceListing{done: make(chan struct{})}
+		r.inFlight[key] = l
+		r.mu.Unlock()
+		r.metrics.CountCacheLookup("hardware_id", "miss")
+
+		listedAt := r.now()
+		l.deviceIDs, l.err = r.list(ctx, user)
+
+		r.mu.Lock()
+		delete(r.inFlight, key)
+		close(l.done)
+		if l.err == nil {
+			r.storeLocked(&hardwareIDCacheEntry{
+				key:       key,
+				deviceIDs: l.deviceIDs,
+				listedAt:  listedAt,
+				expiresAt: listedAt.Add(r.ttl),
+			})
+		}
+		r.mu.Unlock()
+
+		if l.err != nil {
+			return "", l.err
+		}
+		return lookupDeviceID(l.deviceIDs, hardwareID)
+	}
+}
+
+func (r *hardwareIDResolver) list(
+	ctx context.Context, user string,
+) (map[string]string, error) {
+	devices, err := r.listDevices(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceIDs := make(map[string]string, len(devices))
+	for _, d := range devices {
+		if d.HardwareID != "" {
+			deviceIDs[d.HardwareID] = d.ID
+		}
+	}
+	return deviceIDs, nil
+}
+
+func lookupDeviceID(deviceIDs map[string]string, hardwareID string) (string, error) {
+	deviceID, ok := deviceIDs[hardwareID]
+	if !ok {
+		return "", core.ErrHardwareIDNotFound
+	}
+	return deviceID, nil
+}
+
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// cacheKey identifies the user by the forwarded access token, if any, so
+// that the token itself is not kept in memory.
+func cacheKey(ctx context.Context, user string) string {
+	token := core.AccessTokenFromContext(ctx)
+	if token == "" {
+		return "user:" + user
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "token:" + hex.EncodeToString(sum[:])
+}
+
+func (r *hardwareIDResolver) lookupLocked(key string) (*hardwareIDCacheEntry, bool) {
+	elem, ok := r.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*hardwareIDCacheEntry) //nolint:forcetypeassert // always
+	if r.now().After(entry.expiresAt) {
+		r.removeLocked(elem)
+		return nil, false
+	}
+	r.lru.MoveToFront(elem)
+	return entry, true
+}
+
+// storeLocked also evicts the expired entries, because the entries of
+// rotated tokens are never looked up again.
+func (r *hardwareIDResolver) storeLocked(entry *hardwareIDCacheEntry) {
+	now := r.now()
+	for elem := r.lru.Front(); elem != nil; {
+		next := elem.Next()
+		//nolint:forcetypeassert // always
+		if now.After(elem.Value.(*hardwareIDCacheEntry).expiresAt) {
+			r.removeLocked(elem)
+		}
+		elem = next
+	}
+
+	if elem, ok := r.entries[entry.key]; ok {
+		elem.Value = entry
+		r.lru.MoveToFront(elem)
+		return
+	}
+	r.entries[entry.key] = r.lru.PushFront(entry)
+
+	for r.maxEntries > 0 && r.lru.Len() > r.maxEntries {
+		r.removeLocked(r.lru.Back())
+	}
+}
+
+func (r *hardwareIDResolver) removeLocked(elem *list.Element) {
+	r.lru.Remove(elem)
+	//nolint:forcetypeassert // always
+	delete(r.entries, elem.Value.(*hardwareIDCacheEntry).key)
+}
@@ -0,0 +1,90 @@
+package http_test
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/http"
+)
+
+func TestHardwareIDResolution(t *testing.T) {
+	started := make(chan struct{}, 1)
+	var (
+		release  chan struct{}
+		listings atomic.Int32
+	)
+	server := httptest.NewServer(nethttp.HandlerFunc(
+		func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			listings.Add(1)
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			if release != nil {
+				<-release
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"devices":[{"id":"dev","hardware_id":"hw"}]}`))
+		}))
+	defer server.Close()
+
+	newAdapter := func(t *testing.T) *http.EnapterAPIv3Adapter {
+		t.Helper()
+		listings.Store(0)
+		a, err := http.NewEnapterAPIv3Adapter(http.EnapterAPIv3AdapterParams{
+			Logger: log.DefaultLogger,
+			APIURL: server.URL,
+		})
+		require.NoError(t, err)
+		t.Cleanup(a.Close)
+		return a
+	}
+	ctx := context.Background()
+
+	t.Run("should remember hardware ID not found", func(t *testing.T) {
+		a := newAdapter(t)
+		for range 3 {
+			_, err := a.ResolveDeviceID(ctx, &core.ResolveDeviceIDRequest{
+				User:       "user",
+				HardwareID: "unknown",
+			})
+			require.ErrorIs(t, err, core.ErrHardwareIDNotFound)
+		}
+		require.Equal(t, int32(1), listings.Load())
+	})
+
+	t.Run("should coalesce concurrent listings", func(t *testing.T) {
+		release = make(chan struct{})
+		defer func() { release = nil }()
+		a := newAdapter(t)
+
+		var wg sync.WaitGroup
+		resolve := func() {
+			defer wg.Done()
+			resp, err := a.ResolveDeviceID(ctx, &core.ResolveDeviceIDRequest{
+				User:       "user",
+				HardwareID: "hw",
+			})
+			require.NoError(t, err)
+			require.Equal(t, "dev", resp.DeviceID)
+		}
+		wg.Add(1)
+		go resolve()
+		<-started
+		for range 4 {
+			wg.Add(1)
+			go resolve()
+		}
+		close(release)
+		wg.Wait()
+		require.Equal(t, int32(1), listings.Load())
+	})
+}
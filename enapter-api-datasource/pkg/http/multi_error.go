@@ -0,0 +1,29 @@
+package http
+
+import (
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/http/enapterapi"
+)
+
+func convertMultiError(multiErr *enapterapi.MultiError) error {
+	if len(multiErr.Errors) == 0 {
+		// should never happen, return error as is
+		return multiErr
+	}
+
+	apiErr := convertError(multiErr.Errors[0])
+	apiErr.StatusCode = multiErr.StatusCode
+	for _, e := range multiErr.Errors[1:] {
+		apiErr.More = append(apiErr.More, convertError(e))
+	}
+
+	return apiErr
+}
+
+func convertError(e enapterapi.Error) core.EnapterAPIError {
+	return core.EnapterAPIError{
+		Code:    e.Code,
+		Message: e.Message,
+		Details: e.Details,
+	}
+}
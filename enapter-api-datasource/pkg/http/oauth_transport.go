@@ -0,0 +1,40 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+// oauthTransport replaces the data source credentials with the OAuth access
+// token of the Grafana user, if the request context carries one.
+type oauthTransport struct {
+	next http.RoundTripper
+}
+
+func newOAuthTransport(next http.RoundTripper) http.RoundTripper {
+	return &oauthTransport{next: next}
+}
+
+func (t *oauthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := core.AccessTokenFromContext(req.Context())
+	if token == "" {
+		return t.next.RoundTrip(req)
+	}
+
+	// RoundTrip must not modify the request.
+	req = req.Clone(req.Context())
+	req.Header.Del("X-Enapter-Auth-Token")
+	req.Header.Del("X-Enapter-Auth-User")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return t.next.RoundTrip(req)
+}
+
+func (t *oauthTransport) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if c, ok := t.next.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,160 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/metrics"
+)
+
+type RateLimiterParams struct {
+	RequestsPerSecond float64
+	// Burst defaults to the number of requests per second, but at least 1.
+	Burst int
+	// MaxQueued limits the number of requests waiting for their turn.
+	// Zero means no limit.
+	MaxQueued int
+	Metrics   *metrics.DataSource
+}
+
+// RateLimiter is a token bucket limiting the rate of requests to Enapter
+// API. A single limiter is meant to be shared by all clients using the same
+// API token.
+type RateLimiter struct {
+	rate      float64
+	burst     float64
+	maxQueued int
+	metrics   *metrics.DataSource
+	now       func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	queued int
+}
+
+func NewRateLimiter(p RateLimiterParams) *RateLimiter {
+	if p.RequestsPerSecond <= 0 {
+		panic("RequestsPerSecond must be positive")
+	}
+	if p.Burst <= 0 {
+		p.Burst = int(math.Max(1, math.Ceil(p.RequestsPerSecond)))
+	}
+	return &RateLimiter{
+		rate:      p.RequestsPerSecond,
+		burst:     float64(p.Burst),
+		maxQueued: p.MaxQueued,
+		metrics:   p.Metrics,
+		now:       time.Now,
+		tokens:    float64(p.Burst),
+		last:      time.Now(),
+	}
+}
+
+// Wait blocks until the request is allowed to proceed. It fails without
+// waiting if the queue is full or if the turn would come after the context
+// deadline.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	err := l.wait(ctx)
+	result := "allowed"
+	if err != nil {
+		result = "rejected"
+	}
+	l.metrics.ObserveRateLimiterWait(result, time.Since(start))
+	return err
+}
+
+func (l *RateLimiter) wait(ctx context.Context) error {
+	delay, err := l.reserve(ctx)
+	if err != nil || delay == 0 {
+		return err
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		l.mu.Lock()
+		l.queued--
+		l.metrics.AddRateLimiterQueued(-1)
+		l.mu.Unlock()
+		return nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		l.queued--
+		l.metrics.AddRateLimiterQueued(-1)
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (l *RateLimiter) reserve(ctx context.Context) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, nil
+	}
+
+	if l.maxQueued > 0 && l.queued >= l.maxQueued {
+		return 0, fmt.Errorf("%w: %d requests queued", core.ErrRateLimitExceeded, l.queued)
+	}
+
+	delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if deadline, ok := ctx.Deadline(); ok && now.Add(delay).After(deadline) {
+		return 0, fmt.Errorf("%w: wait of %s exceeds deadline", core.ErrRateLimitExceeded, delay)
+	}
+
+	l.tokens--
+	l.queued++
+	l.metrics.AddRateLimiterQueued(1)
+	return delay, nil
+}
+
+func newRateLimitTransport(next http.RoundTripper, limiter *RateLimiter) http.RoundTripper {
+	if limiter == nil {
+		return next
+	}
+	return &rateLimitTransport{
+		next:    next,
+		limiter: limiter,
+	}
+}
+
+type rateLimitTransport struct {
+	next    http.RoundTripper
+	limiter *RateLimiter
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		if req.Body != nil {
+			// RoundTrip must always close the body.
+			_ = req.Body.Close()
+		}
+		return nil, fmt.Errorf("rate limit: %w", err)
+	}
+	return t.next.RoundTrip(req)
+}
+
+func (t *rateLimitTransport) CloseIdleConnections() {
+	type closeIdler interface {
+		CloseIdleConnections()
+	}
+	if c, ok := t.next.(closeIdler); ok {
+		c.CloseIdleConnections()
+	}
+}
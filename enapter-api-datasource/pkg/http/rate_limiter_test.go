@@ -0,0 +1,79 @@
+package http_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/http"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("should allow burst without waiting", func(t *testing.T) {
+		limiter := http.NewRateLimiter(http.RateLimiterParams{
+			RequestsPerSecond: 1,
+			Burst:             3,
+		})
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, limiter.Wait(context.Background()))
+		}
+		require.Less(t, time.Since(start), 500*time.Millisecond)
+	})
+
+	t.Run("should delay requests beyond burst", func(t *testing.T) {
+		limiter := http.NewRateLimiter(http.RateLimiterParams{
+			RequestsPerSecond: 50,
+			Burst:             1,
+		})
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			require.NoError(t, limiter.Wait(context.Background()))
+		}
+		require.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+	})
+
+	t.Run("should fail if queue is full", func(t *testing.T) {
+		limiter := http.NewRateLimiter(http.RateLimiterParams{
+			RequestsPerSecond: 0.1,
+			Burst:             1,
+			MaxQueued:         1,
+		})
+		require.NoError(t, limiter.Wait(context.Background()))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		queued := make(chan error)
+		go func() { queued <- limiter.Wait(ctx) }()
+
+		// Until the other request is queued, the short deadline makes the
+		// limiter fail for another reason.
+		require.Eventually(t, func() bool {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			defer cancel()
+			err := limiter.Wait(ctx)
+			return errors.Is(err, core.ErrRateLimitExceeded) &&
+				strings.Contains(err.Error(), "requests queued")
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		require.ErrorIs(t, <-queued, context.Canceled)
+	})
+
+	t.Run("should fail fast if wait exceeds deadline", func(t *testing.T) {
+		limiter := http.NewRateLimiter(http.RateLimiterParams{
+			RequestsPerSecond: 0.1,
+			Burst:             1,
+		})
+		require.NoError(t, limiter.Wait(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.ErrorIs(t, limiter.Wait(ctx), core.ErrRateLimitExceeded)
+	})
+}
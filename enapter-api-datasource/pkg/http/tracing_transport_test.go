@@ -0,0 +1,48 @@
+package http_test
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Enapter/grafana-plugins/pkg/http"
+)
+
+func TestTracingTransportPropagatesTraceContext(t *testing.T) {
+	origPropagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(origPropagator)
+
+	var received *nethttp.Request
+	server := httptest.NewServer(nethttp.HandlerFunc(
+		func(_ nethttp.ResponseWriter, r *nethttp.Request) {
+			received = r
+		}))
+	defer server.Close()
+
+	traceID := trace.TraceID{0x01, 0x02, 0x03}
+	ctx := trace.ContextWithSpanContext(context.Background(),
+		trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     trace.SpanID{0x04},
+			TraceFlags: trace.FlagsSampled,
+		}))
+
+	client := &nethttp.Client{
+		Transport: http.NewTracingTransport(nethttp.DefaultTransport),
+	}
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	require.Contains(t, received.Header.Get("Traceparent"), traceID.String())
+	require.Empty(t, req.Header.Get("Traceparent"), "request must not be modified")
+}
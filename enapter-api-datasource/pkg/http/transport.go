@@ -0,0 +1,59 @@
+package http
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/grafana/grafana-plugin-sdk-go/backend/proxy"
+)
+
+type TransportParams struct {
+	TLSConfig *tls.Config
+	// ProxyURL is an HTTP or HTTPS proxy. Its credentials, if any, are
+	// taken from the user info.
+	ProxyURL *url.URL
+	// SecureSocksProxy takes effect only if the Grafana instance allows the
+	// secure SOCKS proxy as well.
+	SecureSocksProxy *proxy.Options
+	// ConnectTimeout bounds establishing of a connection. It does not apply
+	// to connections made through the secure SOCKS proxy.
+	ConnectTimeout time.Duration
+	// ResponseHeaderTimeout bounds waiting for the response headers after
+	// the request has been sent.
+	ResponseHeaderTimeout time.Duration
+}
+
+// NewTransport returns the transport actually sending requests. The default
+// transport is shared unless the connections need their own configuration.
+func NewTransport(p TransportParams) (http.RoundTripper, error) {
+	if p.TLSConfig == nil && p.ProxyURL == nil && p.ConnectTimeout == 0 &&
+		p.ResponseHeaderTimeout == 0 &&
+		!proxy.SecureSocksProxyEnabled(p.SecureSocksProxy) {
+		return http.DefaultTransport, nil
+	}
+
+	//nolint:forcetypeassert // always *http.Transport
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if p.TLSConfig != nil {
+		t.TLSClientConfig = p.TLSConfig
+	}
+	if p.ProxyURL != nil {
+		t.Proxy = http.ProxyURL(p.ProxyURL)
+	}
+	if p.ConnectTimeout != 0 {
+		const keepAlive = 30 * time.Second
+		t.DialContext = (&net.Dialer{
+			Timeout:   p.ConnectTimeout,
+			KeepAlive: keepAlive,
+		}).DialContext
+	}
+	t.ResponseHeaderTimeout = p.ResponseHeaderTimeout
+	if err := proxy.ConfigureSecureSocksHTTPProxy(t, p.SecureSocksProxy); err != nil {
+		return nil, fmt.Errorf("secure SOCKS proxy: %w", err)
+	}
+	return t, nil
+}
@@ -13,25 +13,45 @@ import (
 )
 
 type UserResolverAdapterParams struct {
-	URL     string
-	Timeout time.Duration
+	URL       string
+	Timeout   time.Duration
+	Transport http.RoundTripper
+	// ResponseSchemas allowlists the accepted responses. Each schema is a
+	// JSON object with a single string field holding the user ID, and is
+	// named after that field: "guid" or "id". Only "guid" is accepted by
+	// default.
+	ResponseSchemas []string
 }
 
 type UserResolverAdapter struct {
-	url        string
-	httpClient http.Client
+	url          string
+	httpClient   http.Client
+	schemaFields map[string]struct{}
 }
 
-func NewUserResolverAdapter(p UserResolverAdapterParams) *UserResolverAdapter {
+func NewUserResolverAdapter(p UserResolverAdapterParams) (*UserResolverAdapter, error) {
 	if p.Timeout == 0 {
 		p.Timeout = 15 * time.Second
 	}
+	if len(p.ResponseSchemas) == 0 {
+		p.ResponseSchemas = []string{"guid"}
+	}
+	schemaFields := make(map[string]struct{}, len(p.ResponseSchemas))
+	for _, schema := range p.ResponseSchemas {
+		if schema != "guid" && schema != "id" {
+			return nil, fmt.Errorf(`%w: want "guid" or "id", have %q`,
+				errUnsupportedResponseSchema, schema)
+		}
+		schemaFields[schema] = struct{}{}
+	}
 	return &UserResolverAdapter{
 		httpClient: http.Client{
-			Timeout: p.Timeout,
+			Timeout:   p.Timeout,
+			Transport: p.Transport,
 		},
-		url: p.URL,
-	}
+		url:          p.URL,
+		schemaFields: schemaFields,
+	}, nil
 }
 
 func (a *UserResolverAdapter) ResolveUser(
@@ -71,20 +91,51 @@ func (a *UserResolverAdapter) newResolveUserRequest(
 func (a *UserResolverAdapter) processResolveUserResponse(
 	httpResp *http.Response,
 ) (*core.ResolveUserResponse, error) {
+	if httpResp.StatusCode == http.StatusNotFound {
+		return nil, core.ErrUserNotFound
+	}
 	if httpResp.StatusCode != http.StatusOK {
 		return nil, a.processUnexpectedStatusCode(httpResp)
 	}
-	var payload struct {
-		GUID string `json:"guid"`
+	const maxBodySize = 64 << 10
+	dec, err := httputil.NewJSONResponseDecoder(httpResp, maxBodySize)
+	if err != nil {
+		return nil, err
 	}
-	if err := json.NewDecoder(httpResp.Body).Decode(&payload); err != nil {
+	var payload map[string]json.RawMessage
+	if err := dec.Decode(&payload); err != nil {
 		return nil, fmt.Errorf("parse body: %w", err)
 	}
+	id, err := a.matchResponseSchema(payload)
+	if err != nil {
+		return nil, err
+	}
 	return &core.ResolveUserResponse{
-		ID: payload.GUID,
+		ID: id,
 	}, nil
 }
 
+func (a *UserResolverAdapter) matchResponseSchema(
+	payload map[string]json.RawMessage,
+) (string, error) {
+	for field, value := range payload {
+		if len(payload) != 1 {
+			return "", fmt.Errorf("%w: %d fields", errUnexpectedResponseSchema,
+				len(payload))
+		}
+		if _, ok := a.schemaFields[field]; !ok {
+			return "", fmt.Errorf("%w: field %q", errUnexpectedResponseSchema, field)
+		}
+		var id string
+		if err := json.Unmarshal(value, &id); err != nil || id == "" {
+			return "", fmt.Errorf("%w: field %q must be a non-empty string",
+				errUnexpectedResponseSchema, field)
+		}
+		return id, nil
+	}
+	return "", fmt.Errorf("%w: no fields", errUnexpectedResponseSchema)
+}
+
 func (a *UserResolverAdapter) processUnexpectedStatusCode(resp *http.Response) error {
 	dump, err := httputil.DumpBody(resp.Body)
 	if err != nil {
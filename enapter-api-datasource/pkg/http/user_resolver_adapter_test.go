@@ -0,0 +1,84 @@
+package http_test
+
+import (
+	"context"
+	nethttp "net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/http"
+)
+
+func TestUserResolverAdapter(t *testing.T) {
+	var (
+		contentType string
+		body        string
+	)
+	server := httptest.NewServer(nethttp.HandlerFunc(
+		func(w nethttp.ResponseWriter, r *nethttp.Request) {
+			require.Equal(t, "jack@example.com", r.URL.Query().Get("username"))
+			w.Header().Set("Content-Type", contentType)
+			_, _ = w.Write([]byte(body))
+		}))
+	defer server.Close()
+
+	resolve := func(t *testing.T, schemas ...string) (string, error) {
+		t.Helper()
+		adapter, err := http.NewUserResolverAdapter(http.UserResolverAdapterParams{
+			URL:             server.URL,
+			ResponseSchemas: schemas,
+		})
+		require.NoError(t, err)
+		resp, err := adapter.ResolveUser(context.Background(), &core.ResolveUserRequest{
+			Email: "jack@example.com",
+		})
+		if err != nil {
+			return "", err
+		}
+		return resp.ID, nil
+	}
+
+	t.Run("should accept default schema", func(t *testing.T) {
+		contentType, body = "application/json; charset=utf-8", `{"guid": "e2a8"}`
+		id, err := resolve(t)
+		require.NoError(t, err)
+		require.Equal(t, "e2a8", id)
+	})
+
+	t.Run("should accept allowlisted schema", func(t *testing.T) {
+		contentType, body = "application/json", `{"id": "e2a8"}`
+		id, err := resolve(t, "guid", "id")
+		require.NoError(t, err)
+		require.Equal(t, "e2a8", id)
+	})
+
+	t.Run("should reject other schemas", func(t *testing.T) {
+		contentType = "application/json"
+		for _, body = range []string{
+			`{"id": "e2a8"}`,
+			`{"guid": "e2a8", "admin": true}`,
+			`{"guid": 42}`,
+			`{}`,
+		} {
+			_, err := resolve(t)
+			require.ErrorContains(t, err, "does not match any allowed schema", body)
+		}
+	})
+
+	t.Run("should reject non-JSON response", func(t *testing.T) {
+		contentType, body = "text/html", `{"guid": "e2a8"}`
+		_, err := resolve(t)
+		require.ErrorContains(t, err, "not JSON")
+	})
+
+	t.Run("should reject unknown schema", func(t *testing.T) {
+		_, err := http.NewUserResolverAdapter(http.UserResolverAdapterParams{
+			URL:             server.URL,
+			ResponseSchemas: []string{"uuid"},
+		})
+		require.ErrorContains(t, err, "unsupported response schema")
+	})
+}
@@ -0,0 +1,25 @@
+package enapterapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+var errNotJSON = errors.New("response is not JSON")
+
+// NewJSONResponseDecoder fails unless the response declares a JSON content
+// type. The decoder reads no more than maxSize bytes of the body.
+func NewJSONResponseDecoder(resp *http.Response, maxSize int64) (*json.Decoder, error) {
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || (mediaType != "application/json" &&
+		!strings.HasSuffix(mediaType, "+json")) {
+		return nil, fmt.Errorf("%w: content type %q", errNotJSON, contentType)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxSize)), nil
+}
@@ -0,0 +1,146 @@
+// Package metrics registers the Prometheus metrics of the plugin. Grafana
+// collects them through the metrics endpoint of the plugin SDK, which
+// serves the default registry.
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "enapter_api_datasource"
+
+const dataSourceLabel = "datasource"
+
+var (
+	enapterAPIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "enapter_api_requests_total",
+		Help:      "Number of Enapter API requests by operation, status and Enapter API error code.",
+	}, []string{dataSourceLabel, "operation", "status", "error_code"})
+
+	enapterAPIRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "enapter_api_request_duration_seconds",
+		Help:      "Duration of Enapter API requests, including retries.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{dataSourceLabel, "operation", "status"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "query_duration_seconds",
+		Help:      "Duration of queries by query type.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{dataSourceLabel, "query_type", "status"})
+
+	querySeries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "query_series_total",
+		Help:      "Number of series, i.e. data frames, returned by queries.",
+	}, []string{dataSourceLabel, "query_type"})
+
+	queryRows = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "query_rows_total",
+		Help:      "Number of rows returned by queries.",
+	}, []string{dataSourceLabel, "query_type"})
+
+	userResolutionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "user_resolution_duration_seconds",
+		Help:      "Duration of Grafana user resolution, including the cache.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{dataSourceLabel, "result"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Number of cache lookups by cache and result.",
+	}, []string{dataSourceLabel, "cache", "result"})
+
+	rateLimiterWaits = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "rate_limiter_wait_duration_seconds",
+		Help:      "Time requests have waited for the rate limiter.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{dataSourceLabel, "result"})
+
+	rateLimiterQueued = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "rate_limiter_queued_requests",
+		Help:      "Number of requests waiting for the rate limiter.",
+	}, []string{dataSourceLabel})
+)
+
+// DataSource records the metrics of a single data source. The metrics are
+// labeled by the data source UID, which survives updates of the settings.
+// A nil *DataSource records nothing.
+type DataSource struct {
+	uid string
+}
+
+func ForDataSource(uid string) *DataSource {
+	return &DataSource{uid: uid}
+}
+
+func (m *DataSource) ObserveEnapterAPIRequest(
+	operation string, status int, errorCode string, d time.Duration,
+) {
+	if m == nil {
+		return
+	}
+	statusLabel := strconv.Itoa(status)
+	enapterAPIRequests.WithLabelValues(m.uid, operation, statusLabel, errorCode).Inc()
+	enapterAPIRequestDuration.WithLabelValues(m.uid, operation, statusLabel).
+		Observe(d.Seconds())
+}
+
+func (m *DataSource) ObserveQuery(
+	queryType string, status int, d time.Duration, series, rows int,
+) {
+	if m == nil {
+		return
+	}
+	queryDuration.WithLabelValues(m.uid, queryType, strconv.Itoa(status)).
+		Observe(d.Seconds())
+	querySeries.WithLabelValues(m.uid, queryType).Add(float64(series))
+	queryRows.WithLabelValues(m.uid, queryType).Add(float64(rows))
+}
+
+func (m *DataSource) ObserveUserResolution(result string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	userResolutionDuration.WithLabelValues(m.uid, result).Observe(d.Seconds())
+}
+
+// CountCacheLookup counts a lookup in the named cache. The result is
+// "hit", "miss", "stale" or "coalesced".
+func (m *DataSource) CountCacheLookup(cache, result string) {
+	if m == nil {
+		return
+	}
+	cacheLookups.WithLabelValues(m.uid, cache, result).Inc()
+}
+
+// ObserveRateLimiterWait records the wait of a request, which is either
+// "allowed" or "rejected".
+func (m *DataSource) ObserveRateLimiterWait(result string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	rateLimiterWaits.WithLabelValues(m.uid, result).Observe(d.Seconds())
+}
+
+// AddRateLimiterQueued changes the number of queued requests by delta. The
+// gauge sums up every limiter of the data source, as endpoints using
+// different API tokens have limiters of their own.
+func (m *DataSource) AddRateLimiterQueued(delta int) {
+	if m == nil {
+		return
+	}
+	rateLimiterQueued.WithLabelValues(m.uid).Add(float64(delta))
+}
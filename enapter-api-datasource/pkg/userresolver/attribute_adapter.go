@@ -0,0 +1,73 @@
+package userresolver
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+var _ core.UserResolverPort = (*AttributeAdapter)(nil)
+
+type AttributeAdapterParams struct {
+	// Attribute is "login", "email" or "name". Teams cannot be used,
+	// because Grafana does not pass them to plugins.
+	Attribute string
+	// Pattern is optional. If set, the attribute must match it, and the
+	// first capturing group, if any, is taken as the user ID.
+	Pattern string
+}
+
+// AttributeAdapter takes the Enapter user ID from an attribute of the
+// Grafana user.
+type AttributeAdapter struct {
+	attribute func(*core.ResolveUserRequest) string
+	pattern   *regexp.Regexp
+}
+
+func NewAttributeAdapter(p AttributeAdapterParams) (*AttributeAdapter, error) {
+	var attribute func(*core.ResolveUserRequest) string
+	switch p.Attribute {
+	case "login":
+		attribute = func(r *core.ResolveUserRequest) string { return r.Login }
+	case "email":
+		attribute = func(r *core.ResolveUserRequest) string { return r.Email }
+	case "name":
+		attribute = func(r *core.ResolveUserRequest) string { return r.Name }
+	default:
+		return nil, fmt.Errorf(`%w: want "login", "email" or "name", have %q`,
+			errUnsupportedAttribute, p.Attribute)
+	}
+
+	a := &AttributeAdapter{
+		attribute: attribute,
+	}
+	if p.Pattern != "" {
+		pattern, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern: %w", err)
+		}
+		a.pattern = pattern
+	}
+	return a, nil
+}
+
+func (a *AttributeAdapter) ResolveUser(
+	_ context.Context, req *core.ResolveUserRequest,
+) (*core.ResolveUserResponse, error) {
+	id := a.attribute(req)
+	if a.pattern != nil {
+		match := a.pattern.FindStringSubmatch(id)
+		switch {
+		case match == nil:
+			id = ""
+		case len(match) > 1:
+			id = match[1]
+		}
+	}
+	if id == "" {
+		return nil, core.ErrUserNotFound
+	}
+	return &core.ResolveUserResponse{ID: id}, nil
+}
@@ -0,0 +1,13 @@
+package userresolver
+
+import "errors"
+
+var (
+	errFilePathEmptyOrMissing    = errors.New("file path empty or missing")
+	errURLTemplateEmptyOrMissing = errors.New("URL template empty or missing")
+	errUnsupportedAttribute      = errors.New("unsupported user attribute")
+	errUnexpectedStatus          = errors.New("unexpected status")
+	errInvalidJSONPath           = errors.New("invalid JSONPath")
+	errInvalidUserID             = errors.New("user ID must be a string or a number")
+	errUserIDMissing             = errors.New("user ID missing in response")
+)
@@ -0,0 +1,89 @@
+package userresolver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+)
+
+var _ core.UserResolverPort = (*FileAdapter)(nil)
+
+type FileAdapterParams struct {
+	Path string
+}
+
+// FileAdapter resolves users by a static mapping read from a JSON file. The
+// file holds an object whose keys are Grafana user emails or logins and
+// whose values are Enapter user IDs. The file is read again once it has
+// been modified.
+type FileAdapter struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	mapping map[string]string
+}
+
+func NewFileAdapter(p FileAdapterParams) (*FileAdapter, error) {
+	if p.Path == "" {
+		return nil, errFilePathEmptyOrMissing
+	}
+	a := &FileAdapter{
+		path: p.Path,
+	}
+	// An invalid file is better reported right away.
+	if _, err := a.load(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *FileAdapter) ResolveUser(
+	_ context.Context, req *core.ResolveUserRequest,
+) (*core.ResolveUserResponse, error) {
+	mapping, err := a.load()
+	if err != nil {
+		return nil, err
+	}
+	// Emails are looked up first, because they are less ambiguous.
+	for _, key := range []string{req.Email, req.Login} {
+		if id, ok := mapping[key]; ok && key != "" {
+			return &core.ResolveUserResponse{ID: id}, nil
+		}
+	}
+	return nil, core.ErrUserNotFound
+}
+
+func (a *FileAdapter) load() (map[string]string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+	if a.mapping != nil && info.ModTime().Equal(a.modTime) {
+		return a.mapping, nil
+	}
+
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+	if mapping == nil {
+		mapping = make(map[string]string)
+	}
+
+	a.mapping = mapping
+	a.modTime = info.ModTime()
+	return mapping, nil
+}
@@ -0,0 +1,87 @@
+package userresolver_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/userresolver"
+)
+
+type FileAdapterSuite struct {
+	suite.Suite
+	ctx  context.Context
+	path string
+}
+
+func (s *FileAdapterSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.path = filepath.Join(s.T().TempDir(), "users.json")
+}
+
+func (s *FileAdapterSuite) TestResolveByEmailOrLogin() {
+	s.writeFile(`{"jack@example.com": "e2a8", "jill": "f3b9"}`)
+	adapter := s.newAdapter()
+
+	resp, err := adapter.ResolveUser(s.ctx, &core.ResolveUserRequest{
+		Email: "jack@example.com",
+		Login: "jack",
+	})
+	s.Require().NoError(err)
+	s.Require().Equal("e2a8", resp.ID)
+
+	resp, err = adapter.ResolveUser(s.ctx, &core.ResolveUserRequest{
+		Email: "jill@example.com",
+		Login: "jill",
+	})
+	s.Require().NoError(err)
+	s.Require().Equal("f3b9", resp.ID)
+
+	_, err = adapter.ResolveUser(s.ctx, &core.ResolveUserRequest{
+		Email: "john@example.com",
+	})
+	s.Require().ErrorIs(err, core.ErrUserNotFound)
+}
+
+func (s *FileAdapterSuite) TestReloadModifiedFile() {
+	s.writeFile(`{"jack": "e2a8"}`)
+	adapter := s.newAdapter()
+
+	s.writeFile(`{"jack": "f3b9"}`)
+	later := time.Now().Add(time.Minute)
+	s.Require().NoError(os.Chtimes(s.path, later, later))
+
+	resp, err := adapter.ResolveUser(s.ctx, &core.ResolveUserRequest{Login: "jack"})
+	s.Require().NoError(err)
+	s.Require().Equal("f3b9", resp.ID)
+}
+
+func (s *FileAdapterSuite) TestInvalidFile() {
+	s.writeFile(`["jack"]`)
+	_, err := userresolver.NewFileAdapter(userresolver.FileAdapterParams{
+		Path: s.path,
+	})
+	s.Require().ErrorContains(err, "parse")
+}
+
+func (s *FileAdapterSuite) newAdapter() *userresolver.FileAdapter {
+	adapter, err := userresolver.NewFileAdapter(userresolver.FileAdapterParams{
+		Path: s.path,
+	})
+	s.Require().NoError(err)
+	return adapter
+}
+
+func (s *FileAdapterSuite) writeFile(data string) {
+	const filePerm = 0o600
+	s.Require().NoError(os.WriteFile(s.path, []byte(data), filePerm))
+}
+
+func TestFileAdapter(t *testing.T) {
+	suite.Run(t, new(FileAdapterSuite))
+}
@@ -0,0 +1,176 @@
+package userresolver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	httputil "github.com/Enapter/grafana-plugins/pkg/http/util"
+)
+
+var _ core.UserResolverPort = (*HTTPAdapter)(nil)
+
+type HTTPAdapterParams struct {
+	// URLTemplate is a Go template executed with the Email, Login and Name
+	// of the user. Values must be escaped explicitly, e.g.
+	// "https://idp.example/users?email={{urlquery .Email}}".
+	URLTemplate string
+	// Method defaults to GET.
+	Method string
+	// AuthHeaderName defaults to Authorization if AuthHeaderValue is set.
+	AuthHeaderName  string
+	AuthHeaderValue string
+	// IDPath selects the user ID in the JSON response. It defaults to
+	// "$.guid", which is what the original resolver service responds with.
+	IDPath    string
+	Timeout   time.Duration
+	Transport http.RoundTripper
+}
+
+// HTTPAdapter resolves users with an arbitrary HTTP API. A 404 response
+// means that the user is not known.
+type HTTPAdapter struct {
+	urlTemplate     *template.Template
+	method          string
+	authHeaderName  string
+	authHeaderValue string
+	idPath          jsonPath
+	rawIDPath       string
+	httpClient      http.Client
+}
+
+func NewHTTPAdapter(p HTTPAdapterParams) (*HTTPAdapter, error) {
+	if p.URLTemplate == "" {
+		return nil, errURLTemplateEmptyOrMissing
+	}
+	urlTemplate, err := template.New("url").Parse(p.URLTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("URL template: %w", err)
+	}
+	if p.Method == "" {
+		p.Method = http.MethodGet
+	}
+	if p.AuthHeaderName == "" {
+		p.AuthHeaderName = "Authorization"
+	}
+	if p.IDPath == "" {
+		p.IDPath = "$.guid"
+	}
+	idPath, err := parseJSONPath(p.IDPath)
+	if err != nil {
+		return nil, fmt.Errorf("ID path: %w", err)
+	}
+	if p.Timeout == 0 {
+		p.Timeout = 15 * time.Second
+	}
+	return &HTTPAdapter{
+		urlTemplate:     urlTemplate,
+		method:          strings.ToUpper(p.Method),
+		authHeaderName:  p.AuthHeaderName,
+		authHeaderValue: p.AuthHeaderValue,
+		idPath:          idPath,
+		rawIDPath:       p.IDPath,
+		httpClient: http.Client{
+			Timeout:   p.Timeout,
+			Transport: p.Transport,
+		},
+	}, nil
+}
+
+func (a *HTTPAdapter) ResolveUser(
+	ctx context.Context, req *core.ResolveUserRequest,
+) (_ *core.ResolveUserResponse, retErr error) {
+	url := new(bytes.Buffer)
+	if err := a.urlTemplate.Execute(url, req); err != nil {
+		return nil, fmt.Errorf("execute URL template: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, a.method, url.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+	httpReq.Header["Accept"] = []string{"application/json"}
+	if a.authHeaderValue != "" {
+		httpReq.Header.Set(a.authHeaderName, a.authHeaderValue)
+	}
+
+	httpResp, err := a.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() {
+		if err := httputil.DrainAndClose(httpResp.Body); err != nil {
+			if retErr == nil {
+				retErr = err
+			}
+		}
+	}()
+
+	resp, err := a.processResponse(httpResp)
+	if err != nil {
+		return nil, fmt.Errorf("process response: %w", err)
+	}
+	return resp, nil
+}
+
+func (a *HTTPAdapter) processResponse(
+	httpResp *http.Response,
+) (*core.ResolveUserResponse, error) {
+	if httpResp.StatusCode == http.StatusNotFound {
+		return nil, core.ErrUserNotFound
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, a.processUnexpectedStatus(httpResp)
+	}
+
+	const maxBodySize = 64 << 10
+	dec, err := httputil.NewJSONResponseDecoder(httpResp, maxBodySize)
+	if err != nil {
+		return nil, err
+	}
+	dec.UseNumber()
+	var payload any
+	if err := dec.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("parse body: %w", err)
+	}
+
+	// A missing user is reported with 404 or a null ID, so a response the
+	// ID path does not match is likely of another schema.
+	v, ok := a.idPath.lookup(payload)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", errUserIDMissing, a.rawIDPath)
+	}
+	if v == nil {
+		return nil, core.ErrUserNotFound
+	}
+	var id string
+	switch v := v.(type) {
+	case string:
+		id = v
+	case json.Number:
+		id = v.String()
+	default:
+		return nil, fmt.Errorf("%w: have %T", errInvalidUserID, v)
+	}
+	if id == "" {
+		return nil, core.ErrUserNotFound
+	}
+	return &core.ResolveUserResponse{ID: id}, nil
+}
+
+func (a *HTTPAdapter) processUnexpectedStatus(resp *http.Response) error {
+	dump, err := httputil.DumpBody(resp.Body)
+	if err != nil {
+		//nolint:errorlint // two errors
+		return fmt.Errorf("%w: %s: body dump: <not available>: %v",
+			errUnexpectedStatus, resp.Status, err)
+	}
+	return fmt.Errorf("%w: %s: body dump: %s",
+		errUnexpectedStatus, resp.Status, dump)
+}
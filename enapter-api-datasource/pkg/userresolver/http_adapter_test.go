@@ -0,0 +1,130 @@
+package userresolver_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/Enapter/grafana-plugins/pkg/core"
+	"github.com/Enapter/grafana-plugins/pkg/userresolver"
+)
+
+type HTTPAdapterSuite struct {
+	suite.Suite
+	ctx     context.Context
+	handler http.HandlerFunc
+	server  *httptest.Server
+}
+
+func (s *HTTPAdapterSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.server = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			s.handler(w, r)
+		}))
+}
+
+func (s *HTTPAdapterSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *HTTPAdapterSuite) TestResolveUser() {
+	s.handler = func(w http.ResponseWriter, r *http.Request) {
+		s.Require().Equal(http.MethodPost, r.Method)
+		s.Require().Equal("/users/jack", r.URL.Path)
+		s.Require().Equal("jack@example.com", r.URL.Query().Get("email"))
+		s.Require().Equal("secret", r.Header.Get("X-Api-Key"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"users": [{"id": "e2a8"}]}}`))
+	}
+	adapter := s.newAdapter(userresolver.HTTPAdapterParams{
+		URLTemplate:     s.server.URL + "/users/{{.Login}}?email={{urlquery .Email}}",
+		Method:          "post",
+		AuthHeaderName:  "X-Api-Key",
+		AuthHeaderValue: "secret",
+		IDPath:          "$.data['users'][0].id",
+	})
+
+	resp, err := adapter.ResolveUser(s.ctx, &core.ResolveUserRequest{
+		Email: "jack@example.com",
+		Login: "jack",
+	})
+	s.Require().NoError(err)
+	s.Require().Equal("e2a8", resp.ID)
+}
+
+func (s *HTTPAdapterSuite) TestNumericID() {
+	s.handler = func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 12345678901234567890}`))
+	}
+	adapter := s.newAdapter(userresolver.HTTPAdapterParams{
+		URLTemplate: s.server.URL,
+		IDPath:      "$.id",
+	})
+
+	resp, err := adapter.ResolveUser(s.ctx, &core.ResolveUserRequest{})
+	s.Require().NoError(err)
+	s.Require().Equal("12345678901234567890", resp.ID)
+}
+
+func (s *HTTPAdapterSuite) TestUserNotFound() {
+	for name, handler := range map[string]http.HandlerFunc{
+		"status": func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		},
+		"null ID": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"users": [{"guid": null}]}`))
+		},
+	} {
+		s.Run(name, func() {
+			s.handler = handler
+			adapter := s.newAdapter(userresolver.HTTPAdapterParams{
+				URLTemplate: s.server.URL,
+				IDPath:      "$.users[0].guid",
+			})
+			_, err := adapter.ResolveUser(s.ctx, &core.ResolveUserRequest{})
+			s.Require().ErrorIs(err, core.ErrUserNotFound)
+		})
+	}
+}
+
+func (s *HTTPAdapterSuite) TestUserIDMissing() {
+	s.handler = func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data": {"users": [{"id": "e2a8"}]}}`))
+	}
+	adapter := s.newAdapter(userresolver.HTTPAdapterParams{
+		URLTemplate: s.server.URL,
+		IDPath:      "$.users[0].guid",
+	})
+	_, err := adapter.ResolveUser(s.ctx, &core.ResolveUserRequest{})
+	s.Require().ErrorContains(err, "user ID missing in response: $.users[0].guid")
+	s.Require().NotErrorIs(err, core.ErrUserNotFound)
+}
+
+func (s *HTTPAdapterSuite) TestInvalidIDPath() {
+	for _, path := range []string{"data.id", "$.", "$[x]", "$['id'"} {
+		_, err := userresolver.NewHTTPAdapter(userresolver.HTTPAdapterParams{
+			URLTemplate: s.server.URL,
+			IDPath:      path,
+		})
+		s.Require().ErrorContains(err, "invalid JSONPath", path)
+	}
+}
+
+func (s *HTTPAdapterSuite) newAdapter(
+	p userresolver.HTTPAdapterParams,
+) *userresolver.HTTPAdapter {
+	adapter, err := userresolver.NewHTTPAdapter(p)
+	s.Require().NoError(err)
+	return adapter
+}
+
+func TestHTTPAdapter(t *testing.T) {
+	suite.Run(t, new(HTTPAdapterSuite))
+}
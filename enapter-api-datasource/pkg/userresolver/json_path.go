@@ -0,0 +1,101 @@
+package userresolver
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a subset of JSONPath selecting a single value. It supports
+// the root "$", child names like ".name" or "['name']" and array indices
+// like "[0]".
+type jsonPath []jsonPathStep
+
+type jsonPathStep struct {
+	name  string
+	index int
+	// isIndex tells an array index from a child name.
+	isIndex bool
+}
+
+func parseJSONPath(s string) (jsonPath, error) {
+	rest, ok := strings.CutPrefix(s, "$")
+	if !ok {
+		return nil, fmt.Errorf("%w: %q: must start with $", errInvalidJSONPath, s)
+	}
+
+	var path jsonPath
+	for rest != "" {
+		var (
+			step jsonPathStep
+			err  error
+		)
+		switch rest[0] {
+		case '.':
+			step, rest, err = parseJSONPathName(rest[1:])
+		case '[':
+			step, rest, err = parseJSONPathBracket(rest[1:])
+		default:
+			err = fmt.Errorf("unexpected %q", rest[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", errInvalidJSONPath, s, err)
+		}
+		path = append(path, step)
+	}
+	return path, nil
+}
+
+func parseJSONPathName(s string) (jsonPathStep, string, error) {
+	end := strings.IndexAny(s, ".[")
+	if end < 0 {
+		end = len(s)
+	}
+	if end == 0 {
+		return jsonPathStep{}, "", errors.New("empty name")
+	}
+	return jsonPathStep{name: s[:end]}, s[end:], nil
+}
+
+func parseJSONPathBracket(s string) (jsonPathStep, string, error) {
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return jsonPathStep{}, "", errors.New("unclosed bracket")
+	}
+	inner, rest := s[:end], s[end+1:]
+
+	if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') &&
+		inner[len(inner)-1] == inner[0] {
+		return jsonPathStep{name: inner[1 : len(inner)-1]}, rest, nil
+	}
+
+	index, err := strconv.Atoi(inner)
+	if err != nil || index < 0 {
+		return jsonPathStep{}, "", fmt.Errorf("invalid index %q", inner)
+	}
+	return jsonPathStep{index: index, isIndex: true}, rest, nil
+}
+
+// lookup returns the selected value. It returns false if the value does not
+// exist.
+func (p jsonPath) lookup(v any) (any, bool) {
+	for _, step := range p {
+		if step.isIndex {
+			arr, ok := v.([]any)
+			if !ok || step.index >= len(arr) {
+				return nil, false
+			}
+			v = arr[step.index]
+			continue
+		}
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[step.name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}